@@ -0,0 +1,55 @@
+// ### Heading levels
+
+// Screen readers navigate a page by its headings, and expect them to
+// go down a level at a time: an `<h3>` ought to follow an `<h2>`. In
+// literate source that isn't always so, as with files headed with
+// `// ###` like ours, so as a page's segments are rendered we bring
+// each heading up to at most one level below the last, starting from
+// `<h1>`. Headings going back up are left alone.
+
+package main
+
+import (
+    "fmt"
+)
+
+// The level of the last heading on a page.
+type headingLevels struct {
+    last int
+}
+
+// Move every header of `segs` `by` levels, in the table of contents
+// and, as they're rendered, in their docs, keeping within `<h1>` to
+// `<h6>`.
+func shiftHeadings(segs []*seg, by int) {
+    for _, seg := range segs {
+        for j := range seg.headings {
+            seg.headings[j].level = shiftLevel(seg.headings[j].level, by)
+        }
+        seg.headingShift = by
+    }
+}
+
+func shiftLevel(level, by int) int {
+    level += by
+    if level < 1 {
+        return 1
+    } else if level > 6 {
+        return 6
+    }
+    return level
+}
+
+// Bring the headings in the rendered docs of `seg` into line with
+// those before them.
+func (l *headingLevels) fix(seg *seg) {
+    seg.docsRendered = headingTagPat.ReplaceAllStringFunc(seg.docsRendered, func(tag string) string {
+        match := headingTagPat.FindStringSubmatch(tag)
+        level := shiftLevel(int(match[1][0]-'0'), seg.headingShift)
+        if level > l.last+1 {
+            level = l.last + 1
+        }
+        l.last = level
+        return fmt.Sprintf("<h%d%s>%s</h%d>", level, match[2], match[3], level)
+    })
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "testing"
+
+    xhtml "golang.org/x/net/html"
+)
+
+func attr(n *xhtml.Node, key string) string {
+    for _, a := range n.Attr {
+        if a.Key == key {
+            return a.Val
+        }
+    }
+    return ""
+}
+
+// The elements under `n`, in document order.
+func elements(n *xhtml.Node) []*xhtml.Node {
+    out := []*xhtml.Node{}
+    for c := n.FirstChild; c != nil; c = c.NextSibling {
+        if c.Type == xhtml.ElementNode {
+            out = append(out, c)
+        }
+        out = append(out, elements(c)...)
+    }
+    return out
+}
+
+func inside(n *xhtml.Node, tag string) bool {
+    for p := n.Parent; p != nil; p = p.Parent {
+        if p.Type == xhtml.ElementNode && p.Data == tag {
+            return true
+        }
+    }
+    return false
+}
+
+// Every page of a site has its language, a skip link to its one
+// `<main>`, labelled navigation, a header and footer outside the main
+// content, and headings that go down a level at a time.
+func TestLandmarks(t *testing.T) {
+    dir := writeFiles(t, map[string]string{
+        "a.go":        "// ### A\n\n// Docs.\npackage p\n\n// ##### Details\n\n// More.\nvar x = 1\n\n// #### Back up\nvar y = 1\n",
+        "b.go":        "// # B\n\n// Docs.\npackage p\n",
+        "header.html": "Banner",
+        "footer.html": "Fine print",
+    })
+    if _, stderr, code := runGolit(t, dir, "--out-dir", "out", "--header-file", "header.html", "--footer-file", "footer.html", "a.go", "b.go"); code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    for _, name := range []string{"a.html", "b.html", "index.html"} {
+        page, err := ioutil.ReadFile(filepath.Join(dir, "out", name))
+        if err != nil {
+            t.Fatal(err)
+        }
+        doc := checkHTML5(t, name, string(page))
+        if doc == nil {
+            continue
+        }
+        all := elements(doc)
+        tags := map[string][]*xhtml.Node{}
+        for _, n := range all {
+            tags[n.Data] = append(tags[n.Data], n)
+        }
+        if html := tags["html"]; len(html) != 1 || attr(html[0], "lang") != "en" {
+            t.Errorf("%s: <html> has no lang=\"en\"", name)
+        }
+        if main := tags["main"]; len(main) != 1 || attr(main[0], "id") != "content" {
+            t.Errorf("%s: want one <main id=\"content\">, have %d", name, len(main))
+        }
+        body := elements(tags["body"][0])
+        if len(body) == 0 || attr(body[0], "class") != "skip-link" || attr(body[0], "href") != "#content" {
+            t.Errorf("%s: the body doesn't open with a skip link to #content", name)
+        }
+        labels := map[string]bool{}
+        for _, nav := range tags["nav"] {
+            label := attr(nav, "aria-label")
+            if label == "" || labels[label] {
+                t.Errorf("%s: <nav id=%q> has a missing or repeated label %q", name, attr(nav, "id"), label)
+            }
+            labels[label] = true
+        }
+        for _, landmark := range []string{"header", "footer"} {
+            if len(tags[landmark]) == 0 {
+                t.Errorf("%s: no <%s>", name, landmark)
+            }
+            for _, n := range tags[landmark] {
+                if inside(n, "main") {
+                    t.Errorf("%s: <%s id=%q> is inside <main>", name, landmark, attr(n, "id"))
+                }
+            }
+        }
+        last := 0
+        for _, n := range all {
+            if len(n.Data) != 2 || n.Data[0] != 'h' || n.Data[1] < '1' || n.Data[1] > '6' {
+                continue
+            }
+            level, _ := strconv.Atoi(n.Data[1:])
+            if level > last+1 {
+                t.Errorf("%s: <%s id=%q> follows an <h%d>", name, n.Data, attr(n, "id"), last)
+            }
+            last = level
+        }
+    }
+}
+
+// The skip link's target is the first thing in `<main>`, past the
+// sidebar, table of contents and symbols.
+func TestSkipLinkPassesChrome(t *testing.T) {
+    dir := writeFiles(t, map[string]string{"a.go": "// # A\n\n// ## One\n\n// Docs.\npackage p\n\n// ## Two\nfunc F() {}\n"})
+    stdout, stderr, code := runGolit(t, dir, "a.go")
+    if code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    main := strings.Index(stdout, `<main id="content">`)
+    for _, chrome := range []string{`class="toc"`, `class="symbols"`} {
+        if i := strings.Index(stdout, chrome); i < 0 || i > main {
+            t.Errorf("%s isn't before <main>, where the skip link passes it:\n%s", chrome, stdout)
+        }
+    }
+}
@@ -0,0 +1,118 @@
+// ### Admonitions
+
+// A note or a warning that should stand out can be written with
+// GitHub's alert syntax, a quote starting `> [!WARNING]`, or as a
+// comment whose first line starts `WARNING:`, and either way becomes
+// an aside across the docs, titled with its kind: `NOTE`, `TIP`,
+// `IMPORTANT`, `WARNING` or `CAUTION`. It can go on for paragraphs,
+// and have fenced code in it. Comments break their paragraphs with
+// bare comment lines, which would otherwise end the docs, so within
+// an admonition those are kept as blank lines of it. A comment
+// starting `WARNING:` is made into an alert before it's segmented,
+// and the quotes that are alerts are made into asides once they're
+// rendered, so that any Markdown renderer will do. A kind that's also
+// one of the `--todo-markers`, as `NOTE` is by default, starts a TODO
+// callout rather than an aside, unless it's written as an alert.
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+const admonitionKinds = "NOTE|TIP|IMPORTANT|WARNING|CAUTION"
+
+var (
+    alertLinePat      = regexp.MustCompile(`^\s*>\s*\[!(` + admonitionKinds + `)\]\s*$`)
+    admonitionLinePat = regexp.MustCompile(`^\s*(` + admonitionKinds + `):\s*`)
+    alertHTMLPat      = regexp.MustCompile(`<blockquote>\s*<p>\[!(` + admonitionKinds + `)\]\s*(</p>\s*)?`)
+    blockquoteTagPat  = regexp.MustCompile(`</?blockquote>`)
+)
+
+// Make the comments of `lines` that start with a kind, like
+// `WARNING:`, into alerts, and keep the bare comment lines inside
+// admonitions as part of them: those of a comment starting with a
+// kind, to the end of the comment, and those of a quote when it goes
+// on after them. Lines stay where they are, for line numbers.
+func admonitionLines(lines []string, prefix string, docsPat *regexp.Regexp) []string {
+    bare := regexp.MustCompile(`^(\s*` + regexp.QuoteMeta(prefix) + `)\s*$`)
+    // What a line is as docs, if it's docs.
+    text := func(line string) (string, bool) {
+        if bare.MatchString(line) || !docsPat.MatchString(line) {
+            return "", false
+        }
+        return docsPat.ReplaceAllString(line, ""), true
+    }
+    // The docs line `line`, whose docs are `t`, quoting `quoted`.
+    quote := func(line, t, quoted string) string {
+        return strings.TrimRight(line[:len(line)-len(t)]+"> "+quoted, " ")
+    }
+    fixed := append([]string{}, lines...)
+    in, inComment := "", false
+    for i, line := range fixed {
+        t, ok := text(line)
+        switch {
+        case bare.MatchString(line) && in == "comment":
+            fixed[i] = bare.ReplaceAllString(line, "$1 >")
+        case bare.MatchString(line) && in == "alert":
+            in = ""
+            for _, next := range fixed[i+1:] {
+                if bare.MatchString(next) {
+                    continue
+                }
+                if t, ok := text(next); ok && strings.HasPrefix(strings.TrimSpace(t), ">") {
+                    fixed[i], in = bare.ReplaceAllString(line, "$1 >"), "alert"
+                }
+                break
+            }
+        case bare.MatchString(line):
+        case !ok:
+            in = ""
+        case in == "" && alertLinePat.MatchString(t):
+            in = "alert"
+        case in == "" && !inComment && admonitionLinePat.MatchString(t) && (todoLinePat == nil || !todoLinePat.MatchString(t)):
+            in, fixed[i] = "comment", quote(line, t, admonitionLinePat.ReplaceAllString(t, "[!$1] "))
+        case in == "comment":
+            fixed[i] = quote(line, t, t)
+        case in == "alert" && !strings.HasPrefix(strings.TrimSpace(t), ">"):
+            in = ""
+        }
+        inComment = ok || bare.MatchString(line)
+    }
+    return fixed
+}
+
+// Make the quotes of the rendered docs `rendered` that are alerts into
+// asides.
+func markAdmonitions(rendered string) string {
+    for {
+        match := alertHTMLPat.FindStringSubmatchIndex(rendered)
+        if match == nil {
+            return rendered
+        }
+        kind := rendered[match[2]:match[3]]
+        open := fmt.Sprintf(`<aside class="admonition admonition-%s"><p class="admonition-title">%s</p>`+"\n", strings.ToLower(kind), kind[:1]+strings.ToLower(kind[1:]))
+        if match[4] < 0 {
+            open += "<p>"
+        }
+        // The quote ends at the tag closing it, past any nested in it.
+        end, depth := -1, 1
+        for _, tag := range blockquoteTagPat.FindAllStringIndex(rendered[match[1]:], -1) {
+            if rendered[match[1]+tag[0]+1] == '/' {
+                depth--
+            } else {
+                depth++
+            }
+            if depth == 0 {
+                end = match[1] + tag[0]
+                break
+            }
+        }
+        if end < 0 {
+            return rendered
+        }
+        rendered = rendered[:match[0]] + open + rendered[match[1]:end] + "</aside>" + rendered[end+len("</blockquote>"):]
+    }
+}
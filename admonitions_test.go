@@ -0,0 +1,111 @@
+package main
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestAdmonitionLines(t *testing.T) {
+    useTodos(t, "show", "TODO")
+    docsPat, _, err := commentPats("//")
+    if err != nil {
+        t.Fatal(err)
+    }
+    cases := []struct{ src, want string }{
+        {"// WARNING: careful.\n//\n// More.\ncode()\n",
+            "// > [!WARNING] careful.\n// >\n// > More.\ncode()\n"},
+        {"// CAUTION: like this:\n//\n// ```go\n// x()\n// ```\n//\n// Done.\n",
+            "// > [!CAUTION] like this:\n// >\n// > ```go\n// > x()\n// > ```\n// >\n// > Done.\n"},
+        {"// > [!TIP]\n// > One.\n//\n// > Two.\n//\n// After.\n",
+            "// > [!TIP]\n// > One.\n// >\n// > Two.\n//\n// After.\n"},
+        {"// > [!TIP]\n// > Try:\n// >\n// > ```\n// > x()\n// > ```\n",
+            "// > [!TIP]\n// > Try:\n// >\n// > ```\n// > x()\n// > ```\n"},
+        {"// Text.\n// WARNING: not the first line.\n",
+            "// Text.\n// WARNING: not the first line.\n"},
+        {"    // NOTE: indented.\n    x()\n",
+            "    // > [!NOTE] indented.\n    x()\n"},
+    }
+    for _, c := range cases {
+        got := strings.Join(admonitionLines(strings.Split(c.src, "\n"), "//", docsPat), "\n")
+        if got != c.want {
+            t.Errorf("admonitionLines(%q) =\n%s\nwant\n%s", c.src, got, c.want)
+        }
+    }
+}
+
+func TestMarkAdmonitions(t *testing.T) {
+    cases := []struct{ rendered, want string }{
+        {"<blockquote>\n<p>[!WARNING]\nCareful.</p>\n</blockquote>\n",
+            "<aside class=\"admonition admonition-warning\"><p class=\"admonition-title\">Warning</p>\n<p>Careful.</p>\n</aside>\n"},
+        {"<blockquote>\n<p>[!TIP]</p>\n<pre><code>x()\n</code></pre>\n<blockquote>\n<p>Nested.</p>\n</blockquote>\n</blockquote>\n<p>After.</p>\n",
+            "<aside class=\"admonition admonition-tip\"><p class=\"admonition-title\">Tip</p>\n<pre><code>x()\n</code></pre>\n<blockquote>\n<p>Nested.</p>\n</blockquote>\n</aside>\n<p>After.</p>\n"},
+        {"<blockquote>\n<p>Just a quote.</p>\n</blockquote>\n",
+            "<blockquote>\n<p>Just a quote.</p>\n</blockquote>\n"},
+    }
+    for _, c := range cases {
+        if got := markAdmonitions(c.rendered); got != c.want {
+            t.Errorf("markAdmonitions(%q) =\n%s\nwant\n%s", c.rendered, got, c.want)
+        }
+    }
+}
+
+// An admonition's fenced code is rendered as code, inside the aside.
+func TestAdmonitionCode(t *testing.T) {
+    dir := writeFiles(t, map[string]string{"a.go": "// CAUTION: run it like this:\n//\n// ```go\n// x := f()\n// ```\n//\n// Then stop.\npackage a\n"})
+    stdout, stderr, code := runGolit(t, dir, "--remote-css", "--no-vcs-info", "a.go")
+    if code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    start := strings.Index(stdout, `<aside class="admonition admonition-caution">`)
+    end := strings.Index(stdout, "</aside>")
+    if start < 0 || end < start {
+        t.Fatalf("no aside:\n%s", stdout)
+    }
+    aside := stdout[start:end]
+    for _, want := range []string{"run it like this:", "<pre><code class=\"language-go\">x := f()\n</code></pre>", "Then stop."} {
+        if !strings.Contains(aside, want) {
+            t.Errorf("aside has no %q:\n%s", want, aside)
+        }
+    }
+}
+
+// `NOTE:` is both a kind of admonition and a TODO marker. It's the
+// marker's while `--todo-markers` names it, and an alert's otherwise.
+func TestNoteOwner(t *testing.T) {
+    docsPat, _, err := commentPats("//")
+    if err != nil {
+        t.Fatal(err)
+    }
+    lines := []string{"// NOTE: remember this.", "package a"}
+    useTodos(t, "show", todoMarkers)
+    if got := admonitionLines(lines, "//", docsPat); !reflect.DeepEqual(got, lines) {
+        t.Errorf("with NOTE a marker, admonitionLines = %q", got)
+    }
+    useTodos(t, "show", "TODO")
+    if got, want := admonitionLines(lines, "//", docsPat), []string{"// > [!NOTE] remember this.", "package a"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("with NOTE not a marker, admonitionLines = %q, want %q", got, want)
+    }
+
+    dir := writeFiles(t, map[string]string{"a.go": "// Docs.\npackage a\n\n// NOTE: remember this.\nvar x = 1\n\n// > [!NOTE]\n// > An aside.\nvar y = 2\n"})
+    for _, c := range []struct {
+        args   []string
+        todo   bool
+        asides int
+    }{
+        {nil, true, 1},
+        {[]string{"--todos", "only"}, true, 0},
+        {[]string{"--todo-markers", "TODO"}, false, 2},
+    } {
+        args := append([]string{"--remote-css", "--no-vcs-info"}, append(c.args, "a.go")...)
+        stdout, stderr, code := runGolit(t, dir, args...)
+        if code != 0 {
+            t.Fatalf("%q: exit %d: %s", args, code, stderr)
+        }
+        todo := strings.Contains(stdout, `<p class="todo todo-note"><span class="todo-label">NOTE</span> remember this.`)
+        asides := strings.Count(stdout, `<aside class="admonition admonition-note">`)
+        if todo != c.todo || asides != c.asides {
+            t.Errorf("%q: TODO callout %v, %d asides; want %v, %d", c.args, todo, asides, c.todo, c.asides)
+        }
+    }
+}
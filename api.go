@@ -0,0 +1,92 @@
+// ### API appendix
+
+// godoc shows a package's API and golit its story, and with `--api`
+// a page of Go has both: after its segments comes an API section with
+// each of the file's exported declarations, its signature as gofmt
+// would print it and its doc comment rendered as docs, linking back
+// to the segment the declaration is in. Funcs are shown without their
+// bodies. A file with nothing exported has no API section.
+
+package main
+
+import (
+    "bytes"
+    "flag"
+    "go/ast"
+    "go/printer"
+    "html/template"
+    "path/filepath"
+    "strings"
+)
+
+var apiAppendix bool
+
+func init() {
+    flag.BoolVar(&apiAppendix, "api", false, "end pages of Go files with their exported declarations and doc comments, like godoc")
+}
+
+// One exported declaration in the appendix: its name, the signature,
+// its doc comment as HTML, the anchor of its segment, and whether
+// it's deprecated.
+type apiEntry struct {
+    Name       string
+    Signature  string
+    DocHTML    template.HTML
+    Anchor     string
+    Deprecated bool
+}
+
+// The appendix for the Go file at `sourcePath`, whose segments are
+// `segs`, or none if it isn't wanted or has nothing to show.
+func apiEntries(sourcePath string, src []byte, segs []*seg) ([]apiEntry, error) {
+    if !apiAppendix || docsOnly || filepath.Ext(sourcePath) != ".go" {
+        return nil, nil
+    }
+    found, fset := fileSymbols(src)
+    entries := []apiEntry{}
+    done := map[ast.Node]bool{}
+    for _, sym := range found {
+        if !sym.exported || done[sym.decl] {
+            continue
+        }
+        done[sym.decl] = true
+        in := segmentAt(segs, sym.line)
+        if in == nil {
+            continue
+        }
+        // Comments are for the docs, and bodies aren't part of a
+        // signature.
+        decl := sym.decl
+        switch d := decl.(type) {
+        case *ast.FuncDecl:
+            bare := *d
+            bare.Body, bare.Doc = nil, nil
+            decl = &bare
+        case *ast.GenDecl:
+            switch spec := d.Specs[0].(type) {
+            case *ast.TypeSpec:
+                bare := *spec
+                bare.Doc, bare.Comment = nil, nil
+                decl = &ast.GenDecl{Tok: d.Tok, Specs: []ast.Spec{&bare}}
+            case *ast.ValueSpec:
+                bare := *spec
+                bare.Doc, bare.Comment = nil, nil
+                decl = &ast.GenDecl{Tok: d.Tok, Specs: []ast.Spec{&bare}}
+            }
+        }
+        var sig bytes.Buffer
+        if err := (&printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}).Fprint(&sig, fset, decl); err != nil {
+            return nil, err
+        }
+        entry := apiEntry{Name: sym.name, Signature: sig.String(), Anchor: in.anchor, Deprecated: isDeprecated(sym.doc)}
+        if sym.doc != nil {
+            rendered, err := docRenderer.RenderDocs(sym.doc.Text())
+            if err != nil {
+                return nil, err
+            }
+            entry.DocHTML = template.HTML(strings.TrimSpace(markDeprecated(rendered)))
+        }
+        entries = append(entries, entry)
+    }
+    return entries, nil
+}
@@ -0,0 +1,33 @@
+// ### Docs-only pages
+
+// With `--docs-only`, a page is just the prose: the docs of every
+// segment, headers included, run together into one Markdown document
+// and rendered as an `<article>`, with the code and the table of the
+// other layouts left out. Fenced code blocks within the comments are
+// part of the docs, so they stay.
+
+package main
+
+import (
+    "flag"
+    "strings"
+)
+
+var docsOnly bool
+
+func init() {
+    flag.BoolVar(&docsOnly, "docs-only", false, "render only the docs of each file, as one document, leaving out the code")
+}
+
+// The docs of `segs` as a single full-width segment.
+func articleSegment(segs []*seg) *seg {
+    docs := []string{}
+    headings := []heading{}
+    for _, seg := range segs {
+        if strings.TrimSpace(seg.docs) != "" {
+            docs = append(docs, strings.Trim(seg.docs, "\n"))
+        }
+        headings = append(headings, seg.headings...)
+    }
+    return &seg{docs: strings.Join(docs, "\n\n"), lang: segs[0].lang, wide: true, line: segs[0].line, headings: headings}
+}
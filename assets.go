@@ -0,0 +1,116 @@
+// ### Assets
+
+// Docs can show images, like `![flow](assets/flow.png)`. For those to
+// resolve in the generated site, `--assets dir` copies a directory
+// verbatim into the output, at the same place relative to the output
+// as it has to the sources. Dotfiles are skipped, and existing files
+// are simply overwritten, so repeated builds are fine.
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "html"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+var assetsDir string
+
+func init() {
+    flag.StringVar(&assetsDir, "assets", "", "copy `dir` of static files, like images, into the output")
+}
+
+// Copy the assets directory under `outRoot`, the top of the output,
+// given `sourceRoot`, the top of the sources. Each file that can't be
+// copied is reported with its path, and we carry on with the rest.
+func copyAssets(outRoot, sourceRoot string) error {
+    src, err := filepath.Abs(assetsDir)
+    if err != nil {
+        return err
+    }
+    dest := filepath.Join(outRoot, filepath.Base(src))
+    if rel, err := filepath.Rel(sourceRoot, src); err == nil && !outside(rel) {
+        dest = filepath.Join(outRoot, rel)
+    }
+    failed := 0
+    err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "golit: %s: %v\n", path, err)
+            failed++
+            return nil
+        }
+        if strings.HasPrefix(info.Name(), ".") && path != src {
+            if info.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        if !info.Mode().IsRegular() {
+            return nil
+        }
+        rel, err := filepath.Rel(src, path)
+        if err != nil {
+            return err
+        }
+        data, err := ioutil.ReadFile(path)
+        if err == nil {
+            err = writeFileAtomic(filepath.Join(dest, rel), data)
+        }
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "golit: %s: %v\n", path, err)
+            failed++
+        }
+        return nil
+    })
+    if err != nil {
+        return err
+    }
+    if failed > 0 {
+        return fmt.Errorf("couldn't copy %d asset(s) from %s", failed, assetsDir)
+    }
+    return nil
+}
+
+// Whether `rel`, a path made by `filepath.Rel`, leads out of where
+// it's relative to, rather than to a name like `..foo` inside it.
+func outside(rel string) bool {
+    return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Under `--strict`, check that images in the rendered docs from
+// `sourcePath` with relative paths point at files in the assets
+// directory, since those are the only ones that will be there.
+var imgPat = regexp.MustCompile(`<img[^>]*\ssrc="([^"]*)"`)
+
+func checkImages(docsHTML, sourcePath string) error {
+    if !strict || assetsDir == "" {
+        return nil
+    }
+    assets, err := filepath.Abs(assetsDir)
+    if err != nil {
+        return err
+    }
+    source, err := filepath.Abs(sourcePath)
+    if err != nil {
+        return err
+    }
+    for _, match := range imgPat.FindAllStringSubmatch(docsHTML, -1) {
+        src := html.UnescapeString(match[1])
+        if src == "" || strings.HasPrefix(src, "/") || strings.Contains(strings.SplitN(src, "/", 2)[0], ":") {
+            continue
+        }
+        path := filepath.Join(filepath.Dir(source), filepath.FromSlash(src))
+        rel, err := filepath.Rel(assets, path)
+        if _, statErr := os.Stat(path); err != nil || outside(rel) || statErr != nil {
+            if err := warn("image %s isn't in the assets directory %s", src, assetsDir); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
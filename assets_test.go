@@ -0,0 +1,40 @@
+package main
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "testing"
+)
+
+func TestOutside(t *testing.T) {
+    for rel, want := range map[string]bool{
+        "..":                            true,
+        filepath.Join("..", "x.png"):    true,
+        filepath.Join("..", "..", "a"):  true,
+        "..foo":                         false,
+        filepath.Join("..foo", "x.png"): false,
+        filepath.Join("a", "..b"):       false,
+        ".":                             false,
+    } {
+        if got := outside(rel); got != want {
+            t.Errorf("outside(%q) = %v, want %v", rel, got, want)
+        }
+    }
+}
+
+// Assets under a directory whose name starts with dots are still
+// inside the sources, so they're copied to the same place in the
+// output, where `--strict` finds their images.
+func TestDottedAssets(t *testing.T) {
+    dir := writeFiles(t, map[string]string{
+        "a.go":                "// ![Flow](..docs/img/flow.png)\npackage p\n",
+        "b.go":                "// More.\npackage p\n",
+        "..docs/img/flow.png": "png",
+    })
+    if _, stderr, code := runGolit(t, dir, "--strict", "--assets", "..docs/img", "--out-dir", "out", "a.go", "b.go"); code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    if data, err := ioutil.ReadFile(filepath.Join(dir, "out", "..docs", "img", "flow.png")); err != nil || string(data) != "png" {
+        t.Errorf("asset wasn't copied to out/..docs/img: %v", err)
+    }
+}
@@ -0,0 +1,94 @@
+// ### Batched highlighting
+
+// Even spread across workers, starting `pygmentize` once per segment
+// is most of what a build costs. So, as shocco does, we join all the
+// code of a file into one input with a divider comment between
+// segments, highlight it in a single call, and split the HTML back
+// apart where the rendered dividers are. Pygments closes its spans at
+// the end of every line, so each divider comes out on a line of its
+// own. The divider carries a random nonce so it can't be mistaken for
+// anything in the code. If splitting doesn't give back one piece per
+// segment, we fall back to highlighting them one at a time.
+
+package main
+
+import (
+    "crypto/rand"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// The wrapper Pygments puts around highlighted code, which each piece
+// gets a copy of once split.
+var highlightPat = regexp.MustCompile(`(?s)^(<div class="highlight"><pre>(?:<span></span>)?)(.*)(</pre></div>\n?)$`)
+
+// A divider comment for code using the line comment `prefix`.
+func divider(prefix string) (string, error) {
+    nonce := make([]byte, 8)
+    if _, err := rand.Read(nonce); err != nil {
+        return "", err
+    }
+    return fmt.Sprintf("%s golit-divider-%x", prefix, nonce), nil
+}
+
+// Highlight the code of all the segments that have any in one call,
+// filling in `codeRendered`, and report whether it worked. Only
+// Pygments is slow to start, so other renderers aren't batched.
+func highlightBatch(segs []*seg, prefix string) bool {
+    switch codeRenderer.(type) {
+    case pygmentizeCommand, *highlighter:
+    default:
+        return false
+    }
+    code := []*seg{}
+    for _, seg := range segs {
+        if !seg.wide {
+            code = append(code, seg)
+        }
+    }
+    if len(code) < 2 {
+        return false
+    }
+    div, err := divider(prefix)
+    if err != nil {
+        return false
+    }
+    lang := code[0].lang
+    parts := []string{}
+    for _, seg := range code {
+        if seg.lang != lang || strings.Contains(seg.code, div) {
+            return false
+        }
+        parts = append(parts, seg.code+"  ")
+    }
+    out, err := codeRenderer.RenderCode(lang, strings.Join(parts, "\n"+div+"\n"))
+    if err != nil {
+        verbosef("batched highlighting failed, going segment by segment: %v", err)
+        return false
+    }
+
+    wrapper := highlightPat.FindStringSubmatch(out)
+    if wrapper == nil {
+        verbosef("batched highlighting gave unexpected output, going segment by segment")
+        return false
+    }
+    pieces := []string{""}
+    for _, line := range strings.SplitAfter(wrapper[2], "\n") {
+        if strings.Contains(line, div[len(prefix):]) {
+            pieces = append(pieces, "")
+            continue
+        }
+        pieces[len(pieces)-1] += line
+    }
+    if len(pieces) != len(code) {
+        verbosef("batched highlighting split into %d pieces for %d segments, going segment by segment", len(pieces), len(code))
+        return false
+    }
+    // Pygments drops the leading newlines of its input, which in a
+    // batch only happens for the first segment.
+    for i, seg := range code {
+        seg.codeRendered = wrapper[1] + strings.TrimLeft(pieces[i], "\n") + wrapper[3]
+    }
+    return true
+}
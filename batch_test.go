@@ -0,0 +1,100 @@
+package main
+
+import (
+    "fmt"
+    "os/exec"
+    "strings"
+    "testing"
+)
+
+// The segments of a Go file with `n` documented funcs.
+func manySegments(t testing.TB, n int) []*seg {
+    var src strings.Builder
+    src.WriteString("// Package p.\npackage p\n")
+    for i := 0; i < n; i++ {
+        fmt.Fprintf(&src, "\n// F%d returns %d.\nfunc F%d() int {\n    return %d\n}\n", i, i, i, i)
+    }
+    segs, err := fileSegments("p.go", []byte(src.String()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return segs
+}
+
+// Use Pygments for highlighting, or skip without it.
+func usePygments(t testing.TB) {
+    path, err := exec.LookPath("pygmentize")
+    if err != nil {
+        t.Skip("no pygmentize")
+    }
+    saved := codeRenderer
+    codeRenderer = pygmentizeCommand(path)
+    t.Cleanup(func() { codeRenderer = saved })
+}
+
+// The batch splits back into one piece per segment, each with its own
+// code and no divider.
+func TestHighlightBatch(t *testing.T) {
+    usePygments(t)
+    segs := manySegments(t, 10)
+    if !highlightBatch(segs, "//") {
+        t.Fatal("batched highlighting fell back")
+    }
+    for i, seg := range segs[1:] {
+        if !strings.Contains(seg.codeRendered, fmt.Sprintf(">F%d<", i)) || strings.Contains(seg.codeRendered, "golit-divider") {
+            t.Errorf("segment %d highlighted as %q", i+1, seg.codeRendered)
+        }
+        if !highlightPat.MatchString(seg.codeRendered) {
+            t.Errorf("segment %d isn't wrapped like Pygments output: %q", i+1, seg.codeRendered)
+        }
+    }
+}
+
+// Dividers are comments, and no two are alike.
+func TestDivider(t *testing.T) {
+    a, err := divider("#")
+    if err != nil {
+        t.Fatal(err)
+    }
+    b, err := divider("#")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.HasPrefix(a, "# golit-divider-") || a == b {
+        t.Errorf("dividers %q and %q", a, b)
+    }
+}
+
+// Segments that need different lexers aren't batched.
+func TestHighlightBatchMixed(t *testing.T) {
+    usePygments(t)
+    segs := manySegments(t, 3)
+    segs[2].lang = "text"
+    if highlightBatch(segs, "//") {
+        t.Error("batched segments with different lexers")
+    }
+}
+
+func BenchmarkHighlightSegments(b *testing.B) {
+    usePygments(b)
+    segs := manySegments(b, 50)
+    b.ResetTimer()
+    for n := 0; n < b.N; n++ {
+        for _, seg := range segs {
+            if _, err := codeRenderer.RenderCode(seg.lang, seg.code); err != nil {
+                b.Fatal(err)
+            }
+        }
+    }
+}
+
+func BenchmarkHighlightBatch(b *testing.B) {
+    usePygments(b)
+    segs := manySegments(b, 50)
+    b.ResetTimer()
+    for n := 0; n < b.N; n++ {
+        if !highlightBatch(segs, "//") {
+            b.Fatal("batched highlighting fell back")
+        }
+    }
+}
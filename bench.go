@@ -0,0 +1,264 @@
+// ### Benchmarks
+
+// A test file's `Benchmark` funcs get segments of their own too, like
+// its examples, with the benchmark's body beside its doc comment. With
+// `--bench-results`, given what `go test -bench` printed, each also
+// gets a table of how it did: nanoseconds, bytes allocated and
+// allocations per op, for it and each of its sub-benchmarks, averaged
+// over any runs `-count` asked for. Benchmarks in the sources with no
+// results, and results for benchmarks that aren't in the sources, are
+// warned about.
+
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "crypto/sha256"
+    "flag"
+    "fmt"
+    "go/ast"
+    "go/parser"
+    "go/token"
+    "io/ioutil"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+var benchResults string
+
+func init() {
+    flag.StringVar(&benchResults, "bench-results", "", "show the results of benchmarks from `file`, the output of go test -bench")
+}
+
+// The results for one benchmark, or sub-benchmark, from the package
+// `pkg`, and the line they're first on. Each measure is a total over
+// the runs, and `has` says which of them were reported.
+type benchResult struct {
+    pkg, name                   string
+    line, runs                  int
+    nsPerOp, bytesPerOp, allocs float64
+    has                         map[string]bool
+}
+
+// A row of a benchmark's table, as shown.
+type benchRow struct {
+    Name        string
+    Runs        int
+    NsPerOp     string
+    BytesPerOp  string
+    AllocsPerOp string
+}
+
+// The results from `--bench-results`, in the order they first came,
+// and a hash of them for the cache.
+var (
+    benchmarks []*benchResult
+    benchHash  string
+)
+
+// Read the results named by `--bench-results`, if any.
+func loadBenchResults() error {
+    if benchResults == "" {
+        return nil
+    }
+    data, err := ioutil.ReadFile(benchResults)
+    if err != nil {
+        return err
+    }
+    results, err := parseBenchResults(bufio.NewScanner(bytes.NewReader(data)))
+    if err != nil {
+        return fmt.Errorf("%s: %v", benchResults, err)
+    }
+    benchmarks, benchHash = results, fmt.Sprintf("%x", sha256.Sum256(data))
+    return nil
+}
+
+// The results as they go into the options hash, so that pages are
+// rebuilt when they change.
+func benchResultsKey() string {
+    return benchHash
+}
+
+var procsSuffixPat = regexp.MustCompile(`-\d+$`)
+
+// Parse the output of `go test -bench`. Besides results, it has lines
+// like `pkg: example.com/m` naming the package those after it are
+// from, and lines from tests, `PASS` and `ok`, which are passed over.
+// A result is the benchmark's name, with the `GOMAXPROCS` it ran with
+// after a dash unless that was 1, how many times it ran, and pairs of
+// values and units, like `1234 ns/op`. Other units, like `MB/s` or
+// those of `b.ReportMetric`, are passed over too.
+func parseBenchResults(scanner *bufio.Scanner) ([]*benchResult, error) {
+    results := []*benchResult{}
+    byName := map[string]*benchResult{}
+    pkg := ""
+    for n := 1; scanner.Scan(); n++ {
+        line := strings.TrimSpace(scanner.Text())
+        if strings.HasPrefix(line, "pkg:") {
+            pkg = strings.TrimSpace(strings.TrimPrefix(line, "pkg:"))
+            continue
+        }
+        fields := strings.Fields(line)
+        if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") || len(fields)%2 != 0 {
+            continue
+        }
+        if _, err := strconv.Atoi(fields[1]); err != nil {
+            continue
+        }
+        name := procsSuffixPat.ReplaceAllString(fields[0], "")
+        result := byName[pkg+" "+name]
+        if result == nil {
+            result = &benchResult{pkg: pkg, name: name, line: n, has: map[string]bool{}}
+            byName[pkg+" "+name] = result
+            results = append(results, result)
+        }
+        result.runs++
+        for i := 2; i+1 < len(fields); i += 2 {
+            value, err := strconv.ParseFloat(fields[i], 64)
+            if err != nil {
+                return nil, fmt.Errorf("line %d: bad value %q", n, fields[i])
+            }
+            switch fields[i+1] {
+            case "ns/op":
+                result.nsPerOp += value
+            case "B/op":
+                result.bytesPerOp += value
+            case "allocs/op":
+                result.allocs += value
+            default:
+                continue
+            }
+            result.has[fields[i+1]] = true
+        }
+    }
+    return results, scanner.Err()
+}
+
+// Whether `result` is for the benchmark func `name`, in the package
+// `pkg`, itself or one of its sub-benchmarks. Results that don't say
+// what package they're from, and sources not in a module, match on
+// names alone.
+func (result *benchResult) of(pkg, name string) bool {
+    if result.pkg != "" && pkg != "" && result.pkg != pkg {
+        return false
+    }
+    return result.name == name || strings.HasPrefix(result.name, name+"/")
+}
+
+// The rows of the table for the benchmark func `name` in the test
+// file at `sourcePath`.
+func benchRows(sourcePath, name string) []benchRow {
+    pkg := ""
+    if abs, err := filepath.Abs(sourcePath); err == nil {
+        pkg = importPath(filepath.Dir(abs))
+    }
+    rows := []benchRow{}
+    for _, result := range benchmarks {
+        if !result.of(pkg, name) {
+            continue
+        }
+        rows = append(rows, benchRow{
+            Name:        result.name,
+            Runs:        result.runs,
+            NsPerOp:     result.mean("ns/op", result.nsPerOp),
+            BytesPerOp:  result.mean("B/op", result.bytesPerOp),
+            AllocsPerOp: result.mean("allocs/op", result.allocs),
+        })
+    }
+    return rows
+}
+
+// The mean of the `total` of a measure in `unit` over the runs, or ""
+// if it wasn't reported.
+func (result *benchResult) mean(unit string, total float64) string {
+    if !result.has[unit] {
+        return ""
+    }
+    mean := total / float64(result.runs)
+    if mean >= 100 || mean == float64(int64(mean)) {
+        return strconv.FormatFloat(mean, 'f', 0, 64)
+    }
+    return strconv.FormatFloat(mean, 'f', 2, 64)
+}
+
+// Whether `name` is a benchmark's, as `go test` has it: `Benchmark`,
+// then nothing or anything not starting with a lower-case letter.
+func isBenchmarkName(name string) bool {
+    if !strings.HasPrefix(name, "Benchmark") {
+        return false
+    }
+    r, _ := utf8.DecodeRuneInString(name[len("Benchmark"):])
+    return !unicode.IsLower(r)
+}
+
+// The benchmarks of `file`.
+func benchmarkSpans(fset *token.FileSet, file *ast.File) []funcSpan {
+    spans := []funcSpan{}
+    for _, decl := range file.Decls {
+        fn, ok := decl.(*ast.FuncDecl)
+        if !ok || fn.Recv != nil || fn.Body == nil || !isBenchmarkName(fn.Name.Name) || fn.Type.Params.NumFields() != 1 {
+            continue
+        }
+        span := spanOf(fset, fn)
+        span.benchmark = fn.Name.Name
+        spans = append(spans, span)
+    }
+    return spans
+}
+
+// The segment for the benchmark at `span`, from the test file at
+// `sourcePath`.
+func benchmarkSegment(sourcePath string, span funcSpan, lines []string, docsPat *regexp.Regexp) *seg {
+    s := spanSegment(span, "Benchmark "+strings.TrimPrefix(span.benchmark, "Benchmark"), lines, docsPat)
+    s.benchmarks = benchRows(sourcePath, span.benchmark)
+    return s
+}
+
+// Warn about the benchmarks in the test files among `sources` that
+// have no results, and the results with no benchmark in them.
+func checkBenchResults(sources []string) error {
+    if benchResults == "" {
+        return nil
+    }
+    used := map[*benchResult]bool{}
+    for _, sourcePath := range sources {
+        if !isTestFile(sourcePath) || filepath.Ext(sourcePath) != ".go" {
+            continue
+        }
+        fset := token.NewFileSet()
+        file, err := parser.ParseFile(fset, sourcePath, nil, 0)
+        if err != nil {
+            continue
+        }
+        pkg := ""
+        if abs, err := filepath.Abs(sourcePath); err == nil {
+            pkg = importPath(filepath.Dir(abs))
+        }
+        for _, span := range benchmarkSpans(fset, file) {
+            found := false
+            for _, result := range benchmarks {
+                if result.of(pkg, span.benchmark) {
+                    used[result], found = true, true
+                }
+            }
+            if !found {
+                if err := report(Diagnostic{Path: sourcePath, Line: span.start, Message: span.benchmark + " has no results in " + benchResults}); err != nil {
+                    return err
+                }
+            }
+        }
+    }
+    for _, result := range benchmarks {
+        if !used[result] {
+            if err := report(Diagnostic{Path: benchResults, Line: result.line, Message: "results for " + result.name + ", which isn't a benchmark in the sources"}); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+    "bufio"
+    "os"
+    "reflect"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+// Results from two packages, with a benchmark run twice, one with
+// a sub-benchmark, metrics that aren't shown, and the test output
+// between them passed over.
+func TestParseBenchResults(t *testing.T) {
+    f, err := os.Open("testdata/bench/results.txt")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    results, err := parseBenchResults(bufio.NewScanner(f))
+    if err != nil {
+        t.Fatal(err)
+    }
+    got := []string{}
+    for _, r := range results {
+        got = append(got, strings.Join([]string{r.pkg, r.name, strconv.Itoa(r.line), strconv.Itoa(r.runs), r.mean("ns/op", r.nsPerOp), r.mean("B/op", r.bytesPerOp), r.mean("allocs/op", r.allocs)}, " "))
+    }
+    want := []string{
+        "example.com/m BenchmarkParse 5 2 12500 4096 12",
+        "example.com/m BenchmarkParse/small 7 1 1.25  ",
+        "example.com/m BenchmarkRender 8 1 250000  ",
+        "example.com/m/sub BenchmarkParse 14 1 6000  ",
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("results =\n%q, want\n%q", got, want)
+    }
+}
+
+func TestParseBenchResultsError(t *testing.T) {
+    _, err := parseBenchResults(bufio.NewScanner(strings.NewReader("PASS\nBenchmarkX-8 10 fast ns/op\n")))
+    if want := `line 2: bad value "fast"`; err == nil || err.Error() != want {
+        t.Errorf("error = %v, want %s", err, want)
+    }
+}
+
+func TestBenchResultOf(t *testing.T) {
+    cases := []struct {
+        result    benchResult
+        pkg, name string
+        want      bool
+    }{
+        {benchResult{pkg: "example.com/m", name: "BenchmarkParse"}, "example.com/m", "BenchmarkParse", true},
+        {benchResult{pkg: "example.com/m", name: "BenchmarkParse/small"}, "example.com/m", "BenchmarkParse", true},
+        {benchResult{pkg: "example.com/m", name: "BenchmarkParser"}, "example.com/m", "BenchmarkParse", false},
+        {benchResult{pkg: "example.com/m/sub", name: "BenchmarkParse"}, "example.com/m", "BenchmarkParse", false},
+        {benchResult{name: "BenchmarkParse"}, "example.com/m", "BenchmarkParse", true},
+        {benchResult{pkg: "example.com/m", name: "BenchmarkParse"}, "", "BenchmarkParse", true},
+    }
+    for _, c := range cases {
+        if got := c.result.of(c.pkg, c.name); got != c.want {
+            t.Errorf("%s in %q .of(%q, %q) = %v, want %v", c.result.name, c.result.pkg, c.pkg, c.name, got, c.want)
+        }
+    }
+}
+
+func TestIsBenchmarkName(t *testing.T) {
+    for name, want := range map[string]bool{
+        "Benchmark":       true,
+        "BenchmarkParse":  true,
+        "Benchmark_parse": true,
+        "Benchmarkparse":  false,
+        "benchmarkParse":  false,
+        "TestParse":       false,
+    } {
+        if got := isBenchmarkName(name); got != want {
+            t.Errorf("isBenchmarkName(%q) = %v, want %v", name, got, want)
+        }
+    }
+}
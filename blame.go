@@ -0,0 +1,158 @@
+// ### Blame links
+
+// For auditing, `--blame-links` marks each segment with the commit
+// that last touched it, by running `git blame` once per file and
+// taking the newest commit among the segment's lines. Commits link
+// to where `--repo-url`'s host shows them, and lines not committed
+// yet are marked "uncommitted". A file git can't blame, because it
+// isn't in a repository or git isn't installed, is warned about and
+// rendered without the marks.
+
+package main
+
+import (
+    "flag"
+    "net/url"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+var blameLinks bool
+
+func init() {
+    flag.BoolVar(&blameLinks, "blame-links", false, "link each segment to the git commit that last changed it")
+}
+
+// A commit that lines are blamed on.
+type blameCommit struct {
+    hash string
+    time int64
+}
+
+// Find the newest commit behind each of `segs`, from `sourcePath`.
+func blameSegments(sourcePath string, segs []*seg) error {
+    if !blameLinks || sourcePath == "-" || len(segs) == 0 {
+        return nil
+    }
+    lines, err := blame(sourcePath)
+    if err != nil {
+        return report(Diagnostic{Path: sourcePath, Message: "not linking to commits: " + err.Error()})
+    }
+    newestCommits(segs, lines)
+    return nil
+}
+
+// Give each of `segs` the newest of the commits of `lines` it has, or
+// the uncommitted one if any. Each segment runs up to the line before
+// the next one starts.
+func newestCommits(segs []*seg, lines []*blameCommit) {
+    for i, seg := range segs {
+        end := len(lines)
+        if i+1 < len(segs) {
+            end = segs[i+1].line - 1
+        }
+        var newest *blameCommit
+        for n := seg.line; n <= end && n <= len(lines); n++ {
+            c := lines[n-1]
+            if c == nil {
+                continue
+            }
+            if c.uncommitted() {
+                newest = c
+                break
+            }
+            if newest == nil || c.time > newest.time {
+                newest = c
+            }
+        }
+        seg.commit = newest
+    }
+}
+
+// The commit behind each line of `sourcePath`, from
+// `git blame --line-porcelain`.
+func blame(sourcePath string) ([]*blameCommit, error) {
+    abs, err := filepath.Abs(sourcePath)
+    if err != nil {
+        return nil, err
+    }
+    out, err := pipe("git", []string{"-C", filepath.Dir(abs), "blame", "--line-porcelain", "--", filepath.Base(abs)}, "")
+    if err != nil {
+        return nil, err
+    }
+    return parseBlame(out), nil
+}
+
+// Parse `git blame --line-porcelain` output, which repeats a commit's
+// details for every line of it, each headed by the commit's hash, of
+// SHA-1 or SHA-256, and the line's numbers.
+func parseBlame(out string) []*blameCommit {
+    commits := map[string]*blameCommit{}
+    lines := []*blameCommit{}
+    var current *blameCommit
+    for _, line := range strings.Split(out, "\n") {
+        hash := strings.SplitN(line, " ", 2)[0]
+        switch {
+        case strings.HasPrefix(line, "\t"):
+            lines = append(lines, current)
+        case hash != line && (len(hash) == 40 || len(hash) == 64) && isHash(hash):
+            if commits[hash] == nil {
+                commits[hash] = &blameCommit{hash: hash}
+            }
+            current = commits[hash]
+        case current == nil:
+        case strings.HasPrefix(line, "committer-time "):
+            current.time, _ = strconv.ParseInt(line[len("committer-time "):], 10, 64)
+        }
+    }
+    return lines
+}
+
+func isHash(s string) bool {
+    return strings.Trim(s, "0123456789abcdef") == ""
+}
+
+// Whether `c` is what git blames lines not committed yet on, a hash of
+// all zeros.
+func (c *blameCommit) uncommitted() bool {
+    return strings.Trim(c.hash, "0") == ""
+}
+
+// What to show for commit `c`, and where it links to, if anywhere.
+func commitLink(c *blameCommit) (text, link string) {
+    if c == nil {
+        return "", ""
+    }
+    if c.uncommitted() {
+        return "uncommitted", ""
+    }
+    return c.hash[:7], commitURL(c.hash)
+}
+
+// Where `--repo-url`'s host shows the commit `hash`, worked out from
+// how it shows files: `.../blob/main/` on GitHub, `.../-/blob/main/`
+// on GitLab and `.../tree/main/item/` on sourcehut are all next to
+// `.../commit/hash`, and Bitbucket's `.../src/main/` to
+// `.../commits/hash`. It's "" for other hosts.
+func commitURL(hash string) string {
+    if repoURL == "" {
+        return ""
+    }
+    u, err := url.Parse(repoURL)
+    if err != nil {
+        return ""
+    }
+    if i := bitbucketSrc(u); i >= 0 {
+        u.Path, u.RawPath = u.Path[:i]+"/commits/"+hash, ""
+        return u.String()
+    }
+    for _, files := range []string{"/-/blob/", "/blob/", "/tree/"} {
+        if i := strings.Index(u.Path, files); i >= 0 {
+            u.Path = u.Path[:i] + strings.Replace(files, strings.Trim(files, "/-"), "commit", 1) + hash
+            u.RawPath = ""
+            return u.String()
+        }
+    }
+    return ""
+}
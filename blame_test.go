@@ -0,0 +1,84 @@
+package main
+
+import (
+    "reflect"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+const (
+    oldHash    = "1111111111111111111111111111111111111111"
+    newHash    = "2222222222222222222222222222222222222222"
+    sha256Hash = "3333333333333333333333333333333333333333333333333333333333333333"
+    zeroHash   = "0000000000000000000000000000000000000000"
+)
+
+// Porcelain for a line blamed on `hash`, committed at `time`.
+func porcelainLine(hash, time, text string, n int) string {
+    return strings.Join([]string{
+        hash + " " + strconv.Itoa(n) + " " + strconv.Itoa(n) + " 1",
+        "author A",
+        "committer-time " + time,
+        "summary 2222 things " + oldHash,
+        "filename a.go",
+        "\t" + text,
+    }, "\n") + "\n"
+}
+
+func TestParseBlame(t *testing.T) {
+    out := porcelainLine(oldHash, "100", "// Docs.", 1) +
+        porcelainLine(newHash, "200", "package p", 2) +
+        porcelainLine(sha256Hash, "300", "", 3) +
+        porcelainLine(zeroHash, "400", "var x = 1", 4) +
+        porcelainLine(oldHash, "100", "\tindented", 5)
+    got := []string{}
+    for _, c := range parseBlame(out) {
+        got = append(got, c.hash[:4]+" "+strconv.FormatInt(c.time, 10))
+    }
+    if want := []string{"1111 100", "2222 200", "3333 300", "0000 400", "1111 100"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("lines = %q, want %q", got, want)
+    }
+}
+
+// A segment is blamed on the newest commit among its lines, or marked
+// uncommitted if any of them are.
+func TestNewestCommits(t *testing.T) {
+    old, recent, none := &blameCommit{hash: oldHash, time: 100}, &blameCommit{hash: newHash, time: 200}, &blameCommit{hash: zeroHash}
+    lines := []*blameCommit{old, recent, old, old, none, old, old}
+    segs := []*seg{{line: 1}, {line: 3}, {line: 5}, {line: 6}}
+    newestCommits(segs, lines)
+    got := []string{}
+    for _, seg := range segs {
+        text, _ := commitLink(seg.commit)
+        got = append(got, text)
+    }
+    if want := []string{"2222222", "1111111", "uncommitted", "1111111"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("commits = %q, want %q", got, want)
+    }
+}
+
+func TestCommitLink(t *testing.T) {
+    defer func(saved string) { repoURL = saved }(repoURL)
+    cases := []struct {
+        repo       string
+        c          *blameCommit
+        text, link string
+    }{
+        {"", &blameCommit{hash: newHash}, "2222222", ""},
+        {"https://github.com/me/proj/blob/main/", &blameCommit{hash: newHash}, "2222222", "https://github.com/me/proj/commit/" + newHash},
+        {"https://gitlab.com/me/proj/-/blob/main/", &blameCommit{hash: sha256Hash}, "3333333", "https://gitlab.com/me/proj/-/commit/" + sha256Hash},
+        {"https://git.sr.ht/~me/proj/tree/main/item/", &blameCommit{hash: newHash}, "2222222", "https://git.sr.ht/~me/proj/commit/" + newHash},
+        {"https://bitbucket.org/me/proj/src/main/", &blameCommit{hash: newHash}, "2222222", "https://bitbucket.org/me/proj/commits/" + newHash},
+        {"https://example.com/files/", &blameCommit{hash: newHash}, "2222222", ""},
+        {"https://github.com/me/proj/blob/main/", &blameCommit{hash: zeroHash}, "uncommitted", ""},
+        {"https://github.com/me/proj/blob/main/", &blameCommit{hash: strings.Repeat("0", 64)}, "uncommitted", ""},
+        {"https://github.com/me/proj/blob/main/", nil, "", ""},
+    }
+    for _, c := range cases {
+        repoURL = c.repo
+        if text, link := commitLink(c.c); text != c.text || link != c.link {
+            t.Errorf("with --repo-url %q, commitLink(%v) = %q, %q; want %q, %q", c.repo, c.c, text, link, c.text, c.link)
+        }
+    }
+}
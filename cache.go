@@ -0,0 +1,169 @@
+// ### Build cache
+
+// Most of the time in a build goes to the `markdown` and `pygmentize`
+// subprocesses, so re-rendering a whole module because one file
+// changed is wasteful. A multi-file build keeps `.golit-cache.json` in
+// the output directory, recording a hash of each source's contents
+// along with a hash of the options it was built with, and skips files
+// whose hashes match and whose pages are still there. The options
+// hash covers the flags, the stylesheets, the page template, any
+// extra head markup, the set of pages in the site and the revision of
+// the sources, since any of those can change every page. With
+// `--type-info` a Go file's hash covers the other files of its package
+// too, whose declarations its page shows. `--force` ignores the cache,
+// as does `--blame-links`, since a commit changes pages without
+// changing their sources. A cache we can't read is treated as empty,
+// so the worst it can do is cost us a full rebuild.
+
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+)
+
+var force bool
+
+func init() {
+    flag.BoolVar(&force, "force", false, "rebuild every page, ignoring the build cache")
+}
+
+var cacheName = ".golit-cache.json"
+
+// What the cache remembers about one source: the hashes it was built
+// with, and enough about the page to index it without rebuilding.
+type cacheEntry struct {
+    Hash     string          `json:"hash"`
+    Options  string          `json:"options"`
+    Output   string          `json:"output"`
+    Title    string          `json:"title"`
+    Pkg      string          `json:"pkg,omitempty"`
+    Summary  string          `json:"summary,omitempty"`
+    Test     bool            `json:"test,omitempty"`
+    Document bool            `json:"document,omitempty"`
+    Search   []searchSegment `json:"search,omitempty"`
+}
+
+// Flags that don't change what a page looks like, and so needn't
+// invalidate it.
+var uncachedFlags = map[string]bool{
+    "assets": true, "bind": true, "config": true, "force": true,
+    "jobs": true, "serve": true, "v": true, "verbose": true,
+    "version": true, "watch": true,
+}
+
+// Hash everything besides its own source that goes into a page.
+func optionsHash(sources []string, title, css string) string {
+    h := sha256.New()
+    fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00", version(), title, css, siteName, templateText, headHTML, revisionKey(), coverageKey()+benchResultsKey()+mermaidKey()+katexKey())
+    flag.VisitAll(func(f *flag.Flag) {
+        if !uncachedFlags[f.Name] {
+            fmt.Fprintf(h, "%s=%s\x00", f.Name, f.Value)
+        }
+    })
+    for _, sourcePath := range sources {
+        fmt.Fprintf(h, "%s\x00", sourcePath)
+    }
+    return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func contentHash(path string) (string, error) {
+    src, err := ioutil.ReadFile(path)
+    if err != nil {
+        return "", err
+    }
+    return fmt.Sprintf("%x", sha256.Sum256(src)), nil
+}
+
+// The hash of `sourcePath` for the cache. With `--type-info` a Go
+// file's page depends on the rest of its package too, so the other Go
+// files beside it count as well.
+func sourceHash(sourcePath string) (string, error) {
+    hash, err := contentHash(sourcePath)
+    if err != nil || !typeInfo || filepath.Ext(sourcePath) != ".go" {
+        return hash, err
+    }
+    h := sha256.New()
+    fmt.Fprintf(h, "%s\x00", hash)
+    for _, path := range packageMates(sourcePath) {
+        other, err := contentHash(path)
+        if err != nil {
+            return "", err
+        }
+        fmt.Fprintf(h, "%s\x00%s\x00", filepath.Base(path), other)
+    }
+    return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// Read the cache for the site in `outDir`, or an empty one if it's
+// missing, unreadable, or `--force` was given.
+func loadCache() map[string]cacheEntry {
+    cache := map[string]cacheEntry{}
+    if force || blameLinks {
+        return cache
+    }
+    data, err := ioutil.ReadFile(filepath.Join(outDir, cacheName))
+    if err != nil {
+        return cache
+    }
+    if err := json.Unmarshal(data, &cache); err != nil {
+        verbosef("ignoring build cache: %v", err)
+        return map[string]cacheEntry{}
+    }
+    return cache
+}
+
+func saveCache(cache map[string]cacheEntry) error {
+    data, err := json.MarshalIndent(cache, "", "  ")
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(filepath.Join(outDir, cacheName), data)
+}
+
+// Look up `sourcePath` in the cache, returning its page if it can be
+// reused as is. Either way the source's current hash comes back, for
+// recording once it's been built.
+func cached(cache map[string]cacheEntry, sourcePath, outPath, options string) (pageInfo, string, bool) {
+    hash, err := sourceHash(sourcePath)
+    if err != nil {
+        return pageInfo{}, "", false
+    }
+    entry, ok := cache[sourcePath]
+    if !ok || entry.Hash != hash || entry.Options != options || entry.Output != outPath {
+        return pageInfo{}, hash, false
+    }
+    if _, err := os.Stat(outPath); err != nil {
+        return pageInfo{}, hash, false
+    }
+    info := pageInfo{
+        source:   sourcePath,
+        output:   outPath,
+        title:    entry.Title,
+        pkg:      entry.Pkg,
+        summary:  entry.Summary,
+        test:     entry.Test,
+        document: entry.Document,
+        search:   entry.Search,
+    }
+    return info, hash, true
+}
+
+func cacheEntryFor(info pageInfo, hash, options string) cacheEntry {
+    return cacheEntry{
+        Hash:     hash,
+        Options:  options,
+        Output:   info.output,
+        Title:    info.title,
+        Pkg:      info.pkg,
+        Summary:  info.summary,
+        Test:     info.test,
+        Document: info.document,
+        Search:   info.search,
+    }
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+    "flag"
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// With `--type-info`, changing one file of a package rebuilds the pages
+// of the others, which show its types.
+func TestCacheTypeInfo(t *testing.T) {
+    dir := writeFiles(t, map[string]string{
+        "a.go": "// Uses.\npackage p\n\nvar x = y\n",
+        "b.go": "// Declares.\npackage p\n\nvar y int\n",
+    })
+    build := func() string {
+        t.Helper()
+        if _, stderr, code := runGolit(t, dir, "--type-info", "--out-dir", "out", "a.go", "b.go"); code != 0 {
+            t.Fatalf("exit %d: %s", code, stderr)
+        }
+        page, err := ioutil.ReadFile(filepath.Join(dir, "out", "a.html"))
+        if err != nil {
+            t.Fatal(err)
+        }
+        return string(page)
+    }
+    if page := build(); !strings.Contains(page, `title="var y int"`) {
+        t.Fatalf("a.html doesn't show y's type:\n%s", page)
+    }
+    if err := ioutil.WriteFile(filepath.Join(dir, "b.go"), []byte("// Declares.\npackage p\n\nvar y string\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if page := build(); !strings.Contains(page, `title="var y string"`) {
+        t.Errorf("a.html wasn't rebuilt for the change to b.go:\n%s", page)
+    }
+}
+
+// How many jobs render a build doesn't change its pages, so a cache
+// made on a machine with more CPUs still holds on one with fewer.
+func TestOptionsHashJobs(t *testing.T) {
+    saved := flag.Lookup("jobs").Value.String()
+    defer flag.Set("jobs", saved)
+    hashes := map[string]bool{}
+    for _, jobs := range []string{"1", "4", "64"} {
+        if err := flag.Set("jobs", jobs); err != nil {
+            t.Fatal(err)
+        }
+        hashes[optionsHash([]string{"a.go"}, "T", "")] = true
+    }
+    if len(hashes) != 1 {
+        t.Errorf("--jobs changes the options hash")
+    }
+    defer func(saved string) { layout = saved }(layout)
+    layout = "linear"
+    if hashes[optionsHash([]string{"a.go"}, "T", "")] {
+        t.Errorf("--layout doesn't change the options hash")
+    }
+}
@@ -0,0 +1,97 @@
+// ### Highlighting
+
+// Code is highlighted with chroma, a Go port of Pygments, so golit
+// doesn't need Python installed either. We write chroma's tokens out
+// the way Pygments' HTML formatter does, with the same short class
+// names and wrapper, so the docco stylesheet applies unchanged. Those
+// who want Pygments' exact output can still ask for it with
+// `--pygmentize-bin` or `GOLIT_PYGMENTIZE`.
+
+package main
+
+import (
+    "fmt"
+    "strings"
+    "sync"
+
+    "github.com/alecthomas/chroma/v2"
+    "github.com/alecthomas/chroma/v2/lexers"
+)
+
+// Highlights code in process.
+type chromaRenderer struct{}
+
+func (chromaRenderer) RenderCode(lang, src string) (string, error) {
+    return highlightChroma(lang, src)
+}
+
+// Pygments escapes quotes by name, where `html.EscapeString` wouldn't.
+var pygmentsEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&#39;")
+
+// Highlight `src` with chroma's lexer for `lang`. Without one we can
+// still show the code, escaped as plain text, and say so once.
+func highlightChroma(lang, src string) (string, error) {
+    lexer := lexers.Get(lang)
+    if lexer == nil {
+        if err := warnNoLexer(lang); err != nil {
+            return "", err
+        }
+        return plainRenderer{}.RenderCode(lang, src)
+    }
+    // Like Pygments, drop newlines at either end and then end with
+    // exactly one.
+    src = strings.Trim(src, "\n") + "\n"
+    tokens, err := chroma.Tokenise(chroma.Coalesce(lexer), nil, src)
+    if err != nil {
+        return "", err
+    }
+
+    var out strings.Builder
+    out.WriteString(`<div class="highlight"><pre><span></span>`)
+    for _, token := range tokens {
+        class := tokenClass(token.Type)
+        // Spans are closed at the end of every line, as in Pygments.
+        for i, part := range strings.Split(token.Value, "\n") {
+            if i > 0 {
+                out.WriteString("\n")
+            }
+            if part == "" {
+                continue
+            }
+            if class == "" {
+                out.WriteString(pygmentsEscaper.Replace(part))
+            } else {
+                fmt.Fprintf(&out, `<span class="%s">%s</span>`, class, pygmentsEscaper.Replace(part))
+            }
+        }
+    }
+    out.WriteString("</pre></div>\n")
+    return out.String(), nil
+}
+
+// The lexers we've already warned about.
+var noLexers = struct {
+    sync.Mutex
+    warned map[string]bool
+}{warned: map[string]bool{}}
+
+func warnNoLexer(lang string) error {
+    noLexers.Lock()
+    defer noLexers.Unlock()
+    if noLexers.warned[lang] && !strict {
+        return nil
+    }
+    noLexers.warned[lang] = true
+    return warn("no highlighter for %s code, showing it as plain text", lang)
+}
+
+// The Pygments short class name for a token type, falling back to its
+// broader types for ones Pygments doesn't name.
+func tokenClass(t chroma.TokenType) string {
+    for _, t := range []chroma.TokenType{t, t.SubCategory(), t.Category()} {
+        if class, ok := chroma.StandardTypes[t]; ok {
+            return class
+        }
+    }
+    return ""
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+    "io/ioutil"
+    "os/exec"
+    "strings"
+    "testing"
+)
+
+func TestChroma(t *testing.T) {
+    src, err := ioutil.ReadFile("testdata/chroma/snippet.go")
+    if err != nil {
+        t.Fatal(err)
+    }
+    got, err := chromaRenderer{}.RenderCode("go", string(src))
+    if err != nil {
+        t.Fatal(err)
+    }
+    golden(t, "testdata/chroma/snippet.html", got)
+}
+
+// Chroma's output can stand in for Pygments', with the same wrapper
+// and the same text. The lexers differ on a few tokens, so not always
+// the same classes.
+func TestChromaLikePygments(t *testing.T) {
+    path, err := exec.LookPath("pygmentize")
+    if err != nil {
+        t.Skip("no pygmentize")
+    }
+    src := "func main() {\n    fmt.Println(\"hi\", 42) // done\n}\n"
+    got, err := chromaRenderer{}.RenderCode("go", src)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want, err := pygmentizeCommand(path).RenderCode("go", src)
+    if err != nil {
+        t.Fatal(err)
+    }
+    text := func(out string) string {
+        return inlineTagPat.ReplaceAllString(strings.TrimPrefix(out, highlightPat.FindStringSubmatch(out)[1]), "")
+    }
+    if !highlightPat.MatchString(got) || text(got) != text(want) {
+        t.Errorf("chroma gave\n%s\nand Pygments\n%s", got, want)
+    }
+}
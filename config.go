@@ -0,0 +1,118 @@
+// ### Configuration
+
+// Project-wide defaults can live in a `.golit.json` file instead of
+// being repeated on every command line. Its keys are simply the long
+// names of golit's flags:
+//
+//     {
+//       "css": ["docs/style.css"],
+//       "lexer": "go",
+//       "remote-css": false
+//     }
+//
+// List values are applied one at a time, as though the flag had been
+// repeated. Anything given on the command line wins over the config.
+
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sort"
+)
+
+// The config file name we look for, and the `--config` flag that
+// names a specific file instead.
+var configName = ".golit.json"
+
+var configPath string
+
+func init() {
+    flag.StringVar(&configPath, "config", "", "read defaults from this JSON `file` (default ./.golit.json or next to the source)")
+}
+
+// Find the config file to use: the one named with `--config`, else
+// `.golit.json` in the working directory, else one next to the source
+// file. Returns "" when there's none.
+func findConfig(sourcePath string) string {
+    if configPath != "" {
+        return configPath
+    }
+    candidates := []string{configName}
+    if sourcePath != "-" {
+        candidates = append(candidates, filepath.Join(filepath.Dir(sourcePath), configName))
+    }
+    for _, candidate := range candidates {
+        if _, err := os.Stat(candidate); err == nil {
+            return candidate
+        }
+    }
+    return ""
+}
+
+// Read the config file at `path` and apply its settings to any flags
+// not already set on the command line. Errors name the file and, where
+// it's to blame, the offending key.
+func loadConfig(path string) error {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    settings := map[string]interface{}{}
+    decoder := json.NewDecoder(bytes.NewReader(data))
+    decoder.UseNumber()
+    if err := decoder.Decode(&settings); err != nil {
+        if syntaxErr, ok := err.(*json.SyntaxError); ok {
+            return fmt.Errorf("%s: invalid JSON at offset %d: %v", path, syntaxErr.Offset, err)
+        }
+        return fmt.Errorf("%s: %v", path, err)
+    }
+    // Aliases like -o and --output share a value, so setting either on
+    // the command line counts for both.
+    given := map[flag.Value]bool{}
+    flag.Visit(func(f *flag.Flag) {
+        given[f.Value] = true
+    })
+    keys := []string{}
+    for key := range settings {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    for _, key := range keys {
+        if err := applySetting(key, settings[key], given); err != nil {
+            return fmt.Errorf("%s: key %q: %v", path, key, err)
+        }
+    }
+    return nil
+}
+
+// Apply a single config setting to the flag of the same name.
+func applySetting(key string, value interface{}, given map[flag.Value]bool) error {
+    f := flag.Lookup(key)
+    if f == nil || key == "config" || len(key) == 1 {
+        return fmt.Errorf("unknown setting")
+    }
+    if given[f.Value] {
+        return nil
+    }
+    values, ok := value.([]interface{})
+    if !ok {
+        values = []interface{}{value}
+    }
+    for _, v := range values {
+        switch v.(type) {
+        case string, bool, json.Number:
+        default:
+            return fmt.Errorf("expected a string, number, boolean, or list of those")
+        }
+        if err := f.Value.Set(fmt.Sprint(v)); err != nil {
+            return fmt.Errorf("invalid value %q: %v", fmt.Sprint(v), err)
+        }
+    }
+    return nil
+}
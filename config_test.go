@@ -0,0 +1,41 @@
+package main
+
+import (
+    "flag"
+    "io/ioutil"
+    "path/filepath"
+    "testing"
+)
+
+// A flag given on the command line under one name beats the config
+// file's setting under another, like -o and "output".
+func TestConfigAliasGiven(t *testing.T) {
+    defer func(saved string) { outputPath = saved }(outputPath)
+    path := filepath.Join(t.TempDir(), configName)
+    if err := ioutil.WriteFile(path, []byte(`{"output": "cfg.html"}`), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := flag.CommandLine.Parse([]string{"-o", "cli.html"}); err != nil {
+        t.Fatal(err)
+    }
+    if err := loadConfig(path); err != nil {
+        t.Fatal(err)
+    }
+    if outputPath != "cli.html" {
+        t.Errorf("outputPath = %q, want cli.html", outputPath)
+    }
+}
+
+func TestConfigErrorsNameKey(t *testing.T) {
+    path := filepath.Join(t.TempDir(), configName)
+    if err := ioutil.WriteFile(path, []byte(`{"no-such-flag": true}`), 0644); err != nil {
+        t.Fatal(err)
+    }
+    err := loadConfig(path)
+    if err == nil {
+        t.Fatal("loadConfig succeeded with an unknown key")
+    }
+    if want := path + `: key "no-such-flag": unknown setting`; err.Error() != want {
+        t.Errorf("error = %q, want %q", err, want)
+    }
+}
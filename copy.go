@@ -0,0 +1,44 @@
+// ### Copy buttons
+
+// Readers copy snippets out of pages all the time, and selecting
+// code in a table cell drags the docs beside it along as often as
+// not. So each segment's code gets a small "copy" button that puts
+// its source, as it is in the file rather than as highlighted, on the
+// clipboard. The source goes in the button's `data-code`, and a few
+// lines of script inlined into the page do the copying, so pages stay
+// self-contained. Buttons are `hidden` until the script shows them,
+// so without scripts there's nothing that doesn't work.
+// `--no-copy-buttons` leaves them out, as does `--no-js`.
+
+package main
+
+import (
+    _ "embed"
+    "flag"
+    "strings"
+)
+
+var noCopyButtons bool
+
+func init() {
+    flag.BoolVar(&noCopyButtons, "no-copy-buttons", false, "leave out the buttons that copy each segment's code")
+}
+
+//go:embed resources/copy.js
+var copyScript string
+
+// The source a segment's button copies, or "" for no button.
+func copyCode(seg *seg) string {
+    if noCopyButtons || noJS || seg.wide || strings.TrimSpace(seg.code) == "" {
+        return ""
+    }
+    return strings.Trim(seg.code, "\n")
+}
+
+// The script for the buttons, unless they're left out.
+func copyScripts() string {
+    if noCopyButtons || noJS {
+        return ""
+    }
+    return "<script>\n" + copyScript + "</script>\n"
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestCopyCode(t *testing.T) {
+    defer func(saved bool) { noCopyButtons = saved }(noCopyButtons)
+    defer func(saved bool) { noJS = saved }(noJS)
+    code := &seg{code: "\nx := 1\n\n"}
+    cases := []struct {
+        name            string
+        seg             *seg
+        noButtons, noJS bool
+        want            string
+    }{
+        {"code", code, false, false, "x := 1"},
+        {"blank", &seg{code: "\n\n"}, false, false, ""},
+        {"wide", &seg{code: "x := 1", wide: true}, false, false, ""},
+        {"no-copy-buttons", code, true, false, ""},
+        {"no-js", code, false, true, ""},
+    }
+    for _, c := range cases {
+        noCopyButtons, noJS = c.noButtons, c.noJS
+        if got := copyCode(c.seg); got != c.want {
+            t.Errorf("%s: copyCode = %q, want %q", c.name, got, c.want)
+        }
+        if got := copyScripts() != ""; got != (!c.noButtons && !c.noJS) {
+            t.Errorf("%s: copyScripts() != \"\" is %v", c.name, got)
+        }
+    }
+}
+
+// `--no-js` leaves out the buttons, not just the script that would
+// show them.
+func TestNoJSCopyButtons(t *testing.T) {
+    dir := writeFiles(t, map[string]string{"a.go": "// Docs.\npackage a\n"})
+    for _, c := range []struct {
+        flag string
+        want bool
+    }{{"--remote-css", true}, {"--no-js", false}, {"--no-copy-buttons", false}} {
+        stdout, stderr, code := runGolit(t, dir, "--remote-css", c.flag, "a.go")
+        if code != 0 {
+            t.Fatalf("%s: exit %d: %s", c.flag, code, stderr)
+        }
+        if got := strings.Contains(stdout, `<button class="copy"`); got != c.want {
+            t.Errorf("%s: copy button = %v, want %v", c.flag, got, c.want)
+        }
+    }
+}
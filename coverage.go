@@ -0,0 +1,214 @@
+// ### Test coverage
+
+// With `--coverprofile`, given the profile `go test -coverprofile`
+// writes, the lines of code the tests ran are tinted green and those
+// they didn't red, and each page's badges say how much of its file's
+// statements ran, as `go tool cover -func` counts them. The profile's
+// blocks run from column to column, and can start partway along a line
+// another ends on, so a line counts as run if any block on it ran: the
+// line with an `if` ran even if its body didn't. Blocks don't care
+// where segments start, so each segment takes just its own lines. Files
+// the profile doesn't mention render as they would without it.
+
+package main
+
+import (
+    "bufio"
+    "crypto/sha256"
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+var coverProfile string
+
+func init() {
+    flag.StringVar(&coverProfile, "coverprofile", "", "tint lines of Go code by whether the tests behind the coverage `profile`, from go test -coverprofile, ran them")
+}
+
+// A block of statements from a profile, the lines it's on, and how
+// many times it ran.
+type coverBlock struct {
+    startLine, endLine int
+    stmts, count       int
+}
+
+// The blocks of the profile, by the file name it gives, which is the
+// file's import path and base name, and a hash of it for the cache.
+var (
+    coverBlocks = map[string][]coverBlock{}
+    coverHash   string
+)
+
+// Read the profile named by `--coverprofile`, if any.
+func loadCoverProfile() error {
+    if coverProfile == "" {
+        return nil
+    }
+    f, err := os.Open(coverProfile)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    h := sha256.New()
+    // The same block appears once for each test binary that covered
+    // its package, so they're added up.
+    seen := map[string]int{}
+    scanner := bufio.NewScanner(f)
+    for n := 1; scanner.Scan(); n++ {
+        line := strings.TrimSpace(scanner.Text())
+        fmt.Fprintf(h, "%s\n", line)
+        if line == "" || (n == 1 && strings.HasPrefix(line, "mode:")) {
+            continue
+        }
+        name, block, err := parseCoverLine(line)
+        if err != nil {
+            return fmt.Errorf("%s:%d: %v", coverProfile, n, err)
+        }
+        fields := strings.Fields(line)
+        key := strings.Join(fields[:len(fields)-2], " ")
+        if i, ok := seen[key]; ok {
+            coverBlocks[name][i].count += block.count
+            continue
+        }
+        seen[key] = len(coverBlocks[name])
+        coverBlocks[name] = append(coverBlocks[name], block)
+    }
+    if err := scanner.Err(); err != nil {
+        return err
+    }
+    coverHash = fmt.Sprintf("%x", h.Sum(nil))
+    return nil
+}
+
+// Parse a line of a profile, like
+// `example.com/m/a.go:10.2,12.16 2 1`.
+func parseCoverLine(line string) (string, coverBlock, error) {
+    colon := strings.LastIndexByte(line, ':')
+    fields := strings.Fields(line[colon+1:])
+    if colon < 0 || len(fields) != 3 {
+        return "", coverBlock{}, fmt.Errorf("not a coverage block: %q", line)
+    }
+    span := strings.Split(fields[0], ",")
+    if len(span) != 2 {
+        return "", coverBlock{}, fmt.Errorf("not a coverage block: %q", line)
+    }
+    start, end := strings.SplitN(span[0], ".", 2), strings.SplitN(span[1], ".", 2)
+    if len(start) != 2 || len(end) != 2 {
+        return "", coverBlock{}, fmt.Errorf("not a coverage block: %q", line)
+    }
+    numbers := []int{}
+    for _, s := range []string{start[0], end[0], end[1], fields[1], fields[2]} {
+        n, err := strconv.Atoi(s)
+        if err != nil || n < 0 {
+            return "", coverBlock{}, fmt.Errorf("not a coverage block: %q", line)
+        }
+        numbers = append(numbers, n)
+    }
+    if numbers[1] < numbers[0] {
+        return "", coverBlock{}, fmt.Errorf("block ends before it starts: %q", line)
+    }
+    // A block ending at the first column ends with the line before.
+    if numbers[2] <= 1 && numbers[1] > numbers[0] {
+        numbers[1]--
+    }
+    return line[:colon], coverBlock{numbers[0], numbers[1], numbers[3], numbers[4]}, nil
+}
+
+// The profile as it goes into the options hash, so that pages are
+// rebuilt when it changes.
+func coverageKey() string {
+    return coverHash
+}
+
+// The profile's blocks for the file at `sourcePath`, found by its
+// import path in the module it's in, or else by the end of its path.
+func fileCoverBlocks(sourcePath string) []coverBlock {
+    if len(coverBlocks) == 0 || filepath.Ext(sourcePath) != ".go" {
+        return nil
+    }
+    abs, err := filepath.Abs(sourcePath)
+    if err != nil {
+        return nil
+    }
+    if pkg := importPath(filepath.Dir(abs)); pkg != "" {
+        if blocks, ok := coverBlocks[pkg+"/"+filepath.Base(abs)]; ok {
+            return blocks
+        }
+    }
+    slashed := filepath.ToSlash(abs)
+    for name, blocks := range coverBlocks {
+        if slashed == name || strings.HasSuffix(slashed, "/"+name) {
+            return blocks
+        }
+    }
+    return nil
+}
+
+// Whether each line of the file at `sourcePath` that has statements
+// on it ran, or nil if the profile doesn't have it.
+func coveredLines(sourcePath string) map[int]bool {
+    blocks := fileCoverBlocks(sourcePath)
+    if blocks == nil {
+        return nil
+    }
+    lines := map[int]bool{}
+    for _, b := range blocks {
+        if b.stmts == 0 {
+            continue
+        }
+        for n := b.startLine; n <= b.endLine; n++ {
+            lines[n] = lines[n] || b.count > 0
+        }
+    }
+    return lines
+}
+
+// Tint the lines of `seg`'s highlighted code by whether they ran, as
+// `lines` has it. Highlighters' spans are closed and opened again
+// around each line the way line numbers need them to be.
+func addCoverage(seg *seg, lines map[int]bool) {
+    if len(lines) == 0 || seg.codeLine == 0 || seg.codeRendered == "" {
+        return
+    }
+    wrapper := highlightPat.FindStringSubmatch(seg.codeRendered)
+    if wrapper == nil {
+        return
+    }
+    code := strings.Split(wrapper[2], "\n")
+    open := []string{}
+    for i, line := range code {
+        reopened := strings.Join(open, "")
+        open = openSpans(open, line)
+        code[i] = reopened + line + strings.Repeat("</span>", len(open))
+        if ran, ok := lines[seg.codeLine+i]; ok && i < seg.codeLines {
+            class := "uncovered"
+            if ran {
+                class = "covered"
+            }
+            code[i] = `<span class="` + class + `">` + code[i] + "</span>"
+        }
+    }
+    seg.codeRendered = wrapper[1] + strings.Join(code, "\n") + wrapper[3]
+}
+
+// The badge with how much of the file at `sourcePath` its tests ran,
+// or "" if the profile doesn't have it.
+func coverageBadge(sourcePath string) string {
+    blocks := fileCoverBlocks(sourcePath)
+    total, ran := 0, 0
+    for _, b := range blocks {
+        total += b.stmts
+        if b.count > 0 {
+            ran += b.stmts
+        }
+    }
+    if total == 0 {
+        return ""
+    }
+    percent := 100 * float64(ran) / float64(total)
+    return fmt.Sprintf("<span class=\"badge coverage\" title=\"%d of %d statements run by tests\">%.1f%% covered</span>", ran, total, percent)
+}
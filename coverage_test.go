@@ -0,0 +1,121 @@
+package main
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestParseCoverLine(t *testing.T) {
+    cases := []struct {
+        line, name string
+        block      coverBlock
+        err        string
+    }{
+        {"example.com/m/a.go:10.2,12.16 2 1", "example.com/m/a.go", coverBlock{10, 12, 2, 1}, ""},
+        {"example.com/m/a.go:10.2,12.1 2 0", "example.com/m/a.go", coverBlock{10, 11, 2, 0}, ""},
+        {"example.com/m/a.go:10.2,10.1 1 0", "example.com/m/a.go", coverBlock{10, 10, 1, 0}, ""},
+        {`C:\m\a.go:1.1,2.3 1 1`, `C:\m\a.go`, coverBlock{1, 2, 1, 1}, ""},
+        {"example.com/m/a.go:12.2,10.16 2 1", "", coverBlock{}, `block ends before it starts: "example.com/m/a.go:12.2,10.16 2 1"`},
+        {"example.com/m/a.go:10.2,12.16 2", "", coverBlock{}, `not a coverage block: "example.com/m/a.go:10.2,12.16 2"`},
+        {"example.com/m/a.go:10,12 2 1", "", coverBlock{}, `not a coverage block: "example.com/m/a.go:10,12 2 1"`},
+        {"example.com/m/a.go:10.2,12.16 2 -1", "", coverBlock{}, `not a coverage block: "example.com/m/a.go:10.2,12.16 2 -1"`},
+        {"no colon here", "", coverBlock{}, `not a coverage block: "no colon here"`},
+    }
+    for _, c := range cases {
+        name, block, err := parseCoverLine(c.line)
+        if c.err != "" {
+            if err == nil || err.Error() != c.err {
+                t.Errorf("parseCoverLine(%q) error = %v, want %s", c.line, err, c.err)
+            }
+            continue
+        }
+        if err != nil || name != c.name || block != c.block {
+            t.Errorf("parseCoverLine(%q) = %q, %+v, %v; want %q, %+v", c.line, name, block, err, c.name, c.block)
+        }
+    }
+}
+
+// A block covered by more than one test binary is listed once for
+// each, and its counts add up.
+func TestLoadCoverProfile(t *testing.T) {
+    defer func(profile string, blocks map[string][]coverBlock, hash string) {
+        coverProfile, coverBlocks, coverHash = profile, blocks, hash
+    }(coverProfile, coverBlocks, coverHash)
+    coverProfile, coverBlocks = "testdata/coverage/cover.out", map[string][]coverBlock{}
+    if err := loadCoverProfile(); err != nil {
+        t.Fatal(err)
+    }
+    want := map[string][]coverBlock{
+        "example.com/m/a.go":     {{3, 5, 1, 5}, {7, 8, 2, 0}},
+        "example.com/m/sub/b.go": {{4, 4, 1, 1}},
+    }
+    if !reflect.DeepEqual(coverBlocks, want) {
+        t.Errorf("blocks = %+v, want %+v", coverBlocks, want)
+    }
+    if coverageKey() == "" {
+        t.Error("no hash of the profile for the cache")
+    }
+    coverProfile, coverBlocks = "coverage_test.go", map[string][]coverBlock{}
+    if err := loadCoverProfile(); err == nil {
+        t.Error("loadCoverProfile read a file that isn't a profile")
+    }
+}
+
+func TestCoveredLines(t *testing.T) {
+    defer func(saved map[string][]coverBlock) { coverBlocks = saved }(coverBlocks)
+    // A block ending at the first column of a line doesn't cover it.
+    _, endsAtColumnOne, err := parseCoverLine("m/a.go:20.2,22.1 1 1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    cases := []struct {
+        name   string
+        blocks []coverBlock
+        want   map[int]bool
+    }{
+        {"blocks to lines", []coverBlock{{3, 5, 1, 5}, {7, 8, 2, 0}, {10, 10, 0, 1}},
+            map[int]bool{3: true, 4: true, 5: true, 7: false, 8: false}},
+        {"ending at column 1", []coverBlock{endsAtColumnOne},
+            map[int]bool{20: true, 21: true}},
+        {"covered over uncovered", []coverBlock{{3, 3, 1, 1}, {3, 5, 2, 0}},
+            map[int]bool{3: true, 4: false, 5: false}},
+        {"uncovered over covered", []coverBlock{{3, 5, 2, 0}, {3, 3, 1, 1}},
+            map[int]bool{3: true, 4: false, 5: false}},
+    }
+    for _, c := range cases {
+        coverBlocks = map[string][]coverBlock{"m/a.go": c.blocks}
+        if got := coveredLines("/src/m/a.go"); !reflect.DeepEqual(got, c.want) {
+            t.Errorf("%s: lines = %v, want %v", c.name, got, c.want)
+        }
+    }
+    if got := coveredLines("/src/m/other.go"); got != nil {
+        t.Errorf("lines of a file the profile doesn't have = %v, want nil", got)
+    }
+}
+
+// A block spanning two segments tints each one's own lines, and a
+// span across lines is closed and opened again around each.
+func TestAddCoverage(t *testing.T) {
+    lines := map[int]bool{3: true, 4: true, 5: true, 6: false}
+    first := &seg{codeLine: 3, codeLines: 2,
+        codeRendered: "<div class=\"highlight\"><pre><span></span>a\nb\n</pre></div>\n"}
+    second := &seg{codeLine: 5, codeLines: 2,
+        codeRendered: "<div class=\"highlight\"><pre><span class=\"s\">`c\nd`</span>\n</pre></div>\n"}
+    addCoverage(first, lines)
+    addCoverage(second, lines)
+    if want := "<div class=\"highlight\"><pre><span></span>" +
+        "<span class=\"covered\">a</span>\n<span class=\"covered\">b</span>\n</pre></div>\n"; first.codeRendered != want {
+        t.Errorf("first segment:\n%s\nwant:\n%s", first.codeRendered, want)
+    }
+    if want := "<div class=\"highlight\"><pre>" +
+        "<span class=\"covered\"><span class=\"s\">`c</span></span>\n" +
+        "<span class=\"uncovered\"><span class=\"s\">d`</span></span>\n</pre></div>\n"; second.codeRendered != want {
+        t.Errorf("second segment:\n%s\nwant:\n%s", second.codeRendered, want)
+    }
+
+    docs := &seg{codeRendered: "<div class=\"highlight\"><pre>a\n</pre></div>\n"}
+    addCoverage(docs, lines)
+    if docs.codeRendered != "<div class=\"highlight\"><pre>a\n</pre></div>\n" {
+        t.Errorf("segment with no code line tinted: %s", docs.codeRendered)
+    }
+}
@@ -0,0 +1,33 @@
+// ### Deprecation notices
+
+// Go marks what's deprecated with a paragraph of its doc comment
+// starting `Deprecated:`, which is easy to miss in the middle of the
+// docs. We set such paragraphs apart as callouts with a badge, and
+// tag deprecated declarations in the symbols menu and the API
+// appendix too. As `go/doc` has it, only a paragraph that starts that
+// way counts, not the word anywhere else.
+
+package main
+
+import (
+    "go/ast"
+    "regexp"
+)
+
+const deprecatedBadge = `<span class="badge deprecated">Deprecated</span>`
+
+var (
+    deprecatedDocPat  = regexp.MustCompile(`(^|\n\n)Deprecated: `)
+    deprecatedHTMLPat = regexp.MustCompile(`<p>Deprecated:\s`)
+)
+
+// Whether the doc comment `doc` says what it's on is deprecated.
+func isDeprecated(doc *ast.CommentGroup) bool {
+    return doc != nil && deprecatedDocPat.MatchString(doc.Text())
+}
+
+// Set the paragraphs of the rendered docs `rendered` that start with
+// `Deprecated:` apart, with the badge in place of the word.
+func markDeprecated(rendered string) string {
+    return deprecatedHTMLPat.ReplaceAllString(rendered, `<p class="deprecated">`+deprecatedBadge+" ")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+    "go/parser"
+    "go/token"
+    "strings"
+    "testing"
+)
+
+func TestIsDeprecated(t *testing.T) {
+    cases := []struct {
+        doc  string
+        want bool
+    }{
+        {"// Deprecated: use G.", true},
+        {"// F does things.\n//\n// Deprecated: use G.", true},
+        {"// F does things.\n//\n// Deprecated: use G.\n//\n// More.", true},
+        {"// F is not Deprecated: really.", false},
+        {"// F does things.\n// Deprecated: in the same paragraph.", false},
+        {"// Deprecated\n// without the colon.", false},
+    }
+    for _, c := range cases {
+        file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n\n"+c.doc+"\nfunc F() {}\n", parser.ParseComments)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := isDeprecated(file.Comments[0]); got != c.want {
+            t.Errorf("isDeprecated(%q) = %v, want %v", c.doc, got, c.want)
+        }
+    }
+    if isDeprecated(nil) {
+        t.Error("no doc comment is deprecated")
+    }
+}
+
+func TestMarkDeprecated(t *testing.T) {
+    cases := []struct{ rendered, want string }{
+        {"<p>Deprecated: use <code>G</code>.</p>\n", `<p class="deprecated">` + deprecatedBadge + " use <code>G</code>.</p>\n"},
+        {"<p>F does things.</p>\n<p>Deprecated:\nuse G.</p>\n", "<p>F does things.</p>\n<p class=\"deprecated\">" + deprecatedBadge + " use G.</p>\n"},
+        {"<p>F is not Deprecated: really.</p>\n", "<p>F is not Deprecated: really.</p>\n"},
+        {"<p>Deprecated:use G.</p>\n", "<p>Deprecated:use G.</p>\n"},
+    }
+    for _, c := range cases {
+        if got := markDeprecated(c.rendered); got != c.want {
+            t.Errorf("markDeprecated(%q) = %q, want %q", c.rendered, got, c.want)
+        }
+    }
+}
+
+// Deprecated declarations are tagged in the symbols menu, and only
+// they are.
+func TestDeprecatedSymbols(t *testing.T) {
+    src := []byte("// Docs.\npackage p\n\n// Old does things.\n//\n// Deprecated: use New.\nfunc Old() {}\n\n// New isn't Deprecated: at all.\nfunc New() {}\n")
+    segs, err := fileSegments("p.go", src)
+    if err != nil {
+        t.Fatal(err)
+    }
+    segmentAnchors(segs)
+    menu := renderSymbols("p.go", src, segs)
+    for _, want := range []string{
+        `<li class="func deprecated"><a href="#` + segmentAt(segs, 7).anchor + `"><code>Old</code></a> ` + deprecatedBadge + `</li>`,
+        `<li class="func"><a href="#` + segmentAt(segs, 10).anchor + `"><code>New</code></a></li>`,
+    } {
+        if !strings.Contains(menu, want) {
+            t.Errorf("menu has no %s:\n%s", want, menu)
+        }
+    }
+}
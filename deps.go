@@ -0,0 +1,53 @@
+// ### Dependency graph
+
+// Building a whole module with `./...` also writes
+// `dependencies.html`, the graph `internal/deps` draws of which of
+// its packages import which.
+
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "html"
+    "html/template"
+    "path/filepath"
+
+    "github.com/mmcgrana/golit/internal/deps"
+)
+
+// Where the graph goes, from the top of the output.
+const dependenciesName = "dependencies.html"
+
+// Whether this build gets a dependency graph.
+func writesDependencies() bool {
+    return outDir != "" && !fragment && !singlePage && modulePath(moduleRoot) != ""
+}
+
+// Write `dependencies.html` for the pages we built.
+func writeDependencies(pages []pageInfo, css string) error {
+    depsPath := filepath.Join(outDir, dependenciesName)
+    sources := []string{}
+    for _, page := range pages {
+        if !page.document && filepath.Ext(page.source) == ".go" && !isTestFile(page.source) {
+            sources = append(sources, page.source)
+        }
+    }
+    nodes, edges := deps.Graph(modulePath(moduleRoot), moduleRoot, sources, packagePages)
+    title := siteName + " dependencies"
+    docs := fmt.Sprintf("<h1>%s</h1>\n", html.EscapeString(title))
+    if len(nodes) == 0 {
+        docs += "<p>No packages.</p>\n"
+    } else {
+        docs += "<div class=\"dependency-graph\">\n" + deps.Render(nodes, edges, depsPath) + "</div>\n"
+    }
+    var out bytes.Buffer
+    p := page{Title: title, CSS: template.HTML(css), Nav: template.HTML(renderNav(depsPath)), path: depsPath}
+    err := writePage(&out, p, func(emit func(pageSegment) error) error {
+        return emit(pageSegment{DocsHTML: template.HTML(docs), Wide: true, HasDocs: true})
+    })
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(depsPath, out.Bytes())
+}
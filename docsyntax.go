@@ -0,0 +1,195 @@
+// ### Go doc comment syntax
+
+// Docs are Markdown by default, but the comments of a Go project are
+// often written as Go doc comments, whose syntax, since Go 1.19, is
+// its own: `[Name]` links, `# Heading` lines, code blocks set off by
+// indenting, and lists that Markdown reads differently. With
+// `--doc-syntax go`, docs are rendered by `go/doc/comment`, as
+// pkg.go.dev would render them. `[Name]` and `[Type.Method]` link to
+// the segment declaring them when they're declared in the same file,
+// and `[pkg.Name]` to the package, where imports would link to it.
+// Header comments, like `// ### Section`, stay Markdown headings, and
+// quotes stay Markdown quotes.
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "go/doc/comment"
+    "go/parser"
+    "go/token"
+    "path"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+var docSyntax = "markdown"
+
+func init() {
+    flag.StringVar(&docSyntax, "doc-syntax", docSyntax, "read docs as markdown, or as go doc comments")
+}
+
+// Check `--doc-syntax`, and render docs with `go/doc/comment` if it
+// asks for it.
+func checkDocSyntax() error {
+    switch docSyntax {
+    case "markdown":
+        return nil
+    case "go":
+        if docsRendererName != "" || markdownBin != "" {
+            return fmt.Errorf("--doc-syntax go can't be combined with --docs-renderer or --markdown-bin")
+        }
+        docRenderer = goDocRenderer{}
+        return nil
+    }
+    return fmt.Errorf("unknown --doc-syntax %q; use markdown or go", docSyntax)
+}
+
+// What a Go file's docs can link to: the anchors of the segments its
+// top-level names are declared in, with methods as `Type.Method`, the
+// packages it imports, by name, and where its page goes.
+type docScope struct {
+    anchors map[string]string
+    imports map[string]string
+    outPath string
+}
+
+// Give `segs`, from the Go file at `sourcePath`, whose page goes to
+// `outPath`, what their docs can link to.
+func scopeDocs(sourcePath, outPath string, src []byte, segs []*seg) {
+    if docSyntax != "go" || filepath.Ext(sourcePath) != ".go" {
+        return
+    }
+    scope := &docScope{anchors: map[string]string{}, imports: map[string]string{}, outPath: outPath}
+    found, _ := fileSymbols(src)
+    for _, sym := range found {
+        if in := segmentAt(segs, sym.line); in != nil {
+            scope.anchors[sym.name] = in.anchor
+        }
+    }
+    if file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ImportsOnly); err == nil {
+        for _, spec := range file.Imports {
+            p, err := strconv.Unquote(spec.Path.Value)
+            if err != nil {
+                continue
+            }
+            name := path.Base(p)
+            if spec.Name != nil {
+                name = spec.Name.Name
+            }
+            scope.imports[name] = p
+        }
+    }
+    for _, seg := range segs {
+        seg.scope = scope
+    }
+}
+
+var bareCommentPat = regexp.MustCompile(`^(\s*//)(\t|$)`)
+
+// Go doc comments separate paragraphs with bare `//` lines and start
+// code blocks with `//` and a tab, so, for a Go file's `lines`, give
+// those the space after `//` that doc lines have, so they stay docs,
+// code blocks keep their indenting, and paragraphs stay together.
+func goDocLines(sourcePath string, lines []string) []string {
+    if docSyntax != "go" || filepath.Ext(sourcePath) != ".go" {
+        return lines
+    }
+    fixed := make([]string, len(lines))
+    for i, line := range lines {
+        fixed[i] = bareCommentPat.ReplaceAllString(line, "$1 $2")
+    }
+    return fixed
+}
+
+// The renderer for the docs of `seg`: the one every segment goes
+// through, knowing what's in scope for Go doc comments.
+func segmentDocRenderer(seg *seg) DocRenderer {
+    if r, ok := docRenderer.(goDocRenderer); ok && seg.scope != nil {
+        r.scope = seg.scope
+        return r
+    }
+    return docRenderer
+}
+
+// Renders docs as Go doc comments, linking names to what's in
+// `scope`, if anything.
+type goDocRenderer struct {
+    scope *docScope
+}
+
+func (r goDocRenderer) RenderDocs(src string) (string, error) {
+    scope := r.scope
+    if scope == nil {
+        scope = &docScope{}
+    }
+    key := func(recv, name string) string {
+        if recv != "" {
+            return recv + "." + name
+        }
+        return name
+    }
+    p := comment.Parser{
+        LookupPackage: func(name string) (string, bool) {
+            importPath, ok := scope.imports[name]
+            return importPath, ok
+        },
+        LookupSym: func(recv, name string) bool {
+            _, ok := scope.anchors[key(recv, name)]
+            return ok
+        },
+    }
+    printer := comment.Printer{
+        // Headings get their ids as everyone else's do.
+        HeadingID: func(*comment.Heading) string { return "" },
+        DocLinkURL: func(link *comment.DocLink) string {
+            if link.ImportPath == "" {
+                if anchor, ok := scope.anchors[key(link.Recv, link.Name)]; ok {
+                    return "#" + anchor
+                }
+                return ""
+            }
+            href := importURL(link.ImportPath, scope.outPath, modulePath(moduleRoot), localReplacements(moduleRoot))
+            if strings.HasPrefix(href, "https://pkg.go.dev/") && link.Name != "" {
+                href += "#" + key(link.Recv, link.Name)
+            }
+            return href
+        },
+    }
+    // Header comments and quotes, which may be admonitions, are
+    // golit's, not Go's, so they're rendered as Markdown, keeping
+    // headings' levels, and the rest between them as a doc comment.
+    var out strings.Builder
+    var chunk []string
+    flush := func(asMarkdown bool) error {
+        if len(chunk) == 0 {
+            return nil
+        }
+        text := strings.Join(chunk, "\n")
+        chunk = nil
+        if !asMarkdown {
+            out.Write(printer.HTML(p.Parse(text)))
+            return nil
+        }
+        rendered, err := goldmarkRenderer{}.RenderDocs(text)
+        out.WriteString(rendered)
+        return err
+    }
+    inMarkdown := false
+    for _, line := range strings.Split(src, "\n") {
+        if isMarkdown := (headingLinePat.MatchString(line) && strings.HasPrefix(line, "#")) || strings.HasPrefix(line, ">"); isMarkdown != inMarkdown {
+            if err := flush(inMarkdown); err != nil {
+                return "", err
+            }
+            inMarkdown = isMarkdown
+        }
+        chunk = append(chunk, line)
+    }
+    if err := flush(inMarkdown); err != nil {
+        return "", err
+    }
+    return out.String(), nil
+}
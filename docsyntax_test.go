@@ -0,0 +1,94 @@
+package main
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestGoDocLines(t *testing.T) {
+    defer func(saved string) { docSyntax = saved }(docSyntax)
+    lines := []string{"// Text.", "//", "//\tcode()", "    //", "x := 1 //"}
+    docSyntax = "go"
+    want := []string{"// Text.", "// ", "// \tcode()", "    // ", "x := 1 //"}
+    if got := goDocLines("a.go", lines); !reflect.DeepEqual(got, want) {
+        t.Errorf("goDocLines = %q, want %q", got, want)
+    }
+    if got := goDocLines("a.py", lines); !reflect.DeepEqual(got, lines) {
+        t.Errorf("goDocLines changed a file that isn't Go: %q", got)
+    }
+    docSyntax = "markdown"
+    if got := goDocLines("a.go", lines); !reflect.DeepEqual(got, lines) {
+        t.Errorf("goDocLines changed Markdown docs: %q", got)
+    }
+}
+
+func TestGoDocRenderer(t *testing.T) {
+    r := goDocRenderer{scope: &docScope{
+        anchors: map[string]string{"Name": "section-2", "T.M": "section-5"},
+        imports: map[string]string{"strings": "strings"},
+    }}
+    cases := []struct{ src, want string }{
+        {"See [Name] and [T.M].", `<p>See <a href="#section-2">Name</a> and <a href="#section-5">T.M</a>.`},
+        {"Use [strings.Builder].", `<a href="https://pkg.go.dev/strings#Builder">strings.Builder</a>`},
+        {"Not [Missing].", "<p>Not [Missing].\n"},
+        {"Code:\n\n\tx := 1\n\n\tif x {\n\t\ty()\n\t}", "<pre>x := 1\n\nif x {\n\ty()\n}\n</pre>"},
+        {"### Sub\nText [Name].", `<h3>Sub</h3>`},
+        {"### Sub\nText [Name].", `<p>Text <a href="#section-2">Name</a>.`},
+        {"# Heading\n\nText.", "<h1>Heading</h1>"},
+        {"Text.\n> Quoted *here*.", "<blockquote>\n<p>Quoted <em>here</em>.</p>\n</blockquote>"},
+    }
+    for _, c := range cases {
+        got, err := r.RenderDocs(c.src)
+        if err != nil || !strings.Contains(got, c.want) {
+            t.Errorf("RenderDocs(%q) = %q, %v; want it to contain %q", c.src, got, err, c.want)
+        }
+    }
+}
+
+const docScopeSrc = `// Docs.
+package a
+
+import str "strings"
+
+// Greet greets.
+func Greet() string { return str.ToUpper("hi") }
+
+// T is a type.
+type T struct{}
+
+// M is a method.
+func (T) M() {}
+`
+
+// A file's names resolve to the anchors of the segments declaring
+// them, and its imports by the names it uses for them.
+func TestScopeDocs(t *testing.T) {
+    defer func(saved string) { docSyntax = saved }(docSyntax)
+    docSyntax = "go"
+    segs, err := fileSegments("a.go", []byte(docScopeSrc))
+    if err != nil {
+        t.Fatal(err)
+    }
+    segmentAnchors(segs)
+    scopeDocs("a.go", "a.html", []byte(docScopeSrc), segs)
+    scope := segs[0].scope
+    if scope == nil {
+        t.Fatal("no scope")
+    }
+    for name, line := range map[string]int{"Greet": 7, "T": 10, "T.M": 13} {
+        if want := segmentAt(segs, line).anchor; want == "" || scope.anchors[name] != want {
+            t.Errorf("%s links to #%s, want #%s", name, scope.anchors[name], want)
+        }
+    }
+    if want := map[string]string{"str": "strings"}; !reflect.DeepEqual(scope.imports, want) {
+        t.Errorf("imports = %v, want %v", scope.imports, want)
+    }
+
+    docSyntax = "markdown"
+    others, _ := fileSegments("a.go", []byte(docScopeSrc))
+    scopeDocs("a.go", "a.html", []byte(docScopeSrc), others)
+    if others[0].scope != nil {
+        t.Error("scope given to Markdown docs")
+    }
+}
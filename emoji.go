@@ -0,0 +1,66 @@
+// ### Emoji
+
+// Comments that started out in GitHub issues bring their emoji with
+// them, as shortcodes like `:warning:` and `:rocket:`. Before docs are
+// rendered, those GitHub has, from a table of the common ones, become
+// the emoji they stand for, except in code, which keeps them as they
+// are, and `--no-emoji` leaves them all alone. Shortcodes that aren't
+// in the table are left too, so `10:30:00` is still a time.
+
+package main
+
+import (
+    _ "embed"
+    "flag"
+    "regexp"
+    "strings"
+)
+
+var noEmoji bool
+
+func init() {
+    flag.BoolVar(&noEmoji, "no-emoji", false, "leave emoji shortcodes like :rocket: in docs as they are")
+}
+
+//go:embed resources/emoji.txt
+var emojiTable string
+
+// The emoji for each shortcode, without its colons.
+var emoji = map[string]string{}
+
+func init() {
+    for _, line := range strings.Split(emojiTable, "\n") {
+        if fields := strings.Fields(line); len(fields) == 2 && !strings.HasPrefix(line, "#") {
+            emoji[fields[0]] = fields[1]
+        }
+    }
+}
+
+var shortcodePat = regexp.MustCompile(`^:([a-z0-9_+-]+):`)
+
+// Replace the shortcodes in `docs` outside of code with their emoji.
+// A colon that doesn't start one may still end one.
+func replaceEmoji(docs string) string {
+    if noEmoji || !strings.Contains(docs, ":") {
+        return docs
+    }
+    code := codeBytes(docs)
+    var out strings.Builder
+    last := 0
+    for i := strings.IndexByte(docs, ':'); i >= 0 && i < len(docs); {
+        if match := shortcodePat.FindStringSubmatch(docs[i:]); match != nil && !code[i] && emoji[match[1]] != "" {
+            out.WriteString(docs[last:i] + emoji[match[1]])
+            last = i + len(match[0])
+            i = last
+        } else {
+            i++
+        }
+        next := strings.IndexByte(docs[i:], ':')
+        if next < 0 {
+            break
+        }
+        i += next
+    }
+    out.WriteString(docs[last:])
+    return out.String()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+    "strings"
+    "testing"
+)
+
+// Shortcodes in the table become emoji, except in code of any kind,
+// and anything else with colons is left as it is.
+func TestReplaceEmoji(t *testing.T) {
+    cases := []struct {
+        docs, want string
+    }{
+        {"Shipped :rocket: :tada:", "Shipped 🚀 🎉"},
+        {":+1::smile:", "👍😄"},
+        {"At 10:30:00, :warning:", "At 10:30:00, ⚠️"},
+        {"Not one :no_such_emoji: here", "Not one :no_such_emoji: here"},
+        {"Type `:rocket:` for :rocket:", "Type `:rocket:` for 🚀"},
+        {"Or ``a ` :tada: ` b`` here", "Or ``a ` :tada: ` b`` here"},
+        {"```yaml\nkey: :rocket:\n```\n:rocket:", "```yaml\nkey: :rocket:\n```\n🚀"},
+        {"~~~\n:tada:\n~~~", "~~~\n:tada:\n~~~"},
+        {"Code:\n\n    s := \":smile:\"\n\nAfter :smile:", "Code:\n\n    s := \":smile:\"\n\nAfter 😄"},
+        {"An unclosed ` lets :tada: through", "An unclosed ` lets 🎉 through"},
+    }
+    for _, c := range cases {
+        if got := replaceEmoji(c.docs); got != c.want {
+            t.Errorf("replaceEmoji(%q) = %q, want %q", c.docs, got, c.want)
+        }
+    }
+}
+
+func TestNoEmoji(t *testing.T) {
+    defer func(saved bool) { noEmoji = saved }(noEmoji)
+    noEmoji = true
+    if got := replaceEmoji(":rocket:"); got != ":rocket:" {
+        t.Errorf("with --no-emoji, replaceEmoji(:rocket:) = %q", got)
+    }
+}
+
+// Shortcodes in code come through rendering as they are, beside emoji
+// from the same docs.
+func TestEmojiNotInCode(t *testing.T) {
+    dir := writeFiles(t, map[string]string{
+        "a.go": "// Use `:rocket:` for :rocket:, as in:\n//\n// ```\n// msg: :tada:\n// ```\npackage p\n",
+    })
+    stdout, stderr, code := runGolit(t, dir, "--remote-css", "a.go")
+    if code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    for _, want := range []string{"<code>:rocket:</code> for 🚀", "msg: :tada:"} {
+        if !strings.Contains(stdout, want) {
+            t.Errorf("page doesn't have %s:\n%s", want, stdout)
+        }
+    }
+    if strings.Contains(stdout, "🎉") {
+        t.Errorf("the shortcode in the fence became an emoji:\n%s", stdout)
+    }
+}
@@ -0,0 +1,261 @@
+// ### Examples
+
+// A test file's `Example` funcs are literate already: code, with the
+// output it gives in an `// Output:` comment. Rather than segmenting
+// one line by line, we find its examples as `go test` would, with
+// `go/doc`, and give each a segment of its own: a heading naming what
+// it's an example of, linking to where that's declared when it's a
+// page of the same build, then the example's doc comment, beside the
+// body of the func, without the func around it, and its output, in a
+// box of its own. The rest of the file is segmented as usual. An
+// example that `go/doc` can make into a program, one that doesn't use
+// the package's own names, gets a button to run it in the playground.
+
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "go/ast"
+    "go/doc"
+    "go/format"
+    "go/parser"
+    "go/token"
+    "io/ioutil"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+var outputCommentPat = regexp.MustCompile(`(?i)^[[:space:]]*(unordered )?output:`)
+
+// An example's or benchmark's place in the file: the lines from its
+// doc comment to the end of the func, and those of its body that are
+// code rather than an example's output comment.
+type funcSpan struct {
+    example            *doc.Example
+    benchmark          string
+    start, end         int
+    bodyStart, bodyEnd int
+}
+
+// Segment `lines`, the Go test file at `sourcePath`, around its
+// examples and benchmarks, each of which becomes a segment of its
+// own, given `segs` as it's segmented without them. Files without
+// either keep `segs`.
+func segmentExamples(sourcePath string, src []byte, lines []string, segs []*seg, docsPat, headerPat *regexp.Regexp) []*seg {
+    if docsOnly || !isTestFile(sourcePath) || len(segs) == 0 {
+        return segs
+    }
+    fset := token.NewFileSet()
+    file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+    if err != nil {
+        return segs
+    }
+    spans := append(exampleSpans(fset, file), benchmarkSpans(fset, file)...)
+    sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+    if len(spans) == 0 {
+        return segs
+    }
+
+    // Lines are counted from 1; `from` is the first of those not yet
+    // segmented.
+    out := []*seg{}
+    region := func(from, to int) {
+        if from > to {
+            return
+        }
+        for _, seg := range segment(lines[from-1:to], docsPat, headerPat) {
+            if strings.TrimSpace(seg.docs) == "" && strings.TrimSpace(seg.code) == "" {
+                continue
+            }
+            seg.line, seg.lang = seg.line+from-1, segs[0].lang
+            if seg.codeLine != 0 {
+                seg.codeLine += from - 1
+            }
+            out = append(out, seg)
+        }
+    }
+    from := segs[0].line
+    for _, span := range spans {
+        if span.start < from {
+            continue
+        }
+        region(from, span.start-1)
+        var special *seg
+        if span.example != nil {
+            special = exampleSegment(sourcePath, span, lines, docsPat)
+        } else {
+            special = benchmarkSegment(sourcePath, span, lines, docsPat)
+        }
+        special.lang = segs[0].lang
+        out = append(out, special)
+        from = span.end + 1
+    }
+    region(from, len(lines))
+    if len(out) == 0 {
+        return segs
+    }
+    return out
+}
+
+// The examples of `file`.
+func exampleSpans(fset *token.FileSet, file *ast.File) []funcSpan {
+    funcs := map[string]*ast.FuncDecl{}
+    for _, decl := range file.Decls {
+        if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Body != nil {
+            funcs[fn.Name.Name] = fn
+        }
+    }
+    spans := []funcSpan{}
+    for _, ex := range doc.Examples(file) {
+        fn := funcs["Example"+ex.Name]
+        if fn == nil {
+            continue
+        }
+        span := spanOf(fset, fn)
+        span.example = ex
+        // The output comment is the last thing in the body.
+        for _, group := range file.Comments {
+            if group.Pos() > fn.Body.Lbrace && group.End() < fn.Body.Rbrace && outputCommentPat.MatchString(group.Text()) {
+                span.bodyEnd = fset.Position(group.Pos()).Line - 1
+            }
+        }
+        spans = append(spans, span)
+    }
+    return spans
+}
+
+// Where the func `fn` is, doc comment and all.
+func spanOf(fset *token.FileSet, fn *ast.FuncDecl) funcSpan {
+    span := funcSpan{
+        start:     fset.Position(fn.Pos()).Line,
+        end:       fset.Position(fn.End()).Line,
+        bodyStart: fset.Position(fn.Body.Lbrace).Line + 1,
+        bodyEnd:   fset.Position(fn.Body.Rbrace).Line - 1,
+    }
+    if fn.Doc != nil {
+        span.start = fset.Position(fn.Doc.Pos()).Line
+    }
+    return span
+}
+
+// The segment for the example at `span`.
+func exampleSegment(sourcePath string, span funcSpan, lines []string, docsPat *regexp.Regexp) *seg {
+    ex := span.example
+    name := exampleName(ex.Name)
+    heading := "Example"
+    if name != "" {
+        heading += " " + name
+        if href := exampleTarget(sourcePath, name); href != "" {
+            heading = fmt.Sprintf("Example [%s](%s)", name, href)
+        }
+    }
+    if suffix := exampleSuffix(ex.Name); suffix != "" {
+        heading += " (" + suffix + ")"
+    }
+    s := spanSegment(span, heading, lines, docsPat)
+    s.output, s.unordered = ex.Output, ex.Unordered
+    if ex.Play != nil {
+        var program bytes.Buffer
+        if err := format.Node(&program, token.NewFileSet(), ex.Play); err == nil {
+            s.program = program.String()
+        }
+    }
+    return s
+}
+
+// A segment for the func at `span`, with its doc comment under
+// `heading` beside its body, without the func's indentation.
+func spanSegment(span funcSpan, heading string, lines []string, docsPat *regexp.Regexp) *seg {
+    docs := []string{"## " + heading, ""}
+    for n := span.start; n <= len(lines) && docsPat.MatchString(lines[n-1]); n++ {
+        docs = append(docs, docsPat.ReplaceAllString(lines[n-1], ""))
+    }
+    body := []string{}
+    indent := ""
+    for n := span.bodyStart; n <= span.bodyEnd && n <= len(lines); n++ {
+        if indent == "" && strings.TrimSpace(lines[n-1]) != "" {
+            indent = lines[n-1][:len(lines[n-1])-len(strings.TrimLeft(lines[n-1], " \t"))]
+        }
+        body = append(body, lines[n-1])
+    }
+    for i, line := range body {
+        body[i] = strings.TrimPrefix(line, indent)
+    }
+    s := &seg{docs: strings.Join(docs, "\n"), code: strings.Join(body, "\n"), line: span.start}
+    if strings.TrimSpace(s.code) != "" {
+        s.codeLine, s.codeLines = span.bodyStart, len(body)
+    }
+    return s
+}
+
+// What an example called `Example` plus `name` is of: "" for the
+// package, `F` for a func or type, `T.M` for a method.
+func exampleName(name string) string {
+    if name == "" || strings.HasPrefix(name, "_") {
+        return ""
+    }
+    parts := strings.Split(name, "_")
+    if exampleSuffix(name) != "" {
+        parts = parts[:len(parts)-1]
+    }
+    return strings.Join(parts, ".")
+}
+
+// The suffix telling apart examples of the same thing, which starts
+// with a lower-case letter.
+func exampleSuffix(name string) string {
+    i := strings.LastIndexByte(name, '_')
+    if i < 0 {
+        return ""
+    }
+    if r, _ := utf8.DecodeRuneInString(name[i+1:]); !unicode.IsLower(r) {
+        return ""
+    }
+    return name[i+1:]
+}
+
+// Where an example from the test file at `sourcePath` links to what
+// it's of, `name`: the segment declaring it in another file of the
+// package, as a link in the docs, which is rewritten to point at that
+// file's page. It's "" when that file isn't a page of the same build.
+func exampleTarget(sourcePath, name string) string {
+    if len(pagesBySource) == 0 || singlePage {
+        return ""
+    }
+    abs, err := filepath.Abs(sourcePath)
+    if err != nil {
+        return ""
+    }
+    siblings, _ := filepath.Glob(filepath.Join(filepath.Dir(abs), "*.go"))
+    for _, sibling := range siblings {
+        if _, ok := pagesBySource[sibling]; !ok || isTestFile(sibling) {
+            continue
+        }
+        src, err := ioutil.ReadFile(sibling)
+        if err != nil {
+            continue
+        }
+        found, _ := fileSymbols(src)
+        for _, sym := range found {
+            if sym.name != name {
+                continue
+            }
+            segs, err := fileSegments(sibling, src)
+            if err != nil {
+                return ""
+            }
+            segmentAnchors(segs)
+            href := filepath.Base(sibling)
+            if in := segmentAt(segs, sym.line); in != nil {
+                href += "#" + in.anchor
+            }
+            return href
+        }
+    }
+    return ""
+}
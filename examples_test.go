@@ -0,0 +1,129 @@
+package main
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestExampleName(t *testing.T) {
+    cases := []struct{ name, of, suffix string }{
+        {"", "", ""},
+        {"_second", "", "second"},
+        {"F", "F", ""},
+        {"F_fast", "F", "fast"},
+        {"T_M", "T.M", ""},
+        {"T_M_suffix", "T.M", "suffix"},
+        {"T_Method", "T.Method", ""},
+    }
+    for _, c := range cases {
+        if of, suffix := exampleName(c.name), exampleSuffix(c.name); of != c.of || suffix != c.suffix {
+            t.Errorf("Example%s is of %q, suffix %q; want %q, %q", c.name, of, suffix, c.of, c.suffix)
+        }
+    }
+}
+
+const examplesSrc = `package p_test
+
+import "fmt"
+
+// Setup.
+func setup() {}
+
+// Says hi.
+func Example() {
+    fmt.Println("hi")
+    // Output: hi
+}
+
+func ExampleF() {
+    fmt.Println(2)
+    fmt.Println(1)
+    // Unordered output:
+    // 1
+    // 2
+}
+
+func ExampleT_M() {
+    undeclared()
+}
+
+func ExampleT_M_suffix() {
+    fmt.Println("x")
+}
+`
+
+// Each example gets a segment headed with what it's of, beside its
+// body, and its output; one that uses names golit can't give the
+// playground has no program.
+func TestSegmentExamples(t *testing.T) {
+    segs, err := fileSegments("p_test.go", []byte(examplesSrc))
+    if err != nil {
+        t.Fatal(err)
+    }
+    type example struct {
+        heading, code, output string
+        unordered, play       bool
+    }
+    got := []example{}
+    for _, seg := range segs {
+        if !strings.HasPrefix(seg.docs, "## Example") {
+            continue
+        }
+        heading := strings.SplitN(seg.docs, "\n", 2)[0]
+        got = append(got, example{heading, seg.code, seg.output, seg.unordered, seg.program != ""})
+    }
+    want := []example{
+        {"## Example", "fmt.Println(\"hi\")", "hi\n", false, true},
+        {"## Example F", "fmt.Println(2)\nfmt.Println(1)", "1\n2\n", true, true},
+        {"## Example T.M", "undeclared()", "", false, false},
+        {"## Example T.M (suffix)", "fmt.Println(\"x\")", "", false, true},
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("examples =\n%+v\nwant\n%+v", got, want)
+    }
+    rest := ""
+    for _, seg := range segs {
+        if !strings.HasPrefix(seg.docs, "## Example") {
+            rest += seg.docs + seg.code
+        }
+        if strings.HasPrefix(seg.docs, "## Example\n") && !strings.Contains(seg.docs, "Says hi.") {
+            t.Errorf("example's doc comment isn't under its heading: %q", seg.docs)
+        }
+    }
+    if !strings.Contains(rest, "Setup.") || !strings.Contains(rest, "func setup() {}") || strings.Contains(rest, "Example") {
+        t.Errorf("the rest of the file isn't segmented as usual: %q", rest)
+    }
+
+    // Files that aren't tests keep their segments.
+    plain, err := fileSegments("p.go", []byte(examplesSrc))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, seg := range plain {
+        if strings.HasPrefix(seg.docs, "## Example") {
+            t.Errorf("examples segmented out of a file that isn't a test")
+        }
+    }
+}
+
+// The output goes in a box of its own, saying when it's in any order,
+// and only examples with a program have a playground button.
+func TestExampleOutput(t *testing.T) {
+    dir := writeFiles(t, map[string]string{"p_test.go": examplesSrc})
+    stdout, stderr, code := runGolit(t, dir, "--remote-css", "--no-vcs-info", "p_test.go")
+    if code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    for _, want := range []string{
+        `<div class="example-output"><p>Output:</p><pre>hi` + "\n" + `</pre></div>`,
+        `<div class="example-output"><p>Output, in any order:</p><pre>1` + "\n2\n" + `</pre></div>`,
+    } {
+        if !strings.Contains(stdout, want) {
+            t.Errorf("no %s", want)
+        }
+    }
+    if n := strings.Count(stdout, `<button class="playground"`); n != 3 {
+        t.Errorf("%d playground buttons, want 3", n)
+    }
+}
@@ -0,0 +1,51 @@
+// ### Folding
+
+// Boilerplate, like a block of flag definitions or a long struct
+// literal, can take up more of a page than it deserves. A
+// `// golit:fold` line, in whatever comment marker the file uses,
+// folds the code that follows it into a `<details>`, closed until the
+// reader asks to see it, while the docs beside it stay as they are.
+// The directive itself shows up in neither column. With `--fold-over`,
+// any segment with more lines of code than that is folded too. Lines
+// keep their numbers and anchors, and following a link to one opens
+// the fold it's in.
+
+package main
+
+import (
+    "flag"
+    "strings"
+)
+
+var foldOver int
+
+func init() {
+    flag.IntVar(&foldOver, "fold-over", 0, "fold code segments longer than `n` lines; 0 to fold only those marked golit:fold")
+}
+
+// Whether a docs line, stripped of its comment marker, is a fold
+// directive.
+func isFoldDirective(docs string) bool {
+    return strings.TrimSpace(docs) == "golit:fold"
+}
+
+// How many lines of code `seg` shows.
+func foldLines(seg *seg) int {
+    code := strings.Trim(seg.code, "\n")
+    if strings.TrimSpace(code) == "" {
+        return 0
+    }
+    return strings.Count(code, "\n") + 1
+}
+
+// Fold the segments with more than `--fold-over` lines of code.
+func foldLong(segs []*seg) {
+    if foldOver <= 0 {
+        return
+    }
+    for _, seg := range segs {
+        if foldLines(seg) > foldOver {
+            seg.fold = true
+        }
+    }
+}
@@ -0,0 +1,66 @@
+// ### Template helpers
+
+// Templates, ours and the user's, get a few functions beyond the
+// fields of the page: `slugify` makes text into an `id` or class
+// name, `markdown` renders a string as docs, `relurl` turns a path
+// from the top of the output into a link from the current page, and
+// `firstParagraph` pulls the first paragraph out of rendered docs.
+// `relurl` depends on which page is being rendered, so it's bound per
+// page, on a copy of the template.
+
+package main
+
+import (
+    "html/template"
+    "path/filepath"
+    "strings"
+)
+
+var templateFuncs = template.FuncMap{
+    "slugify":        Slugify,
+    "markdown":       renderMarkdown,
+    "relurl":         func(target string) string { return target },
+    "firstParagraph": firstParagraph,
+}
+
+func renderMarkdown(src string) (template.HTML, error) {
+    out, err := docRenderer.RenderDocs(src)
+    return template.HTML(out), err
+}
+
+// The contents of the first `<p>` in `docs`, or "".
+func firstParagraph(docs template.HTML) template.HTML {
+    if match := paragraphPat.FindStringSubmatch(string(docs)); match != nil {
+        return template.HTML(strings.TrimSpace(match[1]))
+    }
+    return ""
+}
+
+// Link to `target`, a path from the top of the output, from the page
+// at `current`. URLs, absolute paths and pages going to stdout are
+// left alone.
+func relURL(current, target string) string {
+    if current == "" || strings.Contains(target, "://") || strings.HasPrefix(target, "/") {
+        return target
+    }
+    root := outDir
+    if root == "" {
+        root = filepath.Dir(current)
+    }
+    rel, err := filepath.Rel(filepath.Dir(current), filepath.Join(root, filepath.FromSlash(target)))
+    if err != nil {
+        return target
+    }
+    return filepath.ToSlash(rel)
+}
+
+// A copy of `t` with `relurl` working from the page at `current`.
+func forPage(t *template.Template, current string) (*template.Template, error) {
+    t, err := t.Clone()
+    if err != nil {
+        return nil, err
+    }
+    return t.Funcs(template.FuncMap{
+        "relurl": func(target string) string { return relURL(current, target) },
+    }), nil
+}
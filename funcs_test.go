@@ -0,0 +1,63 @@
+package main
+
+import (
+    "html/template"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestFirstParagraph(t *testing.T) {
+    cases := []struct {
+        in, want template.HTML
+    }{
+        {"<h1>Title</h1>\n<p>First <em>one</em>.</p>\n<p>Second.</p>", "First <em>one</em>."},
+        {"<p>\n  Padded.\n</p>", "Padded."},
+        {"<h2>Only a header</h2>", ""},
+        {"", ""},
+    }
+    for _, c := range cases {
+        if got := firstParagraph(c.in); got != c.want {
+            t.Errorf("firstParagraph(%q) = %q, want %q", c.in, got, c.want)
+        }
+    }
+}
+
+func TestRelURL(t *testing.T) {
+    defer func(saved string) { outDir = saved }(outDir)
+    outDir = "out"
+    cases := []struct {
+        current, target, want string
+    }{
+        {filepath.Join("out", "a.html"), "index.html", "index.html"},
+        {filepath.Join("out", "sub", "b.html"), "index.html", "../index.html"},
+        {filepath.Join("out", "sub", "b.html"), "sub/c.html", "c.html"},
+        {filepath.Join("out", "sub", "b.html"), "https://go.dev/", "https://go.dev/"},
+        {filepath.Join("out", "sub", "b.html"), "/abs.css", "/abs.css"},
+        {"", "index.html", "index.html"},
+    }
+    for _, c := range cases {
+        if got := relURL(c.current, c.target); got != c.want {
+            t.Errorf("relURL(%q, %q) = %q, want %q", c.current, c.target, got, c.want)
+        }
+    }
+}
+
+// The built-in template describes a page by the first paragraph of its
+// docs, as text, so its markup doesn't leak into the attribute.
+func TestPageDescription(t *testing.T) {
+    dir := writeFiles(t, map[string]string{
+        "a.go": "// # A\n\n// Does *things* with `\"quotes\"`.\n//\n// More.\npackage p\n",
+        "b.go": "// # Just a header\npackage p\n",
+    })
+    stdout, stderr, code := runGolit(t, dir, "a.go")
+    if code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    if want := `<meta name="description" content="Does things with &quot;quotes&quot;.">`; !strings.Contains(stdout, want) {
+        t.Errorf("page doesn't have %s:\n%s", want, stdout)
+    }
+    if stdout, _, _ = runGolit(t, dir, "b.go"); strings.Contains(stdout, `name="description"`) {
+        t.Errorf("page with no paragraph has a description:\n%s", stdout)
+    }
+}
@@ -0,0 +1,15 @@
+module github.com/mmcgrana/golit
+
+go 1.25
+
+require (
+	github.com/alecthomas/chroma/v2 v2.27.0
+	github.com/fsnotify/fsnotify v1.10.1
+	github.com/yuin/goldmark v1.8.6
+	golang.org/x/net v0.50.0
+)
+
+require (
+	github.com/dlclark/regexp2/v2 v2.2.1 // indirect
+	golang.org/x/sys v0.41.0 // indirect
+)
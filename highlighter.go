@@ -0,0 +1,171 @@
+// ### Persistent highlighter
+
+// Starting `pygmentize` means starting Python and loading Pygments,
+// which takes far longer than highlighting a segment does. That adds
+// up across a directory of files, and on every rebuild under
+// `--watch`. So where we can, we start a small filter script of our
+// own once, in the same Python that `pygmentize` runs under, and
+// stream code through it for the rest of the build. Requests and
+// replies are length-prefixed so code can contain anything. If the
+// script can't be started, or dies along the way, we go back to
+// running `pygmentize` each time.
+
+package main
+
+import (
+    "bufio"
+    _ "embed"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+//go:embed resources/highlight.py
+var highlightScript string
+
+// A highlighter process and the pipes to talk to it. Requests are
+// taken one at a time.
+type highlighter struct {
+    sync.Mutex
+    fallback pygmentizeCommand
+    cmd      *exec.Cmd
+    in       io.WriteCloser
+    out      *bufio.Reader
+    broken   bool
+}
+
+// Start a highlighter for Pygments as installed with the `pygmentize`
+// at `bin`, or settle for running `bin` itself when that fails.
+// `chosen` says whether `bin` was picked explicitly rather than found
+// on the `PATH`.
+func startHighlighter(bin string, chosen bool) CodeRenderer {
+    python, err := pythonFor(bin, chosen)
+    if err != nil {
+        verbosef("not keeping a highlighter running: %v", err)
+        return pygmentizeCommand(bin)
+    }
+    h := &highlighter{fallback: pygmentizeCommand(bin)}
+    h.cmd = exec.Command(python, "-c", highlightScript)
+    if h.in, err = h.cmd.StdinPipe(); err == nil {
+        var out io.Reader
+        if out, err = h.cmd.StdoutPipe(); err == nil {
+            h.out = bufio.NewReader(out)
+            err = h.cmd.Start()
+        }
+    }
+    if err == nil {
+        _, err = h.reply("ready")
+    }
+    if err != nil {
+        verbosef("not keeping a highlighter running: %v", err)
+        h.stop()
+        return pygmentizeCommand(bin)
+    }
+    return h
+}
+
+// Find the Python that `pygmentize` runs under from its `#!` line,
+// which tells us which installation of Pygments to use. Wrappers that
+// aren't Python scripts, like version manager shims, leave us with
+// whichever `python3` is on the `PATH`, unless the wrapper was chosen
+// explicitly, in which case it may be doing more than we know.
+func pythonFor(bin string, chosen bool) (string, error) {
+    f, err := os.Open(bin)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+    line, _ := bufio.NewReader(f).ReadString('\n')
+    if strings.HasPrefix(line, "#!") {
+        fields := strings.Fields(line[2:])
+        if len(fields) == 1 && strings.Contains(fields[0], "python") {
+            return fields[0], nil
+        }
+        if len(fields) == 2 && strings.HasSuffix(fields[0], "/env") && strings.Contains(fields[1], "python") {
+            return exec.LookPath(fields[1])
+        }
+    }
+    if chosen {
+        return "", fmt.Errorf("%s isn't a Python script", bin)
+    }
+    return exec.LookPath("python3")
+}
+
+// Highlight `src` with the lexer `lang` using the running process.
+func (h *highlighter) RenderCode(lang, src string) (string, error) {
+    h.Lock()
+    defer h.Unlock()
+    if h.broken {
+        return h.fallback.RenderCode(lang, src)
+    }
+    var expired atomic.Bool
+    if toolTimeout > 0 {
+        timer := time.AfterFunc(toolTimeout, func() {
+            expired.Store(true)
+            h.cmd.Process.Kill()
+        })
+        defer timer.Stop()
+    }
+    _, err := fmt.Fprintf(h.in, "%s\n%d\n%s", lang, len(src), src)
+    var html string
+    if err == nil {
+        html, err = h.reply("ok")
+    }
+    if expired.Load() {
+        h.broken = true
+        h.stop()
+        return "", fmt.Errorf("highlighter timed out after %v", toolTimeout)
+    }
+    if _, failed := err.(highlightError); failed {
+        return "", err
+    }
+    if err != nil {
+        verbosef("highlighter stopped, running pygmentize instead: %v", err)
+        h.broken = true
+        h.stop()
+        return h.fallback.RenderCode(lang, src)
+    }
+    return html, nil
+}
+
+// An error Pygments reported, as opposed to trouble talking to it.
+type highlightError string
+
+func (e highlightError) Error() string {
+    return string(e)
+}
+
+// Read a reply, which should have status `want`.
+func (h *highlighter) reply(want string) (string, error) {
+    var status string
+    var size int
+    if _, err := fmt.Fscanf(h.out, "%s %d\n", &status, &size); err != nil {
+        return "", err
+    }
+    data := make([]byte, size)
+    if _, err := io.ReadFull(h.out, data); err != nil {
+        return "", err
+    }
+    if status == "error" {
+        return "", highlightError(data)
+    }
+    if status != want {
+        return "", fmt.Errorf("unexpected reply %q", status)
+    }
+    return string(data), nil
+}
+
+func (h *highlighter) stop() {
+    if h.in != nil {
+        h.in.Close()
+    }
+    if h.cmd.Process != nil {
+        h.cmd.Process.Kill()
+        h.cmd.Wait()
+    }
+}
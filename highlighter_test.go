@@ -0,0 +1,78 @@
+package main
+
+import (
+    "os/exec"
+    "strings"
+    "testing"
+)
+
+// Start a highlighter, or skip without Pygments or a Python to keep
+// one running in.
+func startTestHighlighter(t testing.TB) (*highlighter, pygmentizeCommand) {
+    path, err := exec.LookPath("pygmentize")
+    if err != nil {
+        t.Skip("no pygmentize")
+    }
+    h, ok := startHighlighter(path, false).(*highlighter)
+    if !ok {
+        t.Skip("can't keep a highlighter running")
+    }
+    t.Cleanup(h.stop)
+    return h, pygmentizeCommand(path)
+}
+
+// The running highlighter gives what `pygmentize` does, for code with
+// anything in it, including what looks like framing.
+func TestHighlighter(t *testing.T) {
+    h, command := startTestHighlighter(t)
+    for _, src := range []string{
+        "func main() {}\n",
+        "",
+        "s := \"ok 12\\nerror 3\\n\"\n",
+        "// ünïcödé ☃\nvar x = `\n\n`\n",
+        strings.Repeat("x := 1\n", 10000),
+    } {
+        got, err := h.RenderCode("go", src)
+        if err != nil {
+            t.Fatal(err)
+        }
+        want, err := command.RenderCode("go", src)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got != want {
+            t.Errorf("highlighting %.20q gave %.60q, want %.60q", src, got, want)
+        }
+    }
+}
+
+// An unknown lexer is an error, and the highlighter carries on.
+func TestHighlighterError(t *testing.T) {
+    h, _ := startTestHighlighter(t)
+    if _, err := h.RenderCode("no-such-lexer", "x"); err == nil {
+        t.Error("no error for an unknown lexer")
+    }
+    if _, err := h.RenderCode("go", "x := 1\n"); err != nil || h.broken {
+        t.Errorf("highlighter broke after an error: %v", err)
+    }
+}
+
+func BenchmarkPygmentizeCommand(b *testing.B) {
+    _, command := startTestHighlighter(b)
+    b.ResetTimer()
+    for n := 0; n < b.N; n++ {
+        if _, err := command.RenderCode("go", "func main() {}\n"); err != nil {
+            b.Fatal(err)
+        }
+    }
+}
+
+func BenchmarkHighlighter(b *testing.B) {
+    h, _ := startTestHighlighter(b)
+    b.ResetTimer()
+    for n := 0; n < b.N; n++ {
+        if _, err := h.RenderCode("go", "func main() {}\n"); err != nil {
+            b.Fatal(err)
+        }
+    }
+}
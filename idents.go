@@ -0,0 +1,146 @@
+// ### Identifier links
+
+// Reading the end of a file often means going back up to find where
+// a helper was declared. So in pages of Go, each use of a name
+// declared at the top level of the same file links to the segment
+// declaring it. The parser tells us where the uses are, by line and
+// column, and we find them in the highlighted code by counting our
+// way through its text, which is the source's, leaving the markup
+// alone. If a use isn't where we expect, because the highlighter
+// changed the text somehow, the segment is left as it was rather than
+// linked wrongly. Names from other files and packages aren't linked.
+// `--no-ident-links` turns this off.
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "go/ast"
+    "go/parser"
+    "go/token"
+    "html"
+    "path/filepath"
+    "strings"
+)
+
+var noIdentLinks bool
+
+func init() {
+    flag.BoolVar(&noIdentLinks, "no-ident-links", false, "don't link uses of a Go file's top-level names to where they're declared")
+}
+
+// Where a use of a name starts in the source.
+type identPos struct {
+    line, col int
+}
+
+// What to do with a name in the code: link it to `href`, as a link
+// of the `class` given, give it a `title`, or both.
+type codeMark struct {
+    name, class, href, title string
+}
+
+// Find the uses of top-level names in the Go file at `sourcePath`
+// outside the segments declaring them, or none if it doesn't parse.
+func identUses(sourcePath string, src []byte, segs []*seg) map[identPos]codeMark {
+    if noIdentLinks || docsOnly || filepath.Ext(sourcePath) != ".go" {
+        return nil
+    }
+    fset := token.NewFileSet()
+    file, err := parser.ParseFile(fset, "", src, 0)
+    if err != nil {
+        return nil
+    }
+    uses := map[identPos]codeMark{}
+    ast.Inspect(file, func(n ast.Node) bool {
+        ident, ok := n.(*ast.Ident)
+        if !ok || ident.Obj == nil || file.Scope.Lookup(ident.Name) != ident.Obj {
+            return true
+        }
+        decl, ok := ident.Obj.Decl.(ast.Node)
+        if !ok {
+            return true
+        }
+        use, declared := fset.Position(ident.Pos()), fset.Position(decl.Pos())
+        from, to := segmentAt(segs, use.Line), segmentAt(segs, declared.Line)
+        if from != nil && to != nil && from != to {
+            uses[identPos{use.Line, use.Column}] = codeMark{name: ident.Name, class: "ident", href: "#" + to.anchor}
+        }
+        return true
+    })
+    return uses
+}
+
+// Mark the names among `marks` in the highlighted code of `seg`.
+func markCode(seg *seg, marks map[identPos]codeMark) {
+    if len(marks) == 0 || seg.codeLine == 0 {
+        return
+    }
+    want := 0
+    for pos := range marks {
+        if pos.line >= seg.codeLine && pos.line < seg.codeLine+seg.codeLines {
+            want++
+        }
+    }
+    if want == 0 {
+        return
+    }
+    code := seg.codeRendered
+    var out strings.Builder
+    line, col, found := seg.codeLine, 1, 0
+    for i := 0; i < len(code); {
+        switch c := code[i]; {
+        case c == '<':
+            end := strings.IndexByte(code[i:], '>')
+            if end < 0 {
+                return
+            }
+            out.WriteString(code[i : i+end+1])
+            i += end + 1
+            continue
+        case c == '&':
+            end := strings.IndexByte(code[i:], ';')
+            if end < 0 {
+                return
+            }
+            out.WriteString(code[i : i+end+1])
+            col += len(html.UnescapeString(code[i : i+end+1]))
+            i += end + 1
+            continue
+        case c == '\n':
+            out.WriteByte(c)
+            line, col = line+1, 1
+            i++
+            continue
+        }
+        if mark, ok := marks[identPos{line, col}]; ok {
+            if !strings.HasPrefix(code[i:], mark.name) {
+                return
+            }
+            out.WriteString(mark.markup())
+            i += len(mark.name)
+            col += len(mark.name)
+            found++
+            continue
+        }
+        out.WriteByte(code[i])
+        i++
+        col++
+    }
+    if found == want {
+        seg.codeRendered = out.String()
+    }
+}
+
+// The name, marked up.
+func (m codeMark) markup() string {
+    title := ""
+    if m.title != "" {
+        title = fmt.Sprintf(" title=\"%s\"", html.EscapeString(m.title))
+    }
+    if m.href == "" {
+        return fmt.Sprintf("<span class=\"type-info\"%s>%s</span>", title, html.EscapeString(m.name))
+    }
+    return fmt.Sprintf("<a class=\"%s\" href=\"%s\"%s>%s</a>", m.class, html.EscapeString(m.href), title, html.EscapeString(m.name))
+}
@@ -0,0 +1,156 @@
+// ### Import links
+
+// A file's imports say where to read next, so in pages of Go each
+// imported path links to its documentation on pkg.go.dev, or, for a
+// package of the module being built, to our own page for it: its
+// `doc.go`, or else its first file. Vendored packages, and those the
+// `go.mod` replaces with a local directory, aren't what pkg.go.dev
+// would show, so they're left unlinked. The links go in after
+// highlighting, by position, the same way as identifier links.
+
+package main
+
+import (
+    "go/parser"
+    "go/token"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// The page for each directory of a multi-file build, for linking to
+// the package in it.
+var packagePages = map[string]string{}
+
+// Pick the page for each directory of the build from `sources`, in
+// reading order.
+func planPackagePages(sources []string, outputs map[string]string) {
+    for _, source := range sources {
+        abs, err := filepath.Abs(source)
+        if err != nil || filepath.Ext(source) != ".go" || isTestFile(source) {
+            continue
+        }
+        dir := filepath.Dir(abs)
+        if _, ok := packagePages[dir]; !ok || filepath.Base(source) == "doc.go" {
+            packagePages[dir] = outputs[source]
+        }
+    }
+}
+
+// Add links for the imports of the Go file at `sourcePath`, whose
+// page goes to `outPath`, to `marks`.
+func addImportLinks(sourcePath, outPath string, src []byte, marks map[identPos]codeMark) map[identPos]codeMark {
+    if docsOnly || filepath.Ext(sourcePath) != ".go" {
+        return marks
+    }
+    fset := token.NewFileSet()
+    file, err := parser.ParseFile(fset, "", src, parser.ImportsOnly)
+    if err != nil {
+        return marks
+    }
+    module, replaced := modulePath(moduleRoot), localReplacements(moduleRoot)
+    for _, spec := range file.Imports {
+        path, err := strconv.Unquote(spec.Path.Value)
+        if err != nil {
+            continue
+        }
+        href := importURL(path, outPath, module, replaced)
+        if href == "" {
+            continue
+        }
+        if marks == nil {
+            marks = map[identPos]codeMark{}
+        }
+        // The link goes inside the quotes.
+        pos := fset.Position(spec.Path.Pos())
+        marks[identPos{pos.Line, pos.Column + 1}] = codeMark{name: path, class: "import", href: href}
+    }
+    return marks
+}
+
+// The import path of the package in `dir`, or "" if it isn't in a
+// module.
+func importPath(dir string) string {
+    root := findModuleRoot(dir)
+    module := modulePath(root)
+    if module == "" {
+        return ""
+    }
+    rel, err := filepath.Rel(root, dir)
+    if err != nil {
+        return ""
+    }
+    if rel == "." {
+        return module
+    }
+    return module + "/" + filepath.ToSlash(rel)
+}
+
+// Where to link the import `path` from the page at `outPath`, or ""
+// to leave it, when building the `module` whose `go.mod` has local
+// replacements for the paths `replaced`.
+func importURL(path, outPath, module string, replaced []string) string {
+    if path == "C" {
+        return ""
+    }
+    if module != "" {
+        if _, err := os.Stat(filepath.Join(moduleRoot, "vendor", filepath.FromSlash(path))); err == nil {
+            return ""
+        }
+        for _, r := range replaced {
+            if path == r || strings.HasPrefix(path, r+"/") {
+                return ""
+            }
+        }
+        if path == module || strings.HasPrefix(path, module+"/") {
+            dir := filepath.Join(moduleRoot, filepath.FromSlash(strings.TrimPrefix(path[len(module):], "/")))
+            if page, ok := packagePages[dir]; ok && outPath != "" {
+                if rel, err := filepath.Rel(filepath.Dir(outPath), page); err == nil {
+                    return filepath.ToSlash(rel)
+                }
+            }
+        }
+    }
+    return "https://pkg.go.dev/" + path
+}
+
+// The module paths the `go.mod` in `dir` replaces with directories.
+func localReplacements(dir string) []string {
+    if dir == "" {
+        return nil
+    }
+    data, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+    if err != nil {
+        return nil
+    }
+    paths := []string{}
+    block := false
+    for _, line := range strings.Split(string(data), "\n") {
+        fields := strings.Fields(strings.SplitN(line, "//", 2)[0])
+        switch {
+        case len(fields) == 0:
+            continue
+        case block && fields[0] == ")":
+            block = false
+            continue
+        case fields[0] == "replace" && len(fields) > 1 && fields[1] == "(":
+            block = true
+            continue
+        case fields[0] == "replace":
+            fields = fields[1:]
+        case !block:
+            continue
+        }
+        for i, field := range fields {
+            if field == "=>" && i > 0 && i+1 < len(fields) {
+                to := fields[i+1]
+                if strings.HasPrefix(to, "./") || strings.HasPrefix(to, "../") || filepath.IsAbs(to) {
+                    paths = append(paths, fields[0])
+                }
+            }
+        }
+    }
+    return paths
+}
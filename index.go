@@ -0,0 +1,251 @@
+// ### Index
+
+// A multi-file build gets an `index.html` at the top of `--out-dir`
+// listing every page, grouped by directory, with each page's title
+// and the first paragraph of its docs. It's wrapped in the same
+// header and footer as the pages themselves, and all its links are
+// relative so the output can be hosted anywhere or opened straight
+// from disk.
+//
+// Go packages conventionally keep their overview in `doc.go`, or else
+// in the package comment. If the top directory of the build has a
+// `doc.go`, the index links to its page as the "Overview" and, unless
+// the build is of a module, named by its path, its first header names
+// the site. Otherwise the index opens with the package comment itself.
+
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "go/parser"
+    "go/token"
+    "html"
+    "html/template"
+    "io/ioutil"
+    "path"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// The name of the whole build: the module path in module mode, or
+// else the name of the directory the sources share.
+var siteName string
+
+// The page for the top directory's `doc.go`, if there is one, or
+// else the text of the first package comment found there.
+var overviewPage, overviewDocs string
+
+// The index pages the build will write, by path. Breadcrumbs only
+// link to directories that have one.
+var indexPages = map[string]bool{}
+
+// Find the module path declared in the `go.mod` in `dir`, or "".
+var modulePat = regexp.MustCompile(`(?m)^module\s+"?([^"\s]+)"?`)
+
+func modulePath(dir string) string {
+    if dir == "" {
+        return ""
+    }
+    data, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+    if err != nil {
+        return ""
+    }
+    if match := modulePat.FindSubmatch(data); match != nil {
+        return string(match[1])
+    }
+    return ""
+}
+
+// Find the overview for the top directory `root` of the build: its
+// `doc.go` page, or the first package comment in its sources.
+func planOverview(sources []string, outputs map[string]string, root string) error {
+    for _, source := range sources {
+        abs, err := filepath.Abs(source)
+        if err != nil {
+            return err
+        }
+        if filepath.Dir(abs) != root || filepath.Base(abs) != "doc.go" {
+            continue
+        }
+        overviewPage = outputs[source]
+        src, err := ioutil.ReadFile(source)
+        if err != nil {
+            return err
+        }
+        // A module is named by its path.
+        if modulePath(moduleRoot) != "" {
+            return nil
+        }
+        for _, line := range strings.Split(string(src), "\n") {
+            if headerPat.MatchString(line) {
+                siteName = strings.TrimSpace(strings.TrimLeft(docsPat.ReplaceAllString(line, ""), "#"))
+                break
+            }
+        }
+        return nil
+    }
+    for _, source := range sources {
+        abs, err := filepath.Abs(source)
+        if err != nil {
+            return err
+        }
+        if filepath.Dir(abs) != root || filepath.Ext(abs) != ".go" {
+            continue
+        }
+        file, err := parser.ParseFile(token.NewFileSet(), source, nil, parser.PackageClauseOnly|parser.ParseComments)
+        if err == nil && file.Doc != nil {
+            overviewDocs = file.Doc.Text()
+            return nil
+        }
+    }
+    return nil
+}
+
+// Pull the first rendered paragraph of docs out of the segments, as a
+// one-line summary for the index. Headers don't count.
+var paragraphPat = regexp.MustCompile("(?s)<p>(.*?)</p>")
+
+func summarize(segs []*seg) string {
+    for _, seg := range segs {
+        if summary := firstParagraph(template.HTML(seg.docsRendered)); summary != "" {
+            return string(summary)
+        }
+    }
+    return ""
+}
+
+// Write `index.html` for the pages of a multi-file build.
+func writeIndex(pages []pageInfo, css string) error {
+    indexPath := filepath.Join(outDir, "index.html")
+    title := siteName
+
+    // Group pages by the directory they're in, relative to the top
+    // of the output, with Markdown documents in a group of their own.
+    // Groups and the pages in them keep the build's reading order.
+    groups := map[string][]pageInfo{}
+    dirs := []string{}
+    documents := []pageInfo{}
+    for _, page := range pages {
+        rel, err := filepath.Rel(outDir, page.output)
+        if err != nil {
+            return err
+        }
+        page.output = filepath.ToSlash(rel)
+        if page.document {
+            documents = append(documents, page)
+            continue
+        }
+        dir := path.Dir(page.output)
+        if _, ok := groups[dir]; !ok {
+            dirs = append(dirs, dir)
+        }
+        groups[dir] = append(groups[dir], page)
+    }
+
+    overview := ""
+    if overviewPage != "" {
+        rel, err := filepath.Rel(outDir, overviewPage)
+        if err != nil {
+            return err
+        }
+        overview = fmt.Sprintf("<p><a href=\"%s\">Overview</a></p>\n", html.EscapeString(filepath.ToSlash(rel)))
+    } else if overviewDocs != "" {
+        rendered, err := docRenderer.RenderDocs(overviewDocs)
+        if err != nil {
+            return err
+        }
+        overview = rendered
+    }
+
+    if writesDependencies() {
+        overview += fmt.Sprintf("<p><a href=\"%s\">Package dependencies</a></p>\n", dependenciesName)
+    }
+
+    // The search box is in the sidebar, if there is one.
+    box := ""
+    if !showNav {
+        box = searchBox(indexPath)
+    }
+    rows := []pageSegment{{DocsHTML: template.HTML(fmt.Sprintf("<h1>%s</h1>\n%s%s", html.EscapeString(title), box, overview))}}
+    if len(documents) > 0 {
+        rows = append(rows, indexGroup("Documents", documents))
+    }
+    for _, dir := range dirs {
+        heading := dir
+        if dir == "." {
+            heading = title
+        }
+        // External test packages, `package foo_test`, belong with
+        // `foo` rather than getting a heading of their own.
+        pkg := ""
+        for _, page := range groups[dir] {
+            if pkg == "" || !page.test {
+                pkg = strings.TrimSuffix(page.pkg, "_test")
+            }
+            if !page.test {
+                break
+            }
+        }
+        if pkg != "" && pkg != path.Base(heading) {
+            heading += " (package " + pkg + ")"
+        }
+        rows = append(rows, indexGroup(heading, groups[dir]))
+    }
+
+    var out bytes.Buffer
+    p := page{Title: title, CSS: template.HTML(css), Nav: template.HTML(renderNav(indexPath)), path: indexPath}
+    err := writePage(&out, p, func(emit func(pageSegment) error) error {
+        for _, row := range rows {
+            if err := emit(row); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(indexPath, out.Bytes())
+}
+
+// One group of the index: a heading and a list of its pages.
+func indexGroup(heading string, pages []pageInfo) pageSegment {
+    var out bytes.Buffer
+    fmt.Fprintf(&out, "<h2>%s</h2>\n<ul>\n", html.EscapeString(heading))
+    for _, page := range pages {
+        fmt.Fprintf(&out, "<li><a href=\"%s\">%s</a>", html.EscapeString(page.output), html.EscapeString(page.title))
+        if page.test {
+            fmt.Fprint(&out, ` <span class="badge">test</span>`)
+        }
+        if page.summary != "" {
+            fmt.Fprintf(&out, "<br>%s", rebaseLinks(page.summary, page.output))
+        }
+        fmt.Fprint(&out, "</li>\n")
+    }
+    fmt.Fprint(&out, "</ul>\n")
+    return pageSegment{DocsHTML: template.HTML(out.String())}
+}
+
+// Make the relative links in `docsHTML`, from the page at `from`,
+// relative to the top of the output instead, where the index is.
+var linkAttrPat = regexp.MustCompile(`(href|src)="([^"]*)"`)
+
+func rebaseLinks(docsHTML, from string) string {
+    return linkAttrPat.ReplaceAllStringFunc(docsHTML, func(attr string) string {
+        match := linkAttrPat.FindStringSubmatch(attr)
+        link := html.UnescapeString(match[2])
+        if strings.HasPrefix(link, "/") || strings.Contains(strings.SplitN(link, "/", 2)[0], ":") {
+            return attr
+        }
+        target, fragment := link, ""
+        if i := strings.Index(link, "#"); i >= 0 {
+            target, fragment = link[:i], link[i:]
+        }
+        if target == "" {
+            target = path.Base(from)
+        }
+        return match[1] + `="` + html.EscapeString(path.Join(path.Dir(from), target)+fragment) + `"`
+    })
+}
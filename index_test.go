@@ -0,0 +1,59 @@
+package main
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "regexp"
+    "testing"
+)
+
+func TestRebaseLinks(t *testing.T) {
+    cases := []struct {
+        from, in, want string
+    }{
+        {"docs/design.html", `<a href="../README.html">`, `<a href="README.html">`},
+        {"docs/design.html", `<a href="#design">`, `<a href="docs/design.html#design">`},
+        {"docs/design.html", `<a href="api.html#L3">`, `<a href="docs/api.html#L3">`},
+        {"docs/design.html", `<img src="../img/arch.png">`, `<img src="img/arch.png">`},
+        {"docs/design.html", `<a href="https://go.dev/">`, `<a href="https://go.dev/">`},
+        {"docs/design.html", `<a href="/abs.html">`, `<a href="/abs.html">`},
+        {"a.html", `<a href="b.html">`, `<a href="b.html">`},
+    }
+    for _, c := range cases {
+        if got := rebaseLinks(c.in, c.from); got != c.want {
+            t.Errorf("rebaseLinks(%q, %q) = %q, want %q", c.in, c.from, got, c.want)
+        }
+    }
+}
+
+var titlePat = regexp.MustCompile(`<title>([^<]*)</title>`)
+
+// A module's site is named by its path, even with a doc.go, whose
+// first header would otherwise name it.
+func TestSiteName(t *testing.T) {
+    files := map[string]string{
+        "doc.go": "// # Getting started\n\n// Package m does things.\npackage m\n",
+        "m.go":   "// Code.\npackage m\n",
+    }
+    for _, c := range []struct {
+        goMod, want string
+    }{
+        {"", "Getting started"},
+        {"module example.com/m\n", "example.com/m"},
+    } {
+        if c.goMod != "" {
+            files["go.mod"] = c.goMod
+        }
+        dir := writeFiles(t, files)
+        if _, stderr, code := runGolit(t, dir, "--out-dir", "out", "./..."); code != 0 {
+            t.Fatalf("exit %d: %s", code, stderr)
+        }
+        index, err := ioutil.ReadFile(filepath.Join(dir, "out", "index.html"))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if match := titlePat.FindSubmatch(index); match == nil || string(match[1]) != c.want {
+            t.Errorf("with go.mod %q, index title = %q, want %q", c.goMod, match, c.want)
+        }
+    }
+}
@@ -0,0 +1,506 @@
+// ### Inputs
+
+// Source arguments may be glob patterns as well as plain paths, so
+// `golit 'pkg/**/*.go' --out-dir docs` works the same whatever the
+// shell does with wildcards. We understand `*`, `?`, and `[...]` as
+// `filepath.Match` does, plus `**` for any number of directories.
+//
+// A directory stands for the Go files of the package in it, along
+// with any Markdown documents like its README, so
+// `golit ./mypkg --out-dir docs` documents the whole package. And as
+// with `go build`, a trailing `/...` means every package below, so
+// `golit ./... --out-dir site` documents a whole module.
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// Patterns from `--exclude` naming files to leave out.
+var excludes stringList
+
+// The reading order for a multi-file build, from `--order`: either a
+// comma-separated list of paths, or a file listing one per line.
+var order string
+
+// With `--include-tests`, directory and module modes take in
+// `_test.go` files too.
+var includeTests bool
+
+// Whether directory and module modes take in `.md` files.
+var markdownFiles = true
+
+func init() {
+    flag.BoolVar(&includeTests, "include-tests", false, "include _test.go files in directory and module modes")
+    flag.BoolVar(&markdownFiles, "markdown-files", markdownFiles, "include .md documents in directory and module modes")
+    flag.Var(&excludes, "exclude", "skip inputs matching glob `pattern`; may be repeated")
+    flag.StringVar(&order, "order", "", "comma-separated `paths`, or a file listing them, to put first in reading order")
+}
+
+// In module mode, the root of the module we're documenting. Output
+// paths are laid out relative to it, so that a package's import path
+// maps onto its pages' URLs.
+var moduleRoot string
+
+// Expand each argument into the files it names, in order. A path
+// that exists is taken literally even if it contains glob characters.
+// Each pattern's matches are sorted, and files named more than once
+// only appear the first time.
+func expandInputs(args []string) ([]string, error) {
+    files := []string{}
+    seen := map[string]bool{}
+    add := func(path string) {
+        clean := filepath.Clean(path)
+        if !seen[clean] {
+            seen[clean] = true
+            files = append(files, path)
+        }
+    }
+    for _, arg := range args {
+        if arg == "..." || strings.HasSuffix(arg, "/...") {
+            dir := strings.TrimSuffix(strings.TrimSuffix(arg, "..."), "/")
+            if dir == "" {
+                dir = "."
+            }
+            if moduleRoot == "" {
+                moduleRoot = findModuleRoot(dir)
+            }
+            treeFiles, err := walkPackages(dir)
+            if err != nil {
+                return nil, err
+            }
+            if len(treeFiles) == 0 {
+                if err := warn("%s matched no Go files", arg); err != nil {
+                    return nil, err
+                }
+            }
+            for _, path := range treeFiles {
+                add(path)
+            }
+            continue
+        }
+        if info, err := os.Stat(arg); err == nil && info.IsDir() {
+            dirFiles, err := packageFiles(arg)
+            if err != nil {
+                return nil, err
+            }
+            if len(dirFiles) == 0 {
+                if err := warn("directory %s has no Go files", arg); err != nil {
+                    return nil, err
+                }
+            }
+            for _, path := range dirFiles {
+                add(path)
+            }
+            continue
+        }
+        if _, err := os.Stat(arg); err == nil || arg == "-" || !isPattern(arg) {
+            add(arg)
+            continue
+        }
+        matches, err := glob(arg)
+        if err != nil {
+            return nil, err
+        }
+        if len(matches) == 0 {
+            if err := warn("pattern %q matched no files", arg); err != nil {
+                return nil, err
+            }
+        }
+        for _, match := range matches {
+            add(match)
+        }
+    }
+    return files, nil
+}
+
+// List the `.go` and `.md` files in `dir`, sorted by name, leaving
+// out tests unless we've been asked for them. A directory of nothing
+// but documents isn't a package, so it has no files for us at all.
+// We deliberately keep hidden files and files whose build constraints
+// rule them out on this platform: we're writing documentation, not
+// compiling, and a `_windows.go` file is worth reading on Linux too.
+func packageFiles(dir string) ([]string, error) {
+    entries, err := ioutil.ReadDir(dir)
+    if err != nil {
+        return nil, err
+    }
+    files := []string{}
+    hasGo := false
+    for _, entry := range entries {
+        name := entry.Name()
+        if isTestFile(name) && !includeTests {
+            continue
+        }
+        if filepath.Ext(name) != ".go" && !(isMarkdownFile(name) && markdownFiles) {
+            continue
+        }
+        if !entry.Mode().IsRegular() {
+            err := report(Diagnostic{Path: filepath.Join(dir, name), Message: "skipping, not a regular file"})
+            if err != nil {
+                return nil, err
+            }
+            continue
+        }
+        files = append(files, filepath.Join(dir, name))
+        hasGo = hasGo || filepath.Ext(name) == ".go"
+    }
+    if !hasGo {
+        return nil, nil
+    }
+    return files, nil
+}
+
+// Find the Go files of every package in the tree below `dir`, with
+// their documents. Like the go command we skip `vendor` and
+// `testdata` directories, hidden ones, nested modules, and those with
+// no Go files. Unlike `filepath.Walk` we follow
+// symlinked directories, remembering where we've been so that a link
+// back up the tree can't send us round in circles.
+func walkPackages(dir string) ([]string, error) {
+    files := []string{}
+    visited := map[string]bool{}
+    var walk func(dir string, top bool) error
+    walk = func(dir string, top bool) error {
+        real, err := filepath.EvalSymlinks(dir)
+        if err != nil {
+            return err
+        }
+        if real, err = filepath.Abs(real); err != nil {
+            return err
+        }
+        if visited[real] {
+            return nil
+        }
+        visited[real] = true
+        if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !top {
+            return nil
+        }
+        dirFiles, err := packageFiles(dir)
+        if err != nil {
+            return err
+        }
+        files = append(files, dirFiles...)
+        entries, err := ioutil.ReadDir(dir)
+        if err != nil {
+            return err
+        }
+        for _, entry := range entries {
+            name := entry.Name()
+            if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "vendor" || name == "testdata" {
+                continue
+            }
+            path := filepath.Join(dir, name)
+            if info, err := os.Stat(path); err != nil || !info.IsDir() {
+                continue
+            }
+            if err := walk(path, false); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    return files, walk(dir, true)
+}
+
+// Find the module containing `dir` by looking upwards for `go.mod`.
+// Returns "" if there's none.
+func findModuleRoot(dir string) string {
+    abs, err := filepath.Abs(dir)
+    if err != nil {
+        return ""
+    }
+    for {
+        if _, err := os.Stat(filepath.Join(abs, "go.mod")); err == nil {
+            return abs
+        }
+        parent := filepath.Dir(abs)
+        if parent == abs {
+            return ""
+        }
+        abs = parent
+    }
+}
+
+func isMarkdownFile(path string) bool {
+    ext := strings.ToLower(filepath.Ext(path))
+    return ext == ".md" || ext == ".markdown"
+}
+
+func isTestFile(path string) bool {
+    return strings.HasSuffix(path, "_test.go")
+}
+
+func isPattern(arg string) bool {
+    return strings.ContainsAny(arg, "*?[")
+}
+
+// Find the regular files matching `pattern`, sorted. Patterns without
+// `**` are handed to `filepath.Glob`; for the rest we walk the tree
+// below the pattern's literal leading directories.
+func glob(pattern string) ([]string, error) {
+    var matches []string
+    if !strings.Contains(pattern, "**") {
+        found, err := filepath.Glob(pattern)
+        if err != nil {
+            return nil, err
+        }
+        for _, path := range found {
+            if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+                matches = append(matches, path)
+            }
+        }
+        return matches, nil
+    }
+
+    parts := strings.Split(filepath.ToSlash(pattern), "/")
+    rootParts := []string{}
+    for len(parts) > 0 && !isPattern(parts[0]) {
+        rootParts = append(rootParts, parts[0])
+        parts = parts[1:]
+    }
+    root := strings.Join(rootParts, "/")
+    if root == "" && len(rootParts) > 0 {
+        root = "/"
+    } else if root == "" {
+        root = "."
+    }
+    for _, part := range parts {
+        if _, err := filepath.Match(part, ""); err != nil && part != "**" {
+            return nil, fmt.Errorf("bad pattern %q: %v", pattern, err)
+        }
+    }
+
+    err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if !info.Mode().IsRegular() {
+            return nil
+        }
+        rel, err := filepath.Rel(root, path)
+        if err != nil {
+            return err
+        }
+        if matchParts(parts, strings.Split(filepath.ToSlash(rel), "/")) {
+            matches = append(matches, path)
+        }
+        return nil
+    })
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    sort.Strings(matches)
+    return matches, err
+}
+
+// Match path segments against pattern segments, where a `**` segment
+// stands for zero or more path segments.
+func matchParts(pattern, path []string) bool {
+    if len(pattern) == 0 {
+        return len(path) == 0
+    }
+    if pattern[0] == "**" {
+        for i := 0; i <= len(path); i++ {
+            if matchParts(pattern[1:], path[i:]) {
+                return true
+            }
+        }
+        return false
+    }
+    if len(path) == 0 {
+        return false
+    }
+    if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+        return false
+    }
+    return matchParts(pattern[1:], path[1:])
+}
+
+// Drop the files matching any of the `--exclude` patterns. Patterns
+// are matched against each path relative to the inputs' common root;
+// a pattern with no `/` in it, like `*_string.go`, is matched against
+// just the file name, wherever the file is.
+func excludeInputs(files []string, patterns []string) ([]string, error) {
+    if len(patterns) == 0 {
+        return files, nil
+    }
+    root, err := commonRoot(files)
+    if err != nil {
+        return nil, err
+    }
+    kept := []string{}
+    for _, path := range files {
+        abs, err := filepath.Abs(path)
+        if err != nil {
+            return nil, err
+        }
+        rel, err := filepath.Rel(root, abs)
+        if err != nil {
+            return nil, err
+        }
+        if pattern, ok := excluded(filepath.ToSlash(rel), patterns); ok {
+            verbosef("excluding %s (matches %q)", path, pattern)
+            continue
+        }
+        kept = append(kept, path)
+    }
+    return kept, nil
+}
+
+// Report whether the slash-separated relative path `rel` matches one
+// of `patterns`, and which.
+func excluded(rel string, patterns []string) (string, bool) {
+    parts := strings.Split(rel, "/")
+    for _, pattern := range patterns {
+        pattern = filepath.ToSlash(pattern)
+        if !strings.Contains(pattern, "/") {
+            if ok, _ := filepath.Match(pattern, parts[len(parts)-1]); ok {
+                return pattern, true
+            }
+            continue
+        }
+        if matchParts(strings.Split(strings.TrimPrefix(pattern, "./"), "/"), parts) {
+            return pattern, true
+        }
+    }
+    return "", false
+}
+
+// The directory our output tree corresponds to: the module root in
+// module mode, otherwise the inputs' common root.
+func sourceRoot(files []string) (string, error) {
+    root, err := commonRoot(files)
+    if err != nil {
+        return "", err
+    }
+    if moduleRoot != "" && (root == moduleRoot || strings.HasPrefix(root, moduleRoot+string(filepath.Separator))) {
+        root = moduleRoot
+    }
+    return root, nil
+}
+
+// Find the deepest directory containing all of `files`, as an
+// absolute path.
+func commonRoot(files []string) (string, error) {
+    root := ""
+    for _, path := range files {
+        abs, err := filepath.Abs(path)
+        if err != nil {
+            return "", err
+        }
+        dir := filepath.Dir(abs)
+        if root == "" {
+            root = dir
+            continue
+        }
+        for root != dir && !strings.HasPrefix(dir, root+string(filepath.Separator)) {
+            parent := filepath.Dir(root)
+            if parent == root {
+                break
+            }
+            root = parent
+        }
+    }
+    return root, nil
+}
+
+// Work out where each source's page goes under `dir`: at the same
+// path relative to `dir` as the source has to the inputs' common
+// root, or the module root in module mode, with its extension
+// swapped for `.html`. Two sources that would land on the same page,
+// like `api.go` and `api.js`, are an error.
+func outputPaths(files []string, dir string) (map[string]string, error) {
+    root, err := sourceRoot(files)
+    if err != nil {
+        return nil, err
+    }
+    outputs := map[string]string{}
+    claimed := map[string]string{}
+    for _, path := range files {
+        abs, err := filepath.Abs(path)
+        if err != nil {
+            return nil, err
+        }
+        rel, err := filepath.Rel(root, abs)
+        if err != nil {
+            return nil, err
+        }
+        out := filepath.Join(dir, strings.TrimSuffix(rel, filepath.Ext(rel))+".html")
+        if other, ok := claimed[out]; ok {
+            return nil, fmt.Errorf("%s and %s would both be written to %s", other, path, out)
+        }
+        claimed[out] = path
+        outputs[path] = out
+    }
+    return outputs, nil
+}
+
+// Put `files` in the order given by `--order`. Listed paths come
+// first, in the order listed, relative to the inputs' common root;
+// the remaining files follow alphabetically. Listed paths that don't
+// match any input are worth a warning but no more.
+func orderInputs(files []string, order string) ([]string, error) {
+    if order == "" {
+        return files, nil
+    }
+    entries := strings.Split(order, ",")
+    if info, err := os.Stat(order); err == nil && info.Mode().IsRegular() {
+        data, err := ioutil.ReadFile(order)
+        if err != nil {
+            return nil, err
+        }
+        entries = strings.Split(string(data), "\n")
+    }
+
+    root, err := sourceRoot(files)
+    if err != nil {
+        return nil, err
+    }
+    byRel := map[string]string{}
+    rels := []string{}
+    for _, path := range files {
+        abs, err := filepath.Abs(path)
+        if err != nil {
+            return nil, err
+        }
+        rel, err := filepath.Rel(root, abs)
+        if err != nil {
+            return nil, err
+        }
+        rel = filepath.ToSlash(rel)
+        byRel[rel] = path
+        rels = append(rels, rel)
+    }
+
+    ordered := []string{}
+    for _, entry := range entries {
+        entry = strings.TrimPrefix(filepath.ToSlash(strings.TrimSpace(entry)), "./")
+        if entry == "" || strings.HasPrefix(entry, "#") {
+            continue
+        }
+        path, ok := byRel[entry]
+        if !ok {
+            if err := warn("--order lists %s, which isn't one of the inputs", entry); err != nil {
+                return nil, err
+            }
+            continue
+        }
+        ordered = append(ordered, path)
+        delete(byRel, entry)
+    }
+    sort.Strings(rels)
+    for _, rel := range rels {
+        if path, ok := byRel[rel]; ok {
+            ordered = append(ordered, path)
+        }
+    }
+    return ordered, nil
+}
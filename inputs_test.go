@@ -0,0 +1,153 @@
+package main
+
+import (
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+// Pages are laid out under the output directory as their sources are
+// under the inputs' common root, however the inputs are named.
+func TestOutputPaths(t *testing.T) {
+    defer func(saved string) { moduleRoot = saved }(moduleRoot)
+    moduleRoot = ""
+    dir := writeFiles(t, map[string]string{"a.go": "", "sub/b.go": "", "sub/deeper/c.go": ""})
+    dir, err := filepath.EvalSymlinks(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    out := "site"
+    cases := []struct {
+        name, cwd string
+        files     []string
+        want      []string
+    }{
+        {"absolute", "/", []string{filepath.Join(dir, "a.go"), filepath.Join(dir, "sub", "b.go")}, []string{"a.html", "sub/b.html"}},
+        {"./-prefixed", dir, []string{"./a.go", "./sub/b.go"}, []string{"a.html", "sub/b.html"}},
+        {"outside the cwd", filepath.Join(dir, "sub"), []string{"../a.go", "b.go", "deeper/c.go"}, []string{"a.html", "sub/b.html", "sub/deeper/c.html"}},
+        {"all below the cwd", dir, []string{"sub/b.go", "sub/deeper/c.go"}, []string{"b.html", "deeper/c.html"}},
+        {"mixed", filepath.Join(dir, "sub"), []string{filepath.Join(dir, "a.go"), "./deeper/c.go"}, []string{"a.html", "sub/deeper/c.html"}},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            t.Chdir(c.cwd)
+            outputs, err := outputPaths(c.files, out)
+            if err != nil {
+                t.Fatal(err)
+            }
+            got := []string{}
+            for _, path := range c.files {
+                rel, err := filepath.Rel(out, outputs[path])
+                if err != nil {
+                    t.Fatal(err)
+                }
+                got = append(got, filepath.ToSlash(rel))
+            }
+            if !reflect.DeepEqual(got, c.want) {
+                t.Errorf("outputPaths(%q) = %q, want %q", c.files, got, c.want)
+            }
+        })
+    }
+}
+
+func TestOutputPathsCollide(t *testing.T) {
+    defer func(saved string) { moduleRoot = saved }(moduleRoot)
+    moduleRoot = ""
+    dir := writeFiles(t, map[string]string{"api.go": "", "api.js": ""})
+    t.Chdir(dir)
+    _, err := outputPaths([]string{"api.go", "api.js"}, "site")
+    if want := "api.go and api.js would both be written to " + filepath.Join("site", "api.html"); err == nil || err.Error() != want {
+        t.Errorf("error = %v, want %s", err, want)
+    }
+}
+
+func TestCommonRoot(t *testing.T) {
+    cases := []struct {
+        files []string
+        want  string
+    }{
+        {[]string{"/a/b/c.go"}, "/a/b"},
+        {[]string{"/a/b/c.go", "/a/b/d/e.go"}, "/a/b"},
+        {[]string{"/a/b/c.go", "/a/x/e.go"}, "/a"},
+        {[]string{"/a/bc/c.go", "/a/b/e.go"}, "/a"},
+        {[]string{"/a/c.go", "/x/e.go"}, "/"},
+    }
+    for _, c := range cases {
+        files := []string{}
+        for _, f := range c.files {
+            files = append(files, filepath.FromSlash(f))
+        }
+        if got, err := commonRoot(files); err != nil || got != filepath.FromSlash(c.want) {
+            t.Errorf("commonRoot(%q) = %q, %v; want %q", c.files, got, err, c.want)
+        }
+    }
+}
+
+// A directory's files are all documented, hidden ones and those build
+// constraints rule out included, with tests only when asked for.
+func TestPackageFiles(t *testing.T) {
+    defer func(saved bool) { includeTests = saved }(includeTests)
+    dir := writeFiles(t, map[string]string{
+        "a.go":         "package p\n",
+        ".hidden.go":   "package p\n",
+        "gen.go":       "//go:build ignore\n\npackage main\n",
+        "a_windows.go": "package p\n",
+        "a_test.go":    "package p\n",
+        "README.md":    "# P\n",
+        "notes.txt":    "not a source\n",
+    })
+    for _, c := range []struct {
+        includeTests bool
+        want         []string
+    }{
+        {false, []string{".hidden.go", "README.md", "a.go", "a_windows.go", "gen.go"}},
+        {true, []string{".hidden.go", "README.md", "a.go", "a_test.go", "a_windows.go", "gen.go"}},
+    } {
+        includeTests = c.includeTests
+        files, err := packageFiles(dir)
+        if err != nil {
+            t.Fatal(err)
+        }
+        got := []string{}
+        for _, path := range files {
+            got = append(got, filepath.Base(path))
+        }
+        if !reflect.DeepEqual(got, c.want) {
+            t.Errorf("with --include-tests=%v, packageFiles = %q, want %q", c.includeTests, got, c.want)
+        }
+    }
+}
+
+// Walking a module takes in its packages and their documents, but not
+// directories of documents alone, or those the go command skips.
+func TestWalkPackages(t *testing.T) {
+    dir := writeFiles(t, map[string]string{
+        "go.mod":           "module example.com/m\n",
+        "README.md":        "# M\n",
+        "m.go":             "package m\n",
+        "docs/guide.md":    "# Guide\n",
+        "docs/more/faq.md": "# FAQ\n",
+        "sub/README.md":    "# Sub\n",
+        "sub/s.go":         "package sub\n",
+        "vendor/v/v.go":    "package v\n",
+        "testdata/t.go":    "package t\n",
+        ".git/g.go":        "package g\n",
+        "nested/go.mod":    "module example.com/n\n",
+        "nested/n.go":      "package n\n",
+    })
+    files, err := walkPackages(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    got := []string{}
+    for _, path := range files {
+        rel, err := filepath.Rel(dir, path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        got = append(got, filepath.ToSlash(rel))
+    }
+    if want := []string{"README.md", "m.go", "sub/README.md", "sub/s.go"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("walkPackages = %q, want %q", got, want)
+    }
+}
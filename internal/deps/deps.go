@@ -0,0 +1,208 @@
+// ### Dependency graph
+
+// The graph of which packages of a module import which, drawn as SVG
+// so that reading it takes nothing but a browser. Each package sits a
+// row above those it imports, so packages importing nothing else of
+// the module are along the bottom, and each links to its page. Go
+// doesn't allow import cycles, but code mid-refactor can have them,
+// so an import that would close one is drawn dashed rather than being
+// allowed to upset the rows.
+
+// Package deps draws the import graph of a module's packages.
+package deps
+
+import (
+    "bytes"
+    "fmt"
+    "go/parser"
+    "go/token"
+    "html"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// A package of the module: its import path, its page, and the
+// packages of the module it imports.
+type Node struct {
+    Path, Page string
+    Imports    []*Node
+    // Its row, counting up from the bottom, and its place in it.
+    layer, x int
+}
+
+// An import from one package of the module to another, and whether
+// it closes a cycle.
+type Edge struct {
+    From, To *Node
+    Cycle    bool
+}
+
+// Find the packages of `module`, at `root`, that the Go files
+// `sources` are in, and the imports between them. `pages` gives the
+// page of each package, by its absolute directory.
+func Graph(module, root string, sources []string, pages map[string]string) ([]*Node, []Edge) {
+    nodes := map[string]*Node{}
+    imports := map[string]map[string]bool{}
+    for _, source := range sources {
+        abs, err := filepath.Abs(source)
+        if err != nil {
+            continue
+        }
+        rel, err := filepath.Rel(root, filepath.Dir(abs))
+        if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+            continue
+        }
+        path := module
+        if rel != "." {
+            path += "/" + filepath.ToSlash(rel)
+        }
+        if nodes[path] == nil {
+            nodes[path] = &Node{Path: path, Page: pages[filepath.Dir(abs)]}
+            imports[path] = map[string]bool{}
+        }
+        file, err := parser.ParseFile(token.NewFileSet(), source, nil, parser.ImportsOnly)
+        if err != nil {
+            continue
+        }
+        for _, spec := range file.Imports {
+            if imported, err := strconv.Unquote(spec.Path.Value); err == nil && imported != path {
+                imports[path][imported] = true
+            }
+        }
+    }
+
+    sorted := []*Node{}
+    for _, node := range nodes {
+        sorted = append(sorted, node)
+    }
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+    for _, node := range sorted {
+        for _, dep := range sorted {
+            if imports[node.Path][dep.Path] {
+                node.Imports = append(node.Imports, dep)
+            }
+        }
+    }
+
+    // Go depth first, leaving out the imports that lead back to a
+    // package we're still in the middle of, and put each package a
+    // row above the highest of the rest.
+    edges := []Edge{}
+    const visiting, visited = 1, 2
+    state := map[*Node]int{}
+    var visit func(node *Node)
+    visit = func(node *Node) {
+        state[node] = visiting
+        for _, dep := range node.Imports {
+            if state[dep] == visiting {
+                edges = append(edges, Edge{node, dep, true})
+                continue
+            }
+            if state[dep] == 0 {
+                visit(dep)
+            }
+            edges = append(edges, Edge{node, dep, false})
+            if dep.layer+1 > node.layer {
+                node.layer = dep.layer + 1
+            }
+        }
+        state[node] = visited
+    }
+    for _, node := range sorted {
+        if state[node] == 0 {
+            visit(node)
+        }
+    }
+    return sorted, edges
+}
+
+// Sizes for drawing the graph.
+const (
+    depCharWidth = 7
+    depBoxHeight = 24
+    depRowHeight = 70
+    depGap       = 20
+    depMargin    = 10
+)
+
+// Draw the graph of `nodes` and `edges` as SVG for the page at
+// `current`, linking each package to its page.
+func Render(nodes []*Node, edges []Edge, current string) string {
+    top := 0
+    for _, node := range nodes {
+        if node.layer > top {
+            top = node.layer
+        }
+    }
+    // Lay each row out left to right, in the order of the average
+    // place of the packages each imports from the row below, so that
+    // lines cross less.
+    rows := make([][]*Node, top+1)
+    for _, node := range nodes {
+        rows[node.layer] = append(rows[node.layer], node)
+    }
+    centers := map[*Node]float64{}
+    width := 0
+    for _, row := range rows {
+        order := map[*Node]float64{}
+        for i, node := range row {
+            order[node] = float64(i)
+            if len(node.Imports) > 0 {
+                sum, n := 0.0, 0
+                for _, dep := range node.Imports {
+                    if c, ok := centers[dep]; ok {
+                        sum, n = sum+c, n+1
+                    }
+                }
+                if n > 0 {
+                    order[node] = sum / float64(n)
+                }
+            }
+        }
+        sort.SliceStable(row, func(i, j int) bool { return order[row[i]] < order[row[j]] })
+        x := depMargin
+        for _, node := range row {
+            node.x = x
+            centers[node] = float64(x + boxWidth(node)/2)
+            x += boxWidth(node) + depGap
+        }
+        if x-depGap+depMargin > width {
+            width = x - depGap + depMargin
+        }
+    }
+    height := (top+1)*depRowHeight - depRowHeight + depBoxHeight + 2*depMargin
+    y := func(node *Node) int { return depMargin + (top-node.layer)*depRowHeight }
+
+    var out bytes.Buffer
+    fmt.Fprintf(&out, "<svg class=\"dependencies\" xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" role=\"img\" aria-label=\"Package dependencies\">\n", width, height, width, height)
+    fmt.Fprint(&out, "<defs><marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"6\" markerHeight=\"6\" orient=\"auto\"><path d=\"M0,0 L10,5 L0,10 z\"/></marker></defs>\n")
+    for _, edge := range edges {
+        class := "import"
+        if edge.Cycle {
+            class = "import cycle"
+        }
+        x1, y1 := edge.From.x+boxWidth(edge.From)/2, y(edge.From)+depBoxHeight
+        x2, y2 := edge.To.x+boxWidth(edge.To)/2, y(edge.To)
+        if edge.Cycle {
+            y1, y2 = y(edge.From), y(edge.To)+depBoxHeight
+        }
+        fmt.Fprintf(&out, "<line class=\"%s\" x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" marker-end=\"url(#arrow)\"/>\n", class, x1, y1, x2, y2)
+    }
+    for _, node := range nodes {
+        box := fmt.Sprintf("<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"3\"/><text x=\"%d\" y=\"%d\">%s</text>",
+            node.x, y(node), boxWidth(node), depBoxHeight, node.x+boxWidth(node)/2, y(node)+depBoxHeight/2+4, html.EscapeString(node.Path))
+        if rel, err := filepath.Rel(filepath.Dir(current), node.Page); node.Page != "" && err == nil {
+            box = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(filepath.ToSlash(rel)), box)
+        }
+        fmt.Fprintf(&out, "<g class=\"package\">%s</g>\n", box)
+    }
+    fmt.Fprint(&out, "</svg>\n")
+    return out.String()
+}
+
+// How wide to draw the box for `node`.
+func boxWidth(node *Node) int {
+    return len(node.Path)*depCharWidth + 2*depMargin
+}
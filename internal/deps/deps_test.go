@@ -0,0 +1,88 @@
+package deps
+
+import (
+    "flag"
+    "io/ioutil"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with what the tests get")
+
+// The fixture module's graph: the command over a, over b, and the
+// cycle between c and d, whose closing import is dashed.
+func fixtureGraph(t *testing.T) ([]*Node, []Edge) {
+    t.Helper()
+    root, err := filepath.Abs(filepath.Join("testdata", "mod"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    sources, err := filepath.Glob(filepath.Join(root, "*", "*.go"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    sources = append(sources, filepath.Join(root, "main.go"), filepath.Join(root, "..", "outside.go"))
+    pages := map[string]string{
+        root:                     "out/main.html",
+        filepath.Join(root, "a"): "out/a/a.html",
+        filepath.Join(root, "b"): "out/b/b.html",
+    }
+    return Graph("example.com/mod", root, sources, pages)
+}
+
+func TestGraph(t *testing.T) {
+    nodes, edges := fixtureGraph(t)
+    got := []string{}
+    layers := []int{}
+    for _, node := range nodes {
+        got = append(got, node.Path+" "+node.Page)
+        layers = append(layers, node.layer)
+    }
+    want := []string{
+        "example.com/mod out/main.html",
+        "example.com/mod/a out/a/a.html",
+        "example.com/mod/b out/b/b.html",
+        "example.com/mod/c ",
+        "example.com/mod/d ",
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("nodes = %q, want %q", got, want)
+    }
+    if want := []int{2, 1, 0, 1, 0}; !reflect.DeepEqual(layers, want) {
+        t.Errorf("layers = %v, want %v", layers, want)
+    }
+    imports := []string{}
+    for _, edge := range edges {
+        imports = append(imports, edge.From.Path+" -> "+edge.To.Path+map[bool]string{true: " (cycle)"}[edge.Cycle])
+    }
+    wantImports := []string{
+        "example.com/mod/a -> example.com/mod/b",
+        "example.com/mod -> example.com/mod/a",
+        "example.com/mod -> example.com/mod/b",
+        "example.com/mod/d -> example.com/mod/c (cycle)",
+        "example.com/mod/c -> example.com/mod/d",
+    }
+    if !reflect.DeepEqual(imports, wantImports) {
+        t.Errorf("edges = %q, want %q", imports, wantImports)
+    }
+}
+
+func TestRender(t *testing.T) {
+    nodes, edges := fixtureGraph(t)
+    got := Render(nodes, edges, "out/dependencies.html")
+    path := filepath.Join("testdata", "graph.svg")
+    if *update {
+        if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+            t.Fatal(err)
+        }
+        return
+    }
+    want, err := ioutil.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got != string(want) {
+        t.Errorf("graph doesn't match %s; rerun with -update if it should:\n%s", path, got)
+    }
+}
@@ -0,0 +1,6 @@
+// Package a builds on b.
+package a
+
+import "example.com/mod/b"
+
+func A() { b.B() }
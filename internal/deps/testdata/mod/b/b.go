@@ -0,0 +1,6 @@
+// Package b imports nothing of the module.
+package b
+
+import "fmt"
+
+func B() { fmt.Println("b") }
@@ -0,0 +1,6 @@
+// Package c and d import each other, mid-refactor.
+package c
+
+import "example.com/mod/d"
+
+func C() { d.D() }
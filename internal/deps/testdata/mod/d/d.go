@@ -0,0 +1,5 @@
+package d
+
+import "example.com/mod/c"
+
+func D() { c.C() }
@@ -0,0 +1,12 @@
+// Command mod uses a and b.
+package main
+
+import (
+    "example.com/mod/a"
+    "example.com/mod/b"
+)
+
+func main() {
+    a.A()
+    b.B()
+}
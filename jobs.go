@@ -0,0 +1,67 @@
+// ### Concurrency
+
+// Rendering is mostly waiting on `markdown` and `pygmentize`, so we
+// keep several going at once. `--jobs N` sets how many: a directory
+// build renders up to N files at a time, each file hands its segments
+// to up to N workers, and however those add up no more than N tool
+// processes run at once. `--jobs 1` does everything in order, one step
+// at a time, which is easiest to follow when debugging.
+
+package main
+
+import (
+    "flag"
+    "runtime"
+    "sync"
+)
+
+var jobs = runtime.GOMAXPROCS(0)
+
+func init() {
+    flag.IntVar(&jobs, "jobs", jobs, "run up to `N` markdown or pygmentize processes at once, across files and the segments within them; 1 renders in order")
+}
+
+// Tokens for running a tool process, taken in `pipe`. It's made once
+// the flags are in.
+var toolSlots chan bool
+
+func startJobs() {
+    toolSlots = make(chan bool, jobs)
+}
+
+// Call `do` for each of `0` to `n-1` using up to `jobs` goroutines,
+// returning the errors by index. With `stop`, the first error means
+// no more calls are started.
+func forEach(n int, stop bool, do func(i int) error) []error {
+    workers := jobs
+    if workers > n {
+        workers = n
+    }
+    indexes := make(chan int)
+    errs := make([]error, n)
+    failed := make(chan bool)
+    var once sync.Once
+    var wg sync.WaitGroup
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range indexes {
+                if errs[i] = do(i); errs[i] != nil && stop {
+                    once.Do(func() { close(failed) })
+                }
+            }
+        }()
+    }
+feed:
+    for i := 0; i < n; i++ {
+        select {
+        case indexes <- i:
+        case <-failed:
+            break feed
+        }
+    }
+    close(indexes)
+    wg.Wait()
+    return errs
+}
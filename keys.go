@@ -0,0 +1,33 @@
+// ### Keyboard navigation
+
+// Long pages are easier to get around with keys: `j` and `k` go to
+// the next and previous segments, `n` and `p` to the next and
+// previous ones with headers, and `t` to the table of contents. Each
+// keeps the hash on the segment it goes to, so the address can be
+// shared. The script finds segments by the start of their anchors,
+// which it's told, and pages read the same without it. `--no-js`
+// leaves out all of golit's scripts, these and the rest, and the
+// copy buttons with them.
+
+package main
+
+import (
+    _ "embed"
+    "flag"
+    "fmt"
+    "strings"
+)
+
+var noJS bool
+
+func init() {
+    flag.BoolVar(&noJS, "no-js", false, "add no scripts to pages, for keys, copy buttons or line ranges")
+}
+
+//go:embed resources/keys.js
+var keysScript string
+
+// The `<script>` for keyboard navigation.
+func keyScripts() string {
+    return "<script>\n" + strings.Replace(keysScript, "ANCHOR_PREFIX", fmt.Sprintf("%q", anchorPrefix), 1) + "</script>\n"
+}
@@ -0,0 +1,87 @@
+// ### License headers
+
+// Many projects start every file with a license, which would otherwise
+// be the first thing on every page. A leading block of comments that
+// reads like one, with an SPDX identifier, "Licensed under" or a
+// copyright notice, is taken out before the file is segmented, and
+// with `--license=hide` dropped, with `fold` shown folded away, or with
+// `show`, the default, left as it is. Only the block the file starts
+// with counts, and only when a blank line separates it from what
+// follows, so a package's doc comment, which runs straight into the
+// `package` clause, is never mistaken for one.
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var license = "show"
+
+func init() {
+    flag.StringVar(&license, "license", license, "hide license headers, fold them away, or show them as docs")
+}
+
+func checkLicense() error {
+    switch license {
+    case "hide", "fold", "show":
+        return nil
+    }
+    return fmt.Errorf("unknown --license %q; use hide, fold or show", license)
+}
+
+var licensePat = regexp.MustCompile(`(?i)SPDX-License-Identifier|Licensed under|Copyright\s+(\(c\)|©|\d{4})`)
+
+// How many of `lines` the license header at the start of them takes
+// up, blank lines after it included, and its text, stripped of its
+// comment markers. Lines of just the marker, `prefix`, are part of it
+// too. It's 0 and "" for files without one.
+func licenseHeader(lines []string, prefix string, docsPat *regexp.Regexp) (int, string) {
+    n := 0
+    docs := []string{}
+    for n < len(lines) {
+        if strings.TrimSpace(lines[n]) == prefix {
+            docs = append(docs, "")
+        } else if strings.TrimSpace(lines[n]) != "" && docsPat.MatchString(lines[n]) {
+            docs = append(docs, docsPat.ReplaceAllString(lines[n], ""))
+        } else {
+            break
+        }
+        n++
+    }
+    if n == 0 || n < len(lines) && strings.TrimSpace(lines[n]) != "" {
+        return 0, ""
+    }
+    text := strings.Join(docs, "\n")
+    if !licensePat.MatchString(text) {
+        return 0, ""
+    }
+    for n < len(lines) && strings.TrimSpace(lines[n]) == "" {
+        n++
+    }
+    return n, text
+}
+
+// Segment `lines` with any license header at their start taken out
+// and, unless it's to be shown, put aside as the second result.
+// Segments keep the line numbers they have in the whole file.
+func segmentLicensed(lines []string, prefix string, docsPat, headerPat *regexp.Regexp) ([]*seg, *seg) {
+    n, text := 0, ""
+    if license != "show" {
+        n, text = licenseHeader(lines, prefix, docsPat)
+    }
+    segs := segment(lines[n:], docsPat, headerPat)
+    if n == 0 {
+        return segs, nil
+    }
+    for _, seg := range segs {
+        seg.line += n
+        if seg.codeLine != 0 {
+            seg.codeLine += n
+        }
+    }
+    return segs, &seg{docs: text, wide: true, line: 1, license: true}
+}
@@ -0,0 +1,100 @@
+// ### Line numbers
+
+// With `--line-numbers`, highlighted code gets a gutter of the lines
+// it has in the source file, so that "line 132" in a review can be
+// found on the page. The gutter is a column of its own beside the
+// code, which readers' selections skip, so copying the code doesn't
+// copy the numbers too. A segment's last line may be one of the
+// trailing spaces we add to keep blank lines, or the end of the file,
+// and gets no number.
+//
+// Each numbered line is wrapped in a `<span>` with an `id` like
+// `L132`, as GitHub does, so `page.html#L132` scrolls to it and
+// highlights it, and each number links there. Highlighters leave
+// spans open across lines, in comments and strings, so those are
+// closed at the end of each line and opened again on the next. A
+// little script highlights ranges, like `#L132-L140`, and
+// shift-clicking a number makes one from the line already picked.
+
+package main
+
+import (
+    _ "embed"
+    "flag"
+    "fmt"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+var lineNumbers bool
+
+func init() {
+    flag.BoolVar(&lineNumbers, "line-numbers", false, "number the lines of code as they are in the source, each with an #L anchor")
+}
+
+//go:embed resources/lines.js
+var linesScript string
+
+// Put the line numbers of `seg` beside its highlighted code, and give
+// each line an `id` starting with `prefix`, which tells apart the
+// files of a single-page build.
+func addLineNumbers(seg *seg, prefix string) {
+    if !lineNumbers || seg.codeLine == 0 || seg.codeRendered == "" {
+        return
+    }
+    wrapper := highlightPat.FindStringSubmatch(seg.codeRendered)
+    if wrapper == nil {
+        return
+    }
+    code := strings.TrimSuffix(wrapper[2], "\n")
+    lines := strings.Split(code, "\n")
+    numbers := make([]string, len(lines))
+    open := []string{}
+    for i, line := range lines {
+        reopened := strings.Join(open, "")
+        open = openSpans(open, line)
+        line = reopened + line + strings.Repeat("</span>", len(open))
+        if i < seg.codeLines {
+            n := seg.codeLine + i
+            id := fmt.Sprintf("%sL%d", prefix, n)
+            numbers[i] = fmt.Sprintf(`<a href="#%s" tabindex="-1">%d</a>`, id, n)
+            line = `<span class="line" id="` + id + `">` + line + "</span>"
+        }
+        lines[i] = line
+    }
+    if len(code) < len(wrapper[2]) {
+        lines = append(lines, "")
+    }
+    seg.codeRendered = `<div class="numbered"><pre class="linenos" aria-hidden="true">` +
+        strings.Join(numbers, "\n") + "</pre>" +
+        wrapper[1] + strings.Join(lines, "\n") + wrapper[3] + "</div>\n"
+}
+
+var spanTagPat = regexp.MustCompile(`<span[^>]*>|</span>`)
+
+// The spans still open after `line`, given those open before it.
+func openSpans(open []string, line string) []string {
+    for _, tag := range spanTagPat.FindAllString(line, -1) {
+        if tag != "</span>" {
+            open = append(open, tag)
+        } else if len(open) > 0 {
+            open = open[:len(open)-1]
+        }
+    }
+    return open
+}
+
+// The prefix for line `id`s from `sourcePath` on a page of many
+// files, like `main-go-` for `main.go`.
+func linePrefix(sourcePath string) string {
+    return Slugify(strings.NewReplacer("/", "-", ".", "-").Replace(filepath.ToSlash(sourcePath))) + "-"
+}
+
+// The script for line ranges, for pages with line numbers.
+func lineScripts() string {
+    if !lineNumbers {
+        return ""
+    }
+    return "<script>\n" + linesScript + "</script>\n"
+}
@@ -0,0 +1,79 @@
+// ### Cross-file links
+
+// Docs often point at neighbouring files, like "see
+// [server.go](server.go) for the listener". In a multi-file build we
+// rewrite such links to point at the generated page for that file
+// instead, keeping any `#anchor`. Links to files we didn't render,
+// and to anything that isn't a relative path, are left alone. A
+// relative link to a file that doesn't exist is reported.
+
+package main
+
+import (
+    "html"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// Every source in a multi-file build, by absolute path, and the page
+// it renders to.
+var pagesBySource = map[string]string{}
+
+var hrefPat = regexp.MustCompile(`href="([^"]*)"`)
+
+// Rewrite the links in rendered docs from `sourcePath`, whose page is
+// written to `outPath`.
+func rewriteLinks(docsHTML, sourcePath, outPath string) string {
+    if len(pagesBySource) == 0 || outPath == "" {
+        return docsHTML
+    }
+    source, err := filepath.Abs(sourcePath)
+    if err != nil {
+        return docsHTML
+    }
+    return hrefPat.ReplaceAllStringFunc(docsHTML, func(attr string) string {
+        href := html.UnescapeString(hrefPat.FindStringSubmatch(attr)[1])
+        if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "/") || strings.Contains(strings.SplitN(href, "/", 2)[0], ":") {
+            return attr
+        }
+        target, fragment := href, ""
+        if i := strings.Index(href, "#"); i >= 0 {
+            target, fragment = href[:i], href[i:]
+        }
+        page, ok := pagesBySource[filepath.Join(filepath.Dir(source), filepath.FromSlash(target))]
+        if !ok {
+            return attr
+        }
+        rel, err := filepath.Rel(filepath.Dir(outPath), page)
+        if err != nil {
+            return attr
+        }
+        return `href="` + html.EscapeString(filepath.ToSlash(rel)+fragment) + `"`
+    })
+}
+
+// Report relative links in rendered docs from `sourcePath` that point
+// at files that aren't there. `line` is where the docs start.
+func checkLinks(docsHTML, sourcePath string, line int) error {
+    if sourcePath == "-" {
+        return nil
+    }
+    for _, match := range hrefPat.FindAllStringSubmatch(docsHTML, -1) {
+        href := html.UnescapeString(match[1])
+        if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "/") || strings.Contains(strings.SplitN(href, "/", 2)[0], ":") {
+            continue
+        }
+        target := strings.SplitN(strings.SplitN(href, "#", 2)[0], "?", 2)[0]
+        if target == "" {
+            continue
+        }
+        if _, err := os.Stat(filepath.Join(filepath.Dir(sourcePath), filepath.FromSlash(target))); err != nil {
+            if err := report(Diagnostic{Path: sourcePath, Line: line, Message: "broken link to " + href}); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
@@ -0,0 +1,36 @@
+// ### Listings
+
+// With `--code-only`, a page is just the source, comments and all,
+// highlighted as one block in a single column. There's no splitting
+// into docs and code at all, so this also suits files with no doc
+// comments to speak of.
+
+package main
+
+import (
+    "flag"
+    "strings"
+)
+
+var codeOnly bool
+
+func init() {
+    flag.BoolVar(&codeOnly, "code-only", false, "render each file as a highlighted listing, without separating out the docs")
+}
+
+// The whole of `src` as a single segment of code.
+func listingSegment(sourcePath string, src []byte) *seg {
+    fileLexer := lexer
+    if fileLexer == "" {
+        fileLexer = lexerFor(sourcePath)
+    }
+    code := strings.TrimRight(string(src), "\n")
+    trimmed := strings.TrimLeft(code, "\n")
+    return &seg{
+        code:      code,
+        lang:      fileLexer,
+        line:      1,
+        codeLine:  1 + len(code) - len(trimmed),
+        codeLines: strings.Count(trimmed, "\n") + 1,
+    }
+}
@@ -15,94 +15,612 @@
 package main
 
 import (
+    "bytes"
+    "context"
+    _ "embed"
+    "errors"
+    "flag"
     "fmt"
+    "go/parser"
+    "go/token"
+    "html"
+    "html/template"
+    "io"
     "io/ioutil"
     "os"
     "os/exec"
+    "os/signal"
+    "path/filepath"
     "regexp"
+    "runtime/debug"
     "strings"
+    "sync/atomic"
+    "syscall"
+    "time"
 )
 
 // ### Usage
 
-// golit takes exactly two arguments: the path to a Go source file and
-// the title for the resulting HTML page. It writes the compiled HTML
-// on stdout.
-var usage = "usage: golit input.go title > output.html"
+// golit takes the path to a Go source file and, optionally, the title
+// for the resulting HTML page. Without a title we make one up from the
+// file's package clause and name. A source path of `-` reads the
+// source from stdin. It writes the compiled HTML on stdout, or to the
+// file named by `-o` if one is given.
+//
+// With `--out-dir`, golit instead takes any number of source files
+// and writes a page for each into that directory. A directory given
+// as a source stands for all the package's non-test Go files. The
+// pages mirror the layout of the sources, so `pkg/a/b.go` and
+// `pkg/c.go` become `a/b.html` and `c.html`. Or with `--single-page`,
+// all the sources go onto one page, one after the other.
+var usage = `usage: golit [-o output.html] input.go [title]
+       golit --out-dir dir input.go|dir...
+       golit --single-page [-o output.html] input.go|dir...`
+
+// Where to write the HTML; empty means stdout. Both `-o` and
+// `--output` set it.
+var outputPath string
+
+// Where to write pages when rendering several files.
+var outDir string
+
+// With `--single-page`, several files go onto one page instead.
+var singlePage bool
+
+// With `--strict`, problems we'd otherwise just warn about are
+// errors.
+var strict bool
+
+// With `--verbose`, say more about what we're doing.
+var verbose bool
+
+// Print the version line and exit, with `--version` or `-v`.
+var showVersion bool
+
+// A flag that may be given more than once, collecting each value in
+// order.
+type stringList []string
+
+func (l *stringList) String() string {
+    return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+    *l = append(*l, value)
+    return nil
+}
+
+// Stylesheets for the page, from `--css`. Each is either a URL or a
+// local file whose contents get inlined, after our bundled copy of the
+// docco stylesheet and its `--theme`, which are inlined too so pages
+// work offline and readers' browsers don't call out to a third-party
+// host.
+var cssFlags stringList
+
+//go:embed resources/docco.css
+var doccoCSS string
+
+// With `--remote-css` and no `--css`, link to the original docco
+// stylesheet instead of inlining ours.
+var remoteCSS bool
+
+var remoteDoccoCSS = "http://jashkenas.github.com/docco/resources/docco.css"
+
+// The line comment marker that introduces docs, from
+// `--comment-prefix`. When it isn't given we use a preset for the
+// source file's extension, falling back to Go's `//`.
+var commentPrefix string
+
+// Specific `markdown` and `pygmentize` executables to use, from
+// `--markdown-bin` and `--pygmentize-bin` or the `GOLIT_MARKDOWN` and
+// `GOLIT_PYGMENTIZE` environment variables. Without them we render
+// Markdown and highlight code ourselves.
+var markdownBin, pygmentizeBin string
+
+// The executables we actually found, shared by every file we render.
+var markdownPath, pygmentizePath string
+
+// How long one run of either tool may take, from `--tool-timeout`,
+// so that a wedged tool fails the build instead of hanging it. Zero
+// means no limit.
+var toolTimeout = 30 * time.Second
+
+// The Pygments lexer used to highlight code, from `--lexer`. When
+// it isn't given we pick one based on the source file's extension.
+var lexer string
+
+func init() {
+    flag.StringVar(&outputPath, "o", "", "write HTML to `file` instead of stdout")
+    flag.StringVar(&outputPath, "output", "", "same as -o")
+    flag.StringVar(&outDir, "out-dir", "", "write a page per input into `dir`")
+    flag.BoolVar(&singlePage, "single-page", false, "render all inputs onto one page")
+    flag.BoolVar(&verbose, "verbose", false, "report more detail about what golit is doing")
+    flag.BoolVar(&strict, "strict", false, "treat warnings as errors")
+    flag.BoolVar(&showVersion, "version", false, "print version information and exit")
+    flag.BoolVar(&showVersion, "v", false, "same as -version")
+    flag.Var(&cssFlags, "css", "stylesheet `URL or file` to link or inline; may be repeated")
+    flag.BoolVar(&remoteCSS, "remote-css", false, "link to the remote docco stylesheet instead of inlining it")
+    flag.StringVar(&markdownBin, "markdown-bin", "", "`path` to a markdown executable to use instead of the built-in renderer (or set GOLIT_MARKDOWN)")
+    flag.StringVar(&pygmentizeBin, "pygmentize-bin", "", "`path` to a pygmentize executable to use instead of the built-in highlighter (or set GOLIT_PYGMENTIZE)")
+    flag.DurationVar(&toolTimeout, "tool-timeout", toolTimeout, "give up on a markdown or pygmentize run after this long; 0 for no limit")
+    flag.StringVar(&commentPrefix, "comment-prefix", "", "line comment `marker` for docs, like # or -- (default from file extension)")
+    flag.StringVar(&lexer, "lexer", "", "Pygments lexer `name` for highlighting code (default from file extension)")
+    flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+    flag.Usage = func() {
+        fmt.Fprintln(os.Stderr, usage)
+        flag.PrintDefaults()
+    }
+}
+
+// The standard `flag` package stops at the first positional argument.
+// We want flags to be accepted anywhere on the command line, so keep
+// parsing after each positional argument we pull off.
+func parseArgs(args []string) ([]string, error) {
+    positional := []string{}
+    for {
+        if err := flag.CommandLine.Parse(args); err != nil {
+            return nil, err
+        }
+        args = flag.Args()
+        if len(args) == 0 {
+            return positional, nil
+        }
+        positional = append(positional, args[0])
+        args = args[1:]
+    }
+}
+
+// ### Version
+
+// Describe the running binary in a single line, like
+// `golit v1.2.0 3f9c2e1... 2024-05-01T12:00:00Z`: the module version,
+// VCS revision, and commit time the Go toolchain embedded at build
+// time, with `unknown` standing in for anything it didn't record.
+func version() string {
+    v, rev, date := "devel", "unknown", "unknown"
+    if info, ok := debug.ReadBuildInfo(); ok {
+        if info.Main.Version != "" && info.Main.Version != "(devel)" {
+            v = info.Main.Version
+        }
+        for _, setting := range info.Settings {
+            switch setting.Key {
+            case "vcs.revision":
+                rev = setting.Value
+            case "vcs.time":
+                date = setting.Value
+            }
+        }
+    }
+    return fmt.Sprintf("golit %s %s %s", v, rev, date)
+}
 
 // ### Helpers
 
-// Panic on non-nil errors. We'll call this after error-returning
-// functions.
-func check(err error) {
-    if err != nil {
-        panic(err)
+// Tell the user what we're up to, when they've asked with
+// `--verbose`.
+func verbosef(format string, args ...interface{}) {
+    if verbose {
+        fmt.Fprintf(os.Stderr, "golit: "+format+"\n", args...)
+    }
+}
+
+// A problem that needn't stop us, like a missing tool we can do
+// without or a link to nowhere, with where it is if that's known.
+type Diagnostic struct {
+    Path    string
+    Line    int
+    Message string
+}
+
+func (d Diagnostic) String() string {
+    switch {
+    case d.Path != "" && d.Line > 0:
+        return fmt.Sprintf("%s:%d: %s", d.Path, d.Line, d.Message)
+    case d.Path != "":
+        return fmt.Sprintf("%s: %s", d.Path, d.Message)
+    }
+    return d.Message
+}
+
+// Report a diagnostic, which is the one place strictness is decided:
+// normally it's printed as a warning and we carry on, but under
+// `--strict` it's an error instead, returned for the caller to deal
+// with.
+func report(d Diagnostic) error {
+    if strict && d.Path != "" {
+        return &sourceError{path: d.Path, index: -1, line: d.Line, err: errors.New(d.Message)}
+    } else if strict {
+        return errors.New(d.Message)
     }
+    fmt.Fprintln(os.Stderr, "golit: warning:", d)
+    return nil
+}
+
+// Report a diagnostic that isn't about any one place.
+func warn(format string, args ...interface{}) error {
+    return report(Diagnostic{Message: fmt.Sprintf(format, args...)})
+}
+
+// How golit exits says what went wrong, so scripts can tell: 1 for a
+// mistake in how it was run, 2 for a problem with its environment,
+// like a missing tool, and 3 for pages that failed to render.
+const (
+    exitUsage  = 1
+    exitEnv    = 2
+    exitRender = 3
+)
+
+// An error that stops us from going on, and the code to exit with.
+// With no `err` there's nothing more to say, because it's been said
+// already.
+type exitError struct {
+    code int
+    err  error
+}
+
+func (e exitError) Error() string {
+    if e.err == nil {
+        return fmt.Sprintf("exit status %d", e.code)
+    }
+    return e.err.Error()
+}
+
+func usageError(err error) error {
+    return exitError{exitUsage, err}
+}
+
+func envError(err error) error {
+    return exitError{exitEnv, err}
 }
 
 // We'll implement Markdown rendering and Pygments syntax highlighting
 // by piping the source data through external programs. This is a
-// general helper for handling both cases.
-func pipe(bin string, arg []string, src string) string {
-    cmd := exec.Command(bin, arg...)
-    in, _ := cmd.StdinPipe()
-    out, _ := cmd.StdoutPipe()
-    cmd.Start()
-    in.Write([]byte(src))
-    in.Close()
-    bytes, _ := ioutil.ReadAll(out)
-    err := cmd.Wait()
-    check(err)
-    return string(bytes)
+// general helper for handling both cases. The input is fed in while
+// the output is read, so a program that starts writing before it has
+// read everything can't leave us both stuck on full pipes. When the
+// program fails, the error gives the command line and what it said on
+// stderr, which is usually the only clue as to why. A run that takes
+// longer than `toolTimeout` is killed, along with anything it started.
+func pipe(bin string, arg []string, src string) (string, error) {
+    ctx := context.Background()
+    if toolTimeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, toolTimeout)
+        defer cancel()
+    }
+    cmd := exec.CommandContext(ctx, bin, arg...)
+    killGroupOnCancel(cmd)
+    cmd.Stdin = strings.NewReader(src)
+    var stderr bytes.Buffer
+    cmd.Stderr = &stderr
+    toolSlots <- true
+    out, err := cmd.Output()
+    <-toolSlots
+    if ctx.Err() == context.DeadlineExceeded {
+        return "", fmt.Errorf("%s: timed out after %v", strings.Join(cmd.Args, " "), toolTimeout)
+    }
+    if err != nil {
+        msg := strings.TrimSpace(stderr.String())
+        if msg == "" {
+            msg = err.Error()
+        }
+        return "", fmt.Errorf("%s: %s", strings.Join(cmd.Args, " "), msg)
+    }
+    return string(out), nil
+}
+
+// Find the executable for one of our external tools. An explicit
+// path from its flag comes first, then its environment variable, then
+// a search of the `PATH` for the tool's usual name. When an explicit
+// path is wrong the error says where it came from.
+func findTool(name, flagName, flagValue, envName string) (string, error) {
+    source, bin := "", name
+    if flagValue != "" {
+        source, bin = "--"+flagName, flagValue
+    } else if env := os.Getenv(envName); env != "" {
+        source, bin = "$"+envName, env
+    }
+    path, err := exec.LookPath(bin)
+    if err != nil && source != "" {
+        return "", fmt.Errorf("%s: %v", source, err)
+    }
+    return path, err
+}
+
+// Read Go source from stdin for the `-` source path. Reading from an
+// interactive terminal would just sit there waiting, so we refuse
+// that up front, and treat empty input as an error too.
+func readStdin() ([]byte, error) {
+    info, err := os.Stdin.Stat()
+    if err != nil {
+        return nil, err
+    }
+    if info.Mode()&os.ModeCharDevice != 0 {
+        return nil, fmt.Errorf("stdin is a terminal; pipe Go source in or give a file path")
+    }
+    src, err := ioutil.ReadAll(os.Stdin)
+    if err != nil {
+        return nil, err
+    }
+    if len(src) == 0 {
+        return nil, fmt.Errorf("no source received on stdin")
+    }
+    return src, nil
+}
+
+// A file written by way of a temporary file in the same directory
+// and a rename, so that a crash part way through never leaves a
+// half-written page behind. Missing parent directories are created.
+type atomicFile struct {
+    *os.File
+    path string
+}
+
+func createAtomic(path string) (*atomicFile, error) {
+    dir := filepath.Dir(path)
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, err
+    }
+    tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".")
+    if err != nil {
+        return nil, err
+    }
+    return &atomicFile{tmp, path}, nil
+}
+
+// Put the file in place, unless `err` says writing it went wrong, in
+// which case it's thrown away.
+func (f *atomicFile) finish(err error) error {
+    if closeErr := f.Close(); err == nil {
+        err = closeErr
+    }
+    if err == nil {
+        err = os.Chmod(f.Name(), 0644)
+    }
+    if err == nil {
+        err = os.Rename(f.Name(), f.path)
+    }
+    if err != nil {
+        os.Remove(f.Name())
+    }
+    return err
+}
+
+// Write `data` to `path` atomically.
+func writeFileAtomic(path string, data []byte) error {
+    f, err := createAtomic(path)
+    if err != nil {
+        return err
+    }
+    _, err = f.Write(data)
+    return f.finish(err)
+}
+
+// Send a page to `outPath`, or to stdout if that's empty, as `write`
+// produces it.
+func writeOutput(outPath string, write func(w io.Writer) error) error {
+    if outPath == "" {
+        return write(os.Stdout)
+    }
+    f, err := createAtomic(outPath)
+    if err != nil {
+        return err
+    }
+    return f.finish(write(f))
+}
+
+// Come up with a page title when none is given on the command line:
+// the package name and base filename, like `mypkg — handlers.go`, or
+// just the filename when the source has no parseable package clause.
+func inferTitle(sourcePath string, src []byte) string {
+    name := filepath.Base(sourcePath)
+    if sourcePath == "-" {
+        name = "stdin"
+    }
+    if pkg := packageName(src); pkg != "" {
+        return pkg + " — " + name
+    }
+    return name
+}
+
+// The name from the source's package clause, or "" if it hasn't got
+// one we can parse.
+func packageName(src []byte) string {
+    file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.PackageClauseOnly)
+    if err != nil || file.Name == nil {
+        return ""
+    }
+    return file.Name.Name
+}
+
+// Build the `<head>` markup for our stylesheets: the built-in one
+// followed by any given, or with `--remote-css` just those given, or
+// the original docco one if there are none. Any `--highlight-style`
+// comes before those given. URLs become `<link>` elements and
+// anything else is read as a local file and inlined in a `<style>`
+// block, so the page doesn't depend on its location.
+func stylesheets(sources []string) (string, error) {
+    out := ""
+    if !remoteCSS {
+        out = fmt.Sprintf("    <style>\n%s\n    </style>\n", strings.TrimRight(themeStylesheet(), "\n"))
+    } else if len(sources) == 0 {
+        sources = []string{remoteDoccoCSS}
+    }
+    if highlightCSS != "" {
+        out += fmt.Sprintf("    <style>\n%s\n    </style>\n", inlineCSS(highlightCSS))
+    }
+    for _, source := range sources {
+        if isURL(source) {
+            out += fmt.Sprintf("    <link rel=\"stylesheet\" href=\"%s\">\n", html.EscapeString(source))
+            continue
+        }
+        css, err := ioutil.ReadFile(source)
+        if err != nil {
+            return "", err
+        }
+        out += fmt.Sprintf("    <style>\n%s\n    </style>\n", inlineCSS(string(css)))
+    }
+    return out, nil
+}
+
+// Ready CSS to go inside a `<style>` element, which would otherwise
+// end early at any `</style` in it. CSS reads `\/` as just `/`.
+func inlineCSS(css string) string {
+    return strings.Replace(strings.TrimRight(css, "\n"), "</", `<\/`, -1)
+}
+
+// Pygments lexer names are short aliases like `go`, `bash`, `c++`,
+// or `html+django`. Anything else is certainly a mistake, and we'd
+// rather say so than pass it to `pygmentize` once per segment.
+var lexerPat = regexp.MustCompile("^[A-Za-z0-9][A-Za-z0-9_+#.-]*$")
+
+// Lexers for the source file extensions we know about.
+var lexersByExt = map[string]string{
+    ".bash":   "bash",
+    ".c":      "c",
+    ".clj":    "clojure",
+    ".coffee": "coffeescript",
+    ".cpp":    "cpp",
+    ".css":    "css",
+    ".go":     "go",
+    ".h":      "c",
+    ".hs":     "haskell",
+    ".html":   "html",
+    ".java":   "java",
+    ".js":     "javascript",
+    ".lua":    "lua",
+    ".py":     "python",
+    ".rb":     "ruby",
+    ".rs":     "rust",
+    ".sh":     "bash",
+    ".sql":    "sql",
+    ".ts":     "typescript",
+    ".yaml":   "yaml",
+    ".yml":    "yaml",
+}
+
+// Pick a lexer from the extension of `path`. Unknown extensions, and
+// files with none, get plain `text` rather than being mis-highlighted
+// as Go. Source on stdin is assumed to be Go.
+func lexerFor(path string) string {
+    if path == "-" {
+        return "go"
+    }
+    if name, ok := lexersByExt[strings.ToLower(filepath.Ext(path))]; ok {
+        return name
+    }
+    return "text"
+}
+
+func validateLexer(name string) error {
+    if !lexerPat.MatchString(name) {
+        return fmt.Errorf("invalid lexer name %q", name)
+    }
+    return nil
 }
 
 // ### Processing
 
-// Recognize doc lines, extract their comment prefixes.
+// Recognize doc lines, extract their comment prefixes. These two
+// patterns are for Go's `//`; `commentPats` makes them for others.
 var docsPat = regexp.MustCompile("^\\s*\\/\\/\\s")
 
 // Recognize header comment lines specially.
 var headerPat = regexp.MustCompile("^\\s*\\/\\/\\s#+\\s")
 
+// Comment markers for languages that don't use `//`.
+var commentPrefixesByExt = map[string]string{
+    ".bash":   "#",
+    ".clj":    ";;",
+    ".coffee": "#",
+    ".hs":     "--",
+    ".lua":    "--",
+    ".py":     "#",
+    ".rb":     "#",
+    ".sh":     "#",
+    ".sql":    "--",
+    ".yaml":   "#",
+    ".yml":    "#",
+}
+
+func commentPrefixFor(path string) string {
+    if prefix, ok := commentPrefixesByExt[strings.ToLower(filepath.Ext(path))]; ok {
+        return prefix
+    }
+    return "//"
+}
+
+// Build the equivalents of `docsPat` and `headerPat` for a different
+// comment marker. The rules stay the same as for `//`: optional
+// leading whitespace, the marker, then at least one whitespace
+// character.
+func commentPats(prefix string) (*regexp.Regexp, *regexp.Regexp, error) {
+    if strings.TrimSpace(prefix) == "" {
+        return nil, nil, fmt.Errorf("empty comment prefix")
+    }
+    quoted := regexp.QuoteMeta(prefix)
+    docs := regexp.MustCompile("^\\s*" + quoted + "\\s")
+    header := regexp.MustCompile("^\\s*" + quoted + "\\s#+\\s")
+    return docs, header, nil
+}
+
 // We'll break the code into `{docs, code}` pairs, and then render
 // those text segments before including them in the HTML doc.
 type seg struct {
     docs, code, docsRendered, codeRendered string
+    // The lexer for highlighting the code.
+    lang string
+    // Whether the docs take up the full width of the page, with no
+    // code at all.
+    wide bool
+    // The `id` of the segment on its page.
+    anchor string
+    // Whether the docs are a header, and the header comments in them,
+    // and how many levels they're moved down on a single page.
+    header       bool
+    headings     []heading
+    headingShift int
+    // The source line the segment starts on, for error messages.
+    line int
+    // The source line its code starts on, once any blank lines that
+    // highlighting drops are skipped, and how many lines there are
+    // from there. A segment with no code has a `codeLine` of 0.
+    codeLine, codeLines int
+    // Where the segment is in the repository, with `--repo-url`, and
+    // the commit that last changed it, with `--blame-links`.
+    sourceURL string
+    commit    *blameCommit
+    // Whether its code is folded away until asked for, and whether
+    // it's a license header.
+    fold, license bool
+    // Whether its code is part of a playground program, and which;
+    // the last segment of a program has the whole of it, and the
+    // link to it once it's shared.
+    playground          bool
+    snippet             string
+    program, programURL string
+    // The output an example gives, and whether in any order, or how
+    // a benchmark did.
+    output     string
+    unordered  bool
+    benchmarks []benchRow
+    // What its docs can link to, as Go doc comments.
+    scope *docScope
 }
 
-func main() {
-    // Accept exactly 2 argument, the source path and page title.
-    if len(os.Args) != 3 {
-        fmt.Fprintln(os.Stderr, usage)
-        os.Exit(1)
-    }
-    sourcePath := os.Args[1]
-    title := os.Args[2]
-
-    // Ensure that we have `markdown` and `pygmentize` binaries,
-    // remember their paths.
-    markdownPath, err := exec.LookPath("markdown")
-    check(err)
-    pygmentizePath, err := exec.LookPath("pygmentize")
-    check(err)
-
-    // Read the source file in, split into lines.
-    srcBytes, err := ioutil.ReadFile(sourcePath)
-    check(err)
-    lines := strings.Split(string(srcBytes), "\n")
-
-    // Group lines into docs/code segments. There are two tricky
-    // aspects to this. First, we want to treat header comments
-    // specially so that they are always in their own segment and
-    // therefore never directly adjacent to any code. Second, we need
-    // to correctly start new segments on certain code/doc boundries
-    // but not on others. In order to handle this later aspect we'll
-    // refer to some state about the previous line and segment when
-    // deciding to handle the current one being processed.
+// Group lines into docs/code segments. There are two tricky
+// aspects to this. First, we want to treat header comments
+// specially so that they are always in their own segment and
+// therefore never directly adjacent to any code. Second, we need
+// to correctly start new segments on certain code/doc boundries
+// but not on others. In order to handle this later aspect we'll
+// refer to some state about the previous line and segment when
+// deciding to handle the current one being processed.
+func segment(lines []string, docsPat, headerPat *regexp.Regexp) []*seg {
     segs := []*seg{}
-    segs = append(segs, &seg{code: "", docs: ""})
+    segs = append(segs, &seg{code: "", docs: "", line: 1})
     lastSeen := ""
-    for _, line := range lines {
+    for i, line := range lines {
         headerMatch := headerPat.MatchString(line)
         docsMatch := docsPat.MatchString(line)
         emptyMatch := line == ""
@@ -120,17 +638,35 @@ func main() {
         if headerMatch || (emptyMatch && lastHeader) {
             trimmed := docsPat.ReplaceAllString(line, "")
             if newHeader {
-                newSeg := seg{docs: trimmed, code: ""}
+                newSeg := seg{docs: trimmed, code: "", line: i + 1, header: true}
                 segs = append(segs, &newSeg)
+                lastSeg = &newSeg
             } else {
                 lastSeg.docs = lastSeg.docs + "\n" + trimmed
+                lastSeg.header = lastSeg.header || headerMatch
+            }
+            if headerMatch {
+                lastSeg.headings = append(lastSeg.headings, parseHeading(trimmed))
             }
-			lastSeen = "header"
-            // Docs line - strip out comment indicator.
+            lastSeen = "header"
+            // Docs line - strip out comment indicator. A fold directive
+            // folds the code to come and is otherwise dropped, though
+            // it still ends any code before it, as does a playground
+            // directive, which marks the code as a program.
         } else if docsMatch || (emptyMatch && lastDocs) {
             trimmed := docsPat.ReplaceAllString(line, "")
-            if newDocs {
-                newSeg := seg{docs: trimmed, code: ""}
+            if docsMatch && isFoldDirective(trimmed) {
+                if newDocs {
+                    segs = append(segs, &seg{line: i + 1})
+                }
+                segs[len(segs)-1].fold = true
+            } else if name, ok := playgroundDirective(trimmed); docsMatch && ok {
+                if newDocs {
+                    segs = append(segs, &seg{line: i + 1})
+                }
+                segs[len(segs)-1].playground, segs[len(segs)-1].snippet = true, name
+            } else if newDocs {
+                newSeg := seg{docs: trimmed, code: "", line: i + 1}
                 segs = append(segs, &newSeg)
             } else {
                 lastSeg.docs = lastSeg.docs + "\n" + trimmed
@@ -139,58 +675,808 @@ func main() {
             // Code line - preserve all whitespace.
         } else {
             if newCode {
-                newSeg := seg{docs: "", code: line}
+                newSeg := seg{docs: "", code: line, line: i + 1, codeLine: i + 1, codeLines: 1}
                 segs = append(segs, &newSeg)
             } else {
                 lastSeg.code = lastSeg.code + "\n" + line
+                if lastSeg.codeLine != 0 {
+                    lastSeg.codeLines++
+                } else if line != "" {
+                    lastSeg.codeLine, lastSeg.codeLines = i+1, 1
+                }
             }
             lastSeen = "code"
         }
     }
+    // The empty string after a file's final newline isn't a line.
+    last := segs[len(segs)-1]
+    if len(lines) > 1 && lines[len(lines)-1] == "" && lastSeen == "code" && last.codeLine != 0 {
+        last.codeLines--
+    }
+    return segs
+}
+
+// ### Rendering
 
-    // Render docs via `markdown` and code via `pygmentize` in each
-    // segment, using our `pipe` helper.
+// What we keep about each rendered page, for the index of a
+// multi-file build.
+type pageInfo struct {
+    source, output, title, pkg, summary string
+    test, document                      bool
+    search                              []searchSegment
+}
+
+// Turn the source for one file into a complete HTML page, written to
+// `w` as it's rendered. Knowing `outPath` lets us point links in the
+// docs at other pages of the build.
+func renderPage(w io.Writer, sourcePath, outPath, title string, src []byte, css string) (pageInfo, error) {
+    segs, err := fileSegments(sourcePath, src)
+    if err != nil {
+        return pageInfo{}, err
+    }
+    ids := segmentAnchors(segs)
+    numberHeadings(segs)
+    headingIDs(segs, ids)
+    scopeDocs(sourcePath, outPath, src, segs)
+    if err := blameSegments(sourcePath, segs); err != nil {
+        return pageInfo{}, err
+    }
+    if err := playgroundPrograms(sourcePath, segs); err != nil {
+        return pageInfo{}, err
+    }
+    api, err := apiEntries(sourcePath, src, segs)
+    if err != nil {
+        return pageInfo{}, err
+    }
+    p := page{
+        Title:       title,
+        Description: description(segs),
+        CSS:         template.HTML(css),
+        Nav:         template.HTML(renderNav(outPath) + renderBreadcrumbs(outPath) + fileBadges(sourcePath)),
+        Pager:       template.HTML(renderPager(outPath)),
+        TOC:         template.HTML(renderTOC(segs)),
+        Symbols:     template.HTML(renderSymbols(sourcePath, src, segs)),
+        API:         api,
+        Metadata: pageMetadata{
+            Source:    sourcePath,
+            Package:   packageName(src),
+            SourceURL: sourceURL(sourcePath),
+            EditURL:   editURL(sourcePath),
+        },
+        path:       outPath,
+        playground: hasPlayground(segs),
+        mermaid:    hasMermaid(segs),
+        math:       hasMath(segs),
+    }
+    summary := ""
+    fileURL := p.Metadata.SourceURL
+    found := &searchCollector{}
+    covered := coveredLines(sourcePath)
+    uses, err := addTypeInfo(sourcePath, src, addImportLinks(sourcePath, outPath, src, identUses(sourcePath, src, segs)))
+    if err != nil {
+        return pageInfo{}, err
+    }
+    err = writePage(w, p, func(emit func(pageSegment) error) error {
+        levels := &headingLevels{}
+        return renderSegments(segs, sourcePath, outPath, func(done *seg) error {
+            if summary == "" && !done.license {
+                summary = summarize([]*seg{done})
+            }
+            addHeadingIDs(done, ids)
+            levels.fix(done)
+            markCode(done, uses)
+            addCoverage(done, covered)
+            addLineNumbers(done, "")
+            addSourceLink(done, fileURL)
+            found.add(done)
+            return emit(segmentFor(done))
+        })
+    })
+    if err != nil {
+        return pageInfo{}, err
+    }
+    if !hasDocs(segs) && !codeOnly {
+        if err := report(Diagnostic{Path: sourcePath, Message: "has no docs"}); err != nil {
+            return pageInfo{}, err
+        }
+    }
+    info := pageInfo{title: title, pkg: packageName(src), summary: summary, test: isTestFile(sourcePath), document: isMarkdownFile(sourcePath), search: found.segments}
+    return info, nil
+}
+
+// Whether any segment has docs worth reading.
+func hasDocs(segs []*seg) bool {
     for _, seg := range segs {
-        seg.docsRendered = pipe(markdownPath, []string{}, seg.docs)
-        seg.codeRendered = pipe(pygmentizePath, []string{"-l", "go", "-f", "html"}, seg.code+"  ")
-    }
-
-    // ### Rendering
-
-    // Print HTML header.
-    fmt.Printf(`
-<!DOCTYPE html>
-<html>
-  <head>
-    <meta http-eqiv="content-type" content="text/html;charset=utf-8">
-    <title>%s</title>
-    <link rel=stylesheet href="http://jashkenas.github.com/docco/resources/docco.css">
-  </head>
-  <body>
-    <div id="container">
-      <div id="background"></div>
-      <table cellspacing="0" cellpadding="0">
-        <thead>
-          <tr>
-            <td class=docs></td>
-            <td class=code></td>
-          </tr>
-        </thead>
-        <tbody>`, title)
-
-    // Print HTML docs/code segments.
+        if strings.TrimSpace(seg.docs) != "" {
+            return true
+        }
+    }
+    return false
+}
+
+// Split the source for one file into segments. The lexer and comment
+// marker come from the flags when given, otherwise from the file's
+// extension. A Markdown document is all docs, so it becomes a single
+// full-width segment, as is every file with `--docs-only`, and with
+// `--code-only` every file is all code. A folded license header comes
+// first, as a segment of its own.
+func fileSegments(sourcePath string, src []byte) ([]*seg, error) {
+    if codeOnly {
+        return []*seg{listingSegment(sourcePath, src)}, nil
+    }
+    if isMarkdownFile(sourcePath) {
+        return []*seg{{docs: string(src), lang: "text", wide: true, line: 1}}, nil
+    }
+    fileLexer := lexer
+    if fileLexer == "" {
+        fileLexer = lexerFor(sourcePath)
+    }
+    prefix := commentPrefix
+    if prefix == "" {
+        prefix = commentPrefixFor(sourcePath)
+    }
+    docsPat, headerPat, err := commentPats(prefix)
+    if err != nil {
+        return nil, err
+    }
+
+    lines := goDocLines(sourcePath, admonitionLines(strings.Split(string(src), "\n"), prefix, docsPat))
+    segs, licenseSeg := segmentLicensed(lines, prefix, docsPat, headerPat)
     for _, seg := range segs {
-        fmt.Printf(
-            `<tr>
-             <td class=docs>%s</td>
-             <td class=code>%s</td>
-           </tr>`, seg.docsRendered, seg.codeRendered)
-    }
-
-    // Print HTML footer.
-    fmt.Print(`</tbody>
-           </table>
-         </div>
-       </body>
-     </html>`)
+        seg.lang = fileLexer
+    }
+    segs = segmentExamples(sourcePath, src, lines, segs, docsPat, headerPat)
+    segs = segmentTodos(segs)
+    foldLong(segs)
+    if docsOnly {
+        segs = []*seg{articleSegment(segs)}
+    }
+    if licenseSeg != nil && license == "fold" {
+        licenseSeg.lang = fileLexer
+        segs = append([]*seg{licenseSeg}, segs...)
+    }
+    return segs, nil
+}
+
+// Render docs via `docRenderer` and code via `codeRenderer` in each
+// segment, and pass each one to `emit` in order as soon as it and
+// those before it are done. With external programs each segment
+// costs two subprocesses, so rather than running them one after
+// another we hand segments out to `--jobs` workers, each with a
+// channel to say when it's finished. Once emitted, a segment's
+// rendered HTML is dropped so a big file's worth doesn't pile up. The
+// first failure, whether rendering or emitting, stops any segments
+// not yet started, and the one reported is the earliest in the file.
+// Code is highlighted in one batch up front when that works, leaving
+// the workers just the docs.
+func renderSegments(segs []*seg, sourcePath, outPath string, emit func(*seg) error) error {
+    prefix := commentPrefix
+    if prefix == "" {
+        prefix = commentPrefixFor(sourcePath)
+    }
+    batched := highlightBatch(segs, prefix)
+
+    done := make([]chan error, len(segs))
+    for i := range done {
+        done[i] = make(chan error, 1)
+    }
+    var quit atomic.Bool
+    finished := make(chan bool)
+    go func() {
+        forEach(len(segs), true, func(i int) error {
+            err := errStopped
+            if !quit.Load() {
+                err = renderSegment(segs[i], sourcePath, outPath, !batched)
+                if _, located := err.(*sourceError); err != nil && err != errStopped && !located {
+                    err = &sourceError{path: sourcePath, index: i, line: segs[i].line, err: err}
+                }
+            }
+            done[i] <- err
+            return err
+        })
+        close(finished)
+    }()
+    defer func() {
+        quit.Store(true)
+        <-finished
+    }()
+
+    for i, seg := range segs {
+        if err := <-done[i]; err != nil {
+            return err
+        }
+        if err := emit(seg); err != nil {
+            return err
+        }
+        seg.docsRendered, seg.codeRendered = "", ""
+    }
+    return nil
+}
+
+// A failure in some part of a source file, which says where as
+// `path:line:` so that editors can jump to it. Failures that aren't
+// about any one segment have an `index` of -1 and no `line`.
+type sourceError struct {
+    path  string
+    index int
+    line  int
+    err   error
+}
+
+func (e *sourceError) Error() string {
+    if e.line == 0 {
+        return fmt.Sprintf("%s: %v", e.path, e.err)
+    }
+    return fmt.Sprintf("%s:%d: %v", e.path, e.line, e.err)
+}
+
+// Attach `path` to `err`, unless it already says where it's from.
+func atSource(path string, err error) error {
+    if _, ok := err.(*sourceError); ok || err == nil {
+        return err
+    }
+    return &sourceError{path: path, index: -1, err: err}
+}
+
+// Stands in for the segments we didn't get to after a failure.
+var errStopped = errors.New("stopped")
+
+// Render the docs of one segment, and its code too if `highlight`.
+func renderSegment(seg *seg, sourcePath, outPath string, highlight bool) error {
+    var err error
+    docs, math := protectMath(seg.docs)
+    seg.docsRendered, err = segmentDocRenderer(seg).RenderDocs(replaceEmoji(docs))
+    if err != nil {
+        return fmt.Errorf("%s failed: %v", rendererName(docRenderer), err)
+    }
+    seg.docsRendered = restoreMath(seg.docsRendered, math)
+    if err := checkLinks(seg.docsRendered, sourcePath, seg.line); err != nil {
+        return err
+    }
+    if err := checkMermaid(seg, sourcePath); err != nil {
+        return err
+    }
+    seg.docsRendered = rewriteLinks(seg.docsRendered, sourcePath, outPath)
+    seg.docsRendered = markMermaid(markAdmonitions(markTodos(markDeprecated(seg.docsRendered))))
+    if err := checkImages(seg.docsRendered, sourcePath); err != nil {
+        return err
+    }
+    if seg.wide || !highlight {
+        return nil
+    }
+    seg.codeRendered, err = codeRenderer.RenderCode(seg.lang, seg.code+"  ")
+    if err != nil {
+        return fmt.Errorf("%s failed: %v", rendererName(codeRenderer), err)
+    }
+    return nil
+}
+
+// Read, render, and write out the page for one source file, to
+// `outPath` or stdout if that's empty. An empty `title` means infer
+// one from the source.
+func build(sourcePath, outPath, title, css string) (pageInfo, error) {
+    var src []byte
+    var err error
+    if sourcePath == "-" {
+        src, err = readStdin()
+    } else {
+        src, err = ioutil.ReadFile(sourcePath)
+    }
+    if err != nil {
+        return pageInfo{}, atSource(sourcePath, err)
+    }
+
+    if title == "" {
+        title = inferTitle(sourcePath, src)
+    }
+
+    var info pageInfo
+    err = writeOutput(outPath, func(w io.Writer) error {
+        var err error
+        info, err = renderPage(w, sourcePath, outPath, title, src, css)
+        return err
+    })
+    info.source, info.output = sourcePath, outPath
+    return info, atSource(sourcePath, err)
+}
+
+// With `--single-page`, render all of `sources` onto one page instead
+// of a page each. Each file's segments are introduced by a header
+// with its name, so the usual header styling separates them.
+func buildSinglePage(sources []string, outPath, title, css string) error {
+    if title == "" {
+        root, err := sourceRoot(sources)
+        if err != nil {
+            return err
+        }
+        title = filepath.Base(root)
+        if name := modulePath(moduleRoot); name != "" {
+            title = name
+        }
+    }
+    // Segment every file first, for the table of contents.
+    files := [][]*seg{}
+    srcs := [][]byte{}
+    all := []*seg{}
+    for _, sourcePath := range sources {
+        src, err := ioutil.ReadFile(sourcePath)
+        if err != nil {
+            return atSource(sourcePath, err)
+        }
+        segs, err := fileSegments(sourcePath, src)
+        if err != nil {
+            return atSource(sourcePath, err)
+        }
+        if err := blameSegments(sourcePath, segs); err != nil {
+            return err
+        }
+        if err := playgroundPrograms(sourcePath, segs); err != nil {
+            return err
+        }
+        // Each file's own headers go just below the `<h1>` naming it,
+        // whatever level they start at, so every file nests alike.
+        top := 0
+        for _, seg := range segs {
+            for _, h := range seg.headings {
+                if top == 0 || h.level < top {
+                    top = h.level
+                }
+            }
+        }
+        if top > 0 {
+            shiftHeadings(segs, 2-top)
+        }
+        name := filepath.Base(sourcePath)
+        header := &seg{docs: "# " + html.EscapeString(name), lang: segs[0].lang, line: 1, header: true, headings: []heading{{level: 1, text: name}}, sourceURL: sourceURL(sourcePath)}
+        segs = append([]*seg{header}, segs...)
+        files = append(files, segs)
+        srcs = append(srcs, src)
+        all = append(all, segs...)
+    }
+    ids := segmentAnchors(all)
+    numberHeadings(all)
+    headingIDs(all, ids)
+    for i, sourcePath := range sources {
+        scopeDocs(sourcePath, outPath, srcs[i], files[i])
+    }
+    uses := make([]map[identPos]codeMark, len(sources))
+    for i, sourcePath := range sources {
+        var err error
+        marks := addImportLinks(sourcePath, outPath, srcs[i], identUses(sourcePath, srcs[i], files[i]))
+        if uses[i], err = addTypeInfo(sourcePath, srcs[i], marks); err != nil {
+            return err
+        }
+    }
+    p := page{
+        Title:      title,
+        CSS:        template.HTML(css),
+        Nav:        template.HTML(renderNav(outPath)),
+        Pager:      template.HTML(renderPager(outPath)),
+        TOC:        template.HTML(renderTOC(all)),
+        path:       outPath,
+        playground: hasPlayground(all),
+        mermaid:    hasMermaid(all),
+        math:       hasMath(all),
+    }
+    return writeOutput(outPath, func(w io.Writer) error {
+        return writePage(w, p, func(emit func(pageSegment) error) error {
+            levels := &headingLevels{}
+            for i, sourcePath := range sources {
+                prefix, fileURL, covered := linePrefix(sourcePath), sourceURL(sourcePath), coveredLines(sourcePath)
+                err := renderSegments(files[i], sourcePath, outPath, func(seg *seg) error {
+                    addHeadingIDs(seg, ids)
+                    levels.fix(seg)
+                    markCode(seg, uses[i])
+                    addCoverage(seg, covered)
+                    addLineNumbers(seg, prefix)
+                    addSourceLink(seg, fileURL)
+                    return emit(segmentFor(seg))
+                })
+                if err != nil {
+                    return atSource(sourcePath, err)
+                }
+            }
+            return nil
+        })
+    })
+}
+
+// Work out what pages of a multi-file build need to know about each
+// other before any of them is rendered: where every source's page
+// goes, the sidebar tree, the reading order, the site's name, and
+// which directories will have index pages.
+func planSite(sources []string, outputs map[string]string) error {
+    for source, page := range outputs {
+        abs, err := filepath.Abs(source)
+        if err != nil {
+            return err
+        }
+        pagesBySource[abs] = page
+    }
+    navTree = buildNav(outputs)
+    planPackagePages(sources, outputs)
+    for _, source := range sources {
+        pageOrder = append(pageOrder, navNode{name: filepath.Base(source), page: outputs[source]})
+    }
+
+    root, err := sourceRoot(sources)
+    if err != nil {
+        return err
+    }
+    siteName = filepath.Base(root)
+    if name := modulePath(moduleRoot); name != "" {
+        siteName = name
+    }
+    if err := planOverview(sources, outputs, root); err != nil {
+        return err
+    }
+
+    // Fragments go into another site, which has an index of its own.
+    if fragment {
+        return nil
+    }
+    rootIndex := filepath.Join(outDir, "index.html")
+    for _, source := range sources {
+        if filepath.Clean(outputs[source]) == filepath.Clean(rootIndex) {
+            return warn("not writing an index, %s already renders to %s", source, rootIndex)
+        }
+    }
+    indexPages[rootIndex] = true
+    return nil
+}
+
+// Render `changed`, which is some or all of `sources`, recording each
+// page in `pages`, and then write the index and sitemap for the whole
+// build. A failure is reported with the file's name, but doesn't stop
+// us going on to the rest. Returns whether there were any.
+func buildFiles(changed, sources []string, outputs map[string]string, pages map[string]pageInfo, title, css string) bool {
+    readRevision(sources)
+    if singlePage {
+        if err := buildSinglePage(sources, outputPath, title, css); err != nil {
+            fmt.Fprintln(os.Stderr, "golit:", err)
+            return true
+        }
+        return false
+    }
+
+    // Pages of a site whose sources and options haven't changed since
+    // the last build are left alone.
+    var cache map[string]cacheEntry
+    var options string
+    if outDir != "" {
+        cache = loadCache()
+        options = optionsHash(sources, title, css)
+    }
+
+    // Render the files `--jobs` at a time, then go through the
+    // results in order so that reports come out the same every time.
+    infos := make([]pageInfo, len(changed))
+    hashes := make([]string, len(changed))
+    skipped := make([]bool, len(changed))
+    errs := forEach(len(changed), false, func(i int) error {
+        sourcePath := changed[i]
+        if cache != nil {
+            info, hash, ok := cached(cache, sourcePath, outputs[sourcePath], options)
+            if ok {
+                verbosef("%s: unchanged, skipping", sourcePath)
+                infos[i], skipped[i] = info, true
+                return nil
+            }
+            hashes[i] = hash
+        }
+        var err error
+        infos[i], err = build(sourcePath, outputs[sourcePath], title, css)
+        return err
+    })
+
+    failed := false
+    for i, sourcePath := range changed {
+        info, hash, err := infos[i], hashes[i], errs[i]
+        if skipped[i] {
+            pages[sourcePath] = info
+            continue
+        }
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "golit:", err)
+            failed = true
+            delete(pages, sourcePath)
+            if cache != nil {
+                delete(cache, sourcePath)
+            }
+            continue
+        }
+        pages[sourcePath] = info
+        if cache != nil && hash != "" {
+            cache[sourcePath] = cacheEntryFor(info, hash, options)
+        }
+    }
+    if cache != nil {
+        for sourcePath := range cache {
+            if _, ok := outputs[sourcePath]; !ok {
+                delete(cache, sourcePath)
+            }
+        }
+        if err := saveCache(cache); err != nil {
+            fmt.Fprintf(os.Stderr, "golit: cache: %v\n", err)
+        }
+    }
+    ordered := []pageInfo{}
+    for _, sourcePath := range sources {
+        if info, ok := pages[sourcePath]; ok {
+            ordered = append(ordered, info)
+        }
+    }
+
+    // Give a multi-file build an entry point.
+    if indexPages[filepath.Join(outDir, "index.html")] {
+        if err := writeIndex(ordered, css); err != nil {
+            fmt.Fprintf(os.Stderr, "golit: index: %v\n", err)
+            failed = true
+        }
+    }
+    if writesDependencies() {
+        if err := writeDependencies(ordered, css); err != nil {
+            fmt.Fprintf(os.Stderr, "golit: dependencies: %v\n", err)
+            failed = true
+        }
+    }
+    if searching() {
+        if err := writeSearchIndex(ordered); err != nil {
+            fmt.Fprintf(os.Stderr, "golit: search: %v\n", err)
+            failed = true
+        }
+    }
+    if outDir != "" && baseURL != "" {
+        if err := writeSitemap(ordered); err != nil {
+            fmt.Fprintf(os.Stderr, "golit: sitemap: %v\n", err)
+            failed = true
+        }
+    }
+    return failed
+}
+
+func main() {
+    if err := run(); err != nil {
+        code := exitUsage
+        if exit, ok := err.(exitError); ok {
+            code, err = exit.code, exit.err
+        }
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "golit:", err)
+        }
+        os.Exit(code)
+    }
+}
+
+// Do everything `main` does, returning what went wrong, if anything,
+// as an `exitError` saying how to exit.
+func run() error {
+    // Accept the source path and optional page title, or with
+    // `--out-dir` any number of source paths, with flags mixed in
+    // anywhere.
+    args, err := parseArgs(os.Args[1:])
+    if err == flag.ErrHelp {
+        return nil
+    } else if err != nil {
+        return exitError{exitUsage, nil}
+    }
+    if showVersion {
+        fmt.Println(version())
+        return nil
+    }
+    if printTemplate {
+        fmt.Print(pageHTML)
+        return nil
+    }
+    if listThemes {
+        writeThemes(os.Stdout)
+        return nil
+    }
+    if len(args) < 1 {
+        flag.Usage()
+        return exitError{exitUsage, nil}
+    }
+
+    // Fill in defaults from a config file, if there is one.
+    if path := findConfig(args[0]); path != "" {
+        if err := loadConfig(path); err != nil {
+            return usageError(err)
+        }
+    }
+
+    // Serving a site means building one, in a scratch directory if
+    // need be.
+    if serveAddr != "" && outDir == "" && !singlePage {
+        dir, err := ioutil.TempDir("", "golit")
+        if err != nil {
+            return envError(err)
+        }
+        defer os.RemoveAll(dir)
+        outDir = dir
+    }
+    if serveAddr != "" && outDir == "" {
+        return usageError(fmt.Errorf("--serve needs a site, not --single-page"))
+    }
+
+    sources, title := args, ""
+    if singlePage && outDir != "" {
+        return usageError(fmt.Errorf("--single-page can't be combined with --out-dir"))
+    }
+    if codeOnly && docsOnly {
+        return usageError(fmt.Errorf("--code-only can't be combined with --docs-only"))
+    }
+    if outDir == "" && !singlePage {
+        if len(args) > 2 {
+            return usageError(fmt.Errorf("multiple inputs require --out-dir"))
+        }
+        sources = args[:1]
+        if len(args) == 2 {
+            title = args[1]
+        }
+    } else if outDir != "" && outputPath != "" {
+        return usageError(fmt.Errorf("-o can't be combined with --out-dir"))
+    }
+    sources, err = expandInputs(sources)
+    if err != nil {
+        return usageError(err)
+    }
+    sources, err = excludeInputs(sources, excludes)
+    if err != nil {
+        return usageError(err)
+    }
+    sources, err = orderInputs(sources, order)
+    if err != nil {
+        return usageError(err)
+    }
+    if len(sources) > 1 && outDir == "" && !singlePage {
+        return usageError(fmt.Errorf("multiple inputs require --out-dir"))
+    }
+    if len(sources) == 0 {
+        return usageError(fmt.Errorf("no input files"))
+    }
+    for _, sourcePath := range sources {
+        if _, err := os.Stat(sourcePath); os.IsNotExist(err) && sourcePath != "-" {
+            return usageError(fmt.Errorf("%s: no such file or directory", sourcePath))
+        }
+    }
+
+    // Decide up front where every page goes, so that clashes are
+    // caught before we've written anything.
+    outputs := map[string]string{sources[0]: outputPath}
+    if outDir != "" {
+        outputs, err = outputPaths(sources, outDir)
+        if err != nil {
+            return usageError(err)
+        }
+        if err := planSite(sources, outputs); err != nil {
+            return usageError(err)
+        }
+    }
+    for _, sourcePath := range sources {
+        if sourcePath == "-" && (outDir != "" || singlePage || watching) {
+            return usageError(fmt.Errorf("can't read source from stdin with --out-dir, --single-page, or --watch"))
+        }
+    }
+    if watching && outDir == "" && outputPath == "" {
+        return usageError(fmt.Errorf("--watch needs --out-dir or -o"))
+    }
+
+    if lexer != "" {
+        if err := validateLexer(lexer); err != nil {
+            return usageError(err)
+        }
+    }
+
+    if jobs < 1 {
+        return usageError(fmt.Errorf("--jobs must be at least 1"))
+    }
+    startJobs()
+
+    // Render docs with goldmark and code with chroma unless we're
+    // asked for something else, ensuring we have any programs that
+    // takes.
+    if err := chooseRenderers(); err != nil {
+        return err
+    }
+    if err := loadHighlightStyle(); err != nil {
+        return usageError(err)
+    }
+    if err := checkDocSyntax(); err != nil {
+        return usageError(err)
+    }
+    if err := loadCoverProfile(); err != nil {
+        return usageError(err)
+    }
+    if err := loadBenchResults(); err != nil {
+        return usageError(err)
+    }
+    if err := loadMermaid(); err != nil {
+        return usageError(err)
+    }
+    if err := loadKaTeX(); err != nil {
+        return usageError(err)
+    }
+    if err := checkBenchResults(sources); err != nil {
+        return exitError{exitRender, err}
+    }
+
+    // Parse any template of the user's, and read any markup for the
+    // head, before we start writing.
+    if err := loadTemplate(); err != nil {
+        return usageError(err)
+    }
+    if err := loadHeadHTML(); err != nil {
+        return usageError(err)
+    }
+    if err := checkRepoURL(); err != nil {
+        return usageError(err)
+    }
+    if err := checkLicense(); err != nil {
+        return usageError(err)
+    }
+    if err := checkTheme(); err != nil {
+        return usageError(err)
+    }
+    if err := checkSymbols(); err != nil {
+        return usageError(err)
+    }
+    if err := checkTodos(); err != nil {
+        return usageError(err)
+    }
+
+    // Resolve the stylesheets before we start writing.
+    css, err := stylesheets(cssFlags)
+    if err != nil {
+        return usageError(err)
+    }
+
+    // Copy static assets in next to the pages.
+    if assetsDir != "" {
+        outRoot := outDir
+        if outRoot == "" && outputPath != "" {
+            outRoot = filepath.Dir(outputPath)
+        } else if outRoot == "" {
+            return usageError(fmt.Errorf("--assets needs --out-dir or -o"))
+        }
+        root, err := sourceRoot(sources)
+        if err != nil {
+            return usageError(err)
+        }
+        if err := copyAssets(outRoot, root); err != nil {
+            return exitError{exitRender, err}
+        }
+    }
+
+    // Build everything once, and then again as sources change if
+    // we're watching.
+    pages := map[string]pageInfo{}
+    failed := buildFiles(sources, sources, outputs, pages, title, css)
+    if serveAddr != "" {
+        if err := serve(); err != nil {
+            return envError(err)
+        }
+    }
+    if watching {
+        err := watch(sources, func(changed []string) bool {
+            failed := buildFiles(changed, sources, outputs, pages, title, css)
+            if serveAddr != "" {
+                broadcastReload()
+            }
+            return failed
+        })
+        if err != nil {
+            return envError(err)
+        }
+        return nil
+    }
+    if serveAddr != "" {
+        interrupt := make(chan os.Signal, 1)
+        signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+        <-interrupt
+        return nil
+    }
+    if failed {
+        return exitError{exitRender, nil}
+    }
+    return nil
 }
@@ -0,0 +1,238 @@
+package main
+
+import (
+    "bytes"
+    "flag"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "reflect"
+    "regexp"
+    "strings"
+    "testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with what the tests get")
+
+// The test binary doubles as golit, so tests can run it as its own
+// process, with flags and globals fresh each time.
+func TestMain(m *testing.M) {
+    if os.Getenv("GOLIT_TEST_MAIN") == "1" {
+        main()
+        os.Exit(0)
+    }
+    startJobs()
+    os.Exit(m.Run())
+}
+
+// Run golit with `args` in `dir`, returning what it wrote to stdout and
+// stderr and how it exited.
+func runGolit(t *testing.T, dir string, args ...string) (stdout, stderr string, code int) {
+    t.Helper()
+    self, err := os.Executable()
+    if err != nil {
+        t.Fatal(err)
+    }
+    cmd := exec.Command(self, args...)
+    cmd.Dir = dir
+    cmd.Env = append(os.Environ(), "GOLIT_TEST_MAIN=1", "GOLIT_MARKDOWN=", "GOLIT_PYGMENTIZE=")
+    var out, errOut bytes.Buffer
+    cmd.Stdout, cmd.Stderr = &out, &errOut
+    err = cmd.Run()
+    if exit, ok := err.(*exec.ExitError); ok {
+        code = exit.ExitCode()
+    } else if err != nil {
+        t.Fatal(err)
+    }
+    return out.String(), errOut.String(), code
+}
+
+// Write `files`, by name, into a fresh directory and return it.
+func writeFiles(t *testing.T, files map[string]string) string {
+    t.Helper()
+    dir := t.TempDir()
+    for name, content := range files {
+        path := filepath.Join(dir, filepath.FromSlash(name))
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            t.Fatal(err)
+        }
+        if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return dir
+}
+
+// Compare `got` with the golden file `path`, or with `-update` write
+// it there.
+func golden(t *testing.T, path, got string) {
+    t.Helper()
+    if *update {
+        if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+            t.Fatal(err)
+        }
+        return
+    }
+    want, err := ioutil.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got != string(want) {
+        t.Errorf("output doesn't match %s; rerun with -update if it should:\n%s", path, got)
+    }
+}
+
+var renderedHeadingPat = regexp.MustCompile(`<h([1-6]) id="([^"]*)"`)
+
+// Each file on a single page is headed by an `<h1>` of its name, with
+// its own headers below that whatever level they start at.
+func TestSinglePageHeadings(t *testing.T) {
+    dir := writeFiles(t, map[string]string{
+        "a.go": "// # A\n\n// ## A sub\npackage p\n",
+        "b.go": "// ### B\n\n// Text.\npackage p\n\n// #### B sub\nvar x = 1\n",
+        "c.go": "// Nothing.\npackage p\n",
+    })
+    if _, stderr, code := runGolit(t, dir, "--single-page", "-o", "out.html", "a.go", "b.go", "c.go"); code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    page, err := ioutil.ReadFile(filepath.Join(dir, "out.html"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    got := []string{}
+    for _, match := range renderedHeadingPat.FindAllStringSubmatch(string(page), -1) {
+        got = append(got, match[1]+" "+match[2])
+    }
+    want := []string{"1 ago", "2 a", "3 a-sub", "1 bgo", "2 b", "3 b-sub", "1 cgo"}
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("headings = %q, want %q", got, want)
+    }
+}
+
+// What went wrong goes to stderr, without a stack trace, and the exit
+// code says what kind of thing it was.
+func TestExitCodes(t *testing.T) {
+    dir := writeFiles(t, map[string]string{
+        "a.go":    "// Fine.\npackage p\n",
+        "link.go": "// See [x](missing.go).\npackage p\n",
+    })
+    cases := []struct {
+        args   []string
+        code   int
+        stderr string
+    }{
+        {[]string{"nope.go"}, exitUsage, "golit: nope.go: no such file or directory\n"},
+        {[]string{"--jobs", "0", "a.go"}, exitUsage, "golit: --jobs must be at least 1\n"},
+        {[]string{"--require-external", "--markdown-bin", "/no/such/markdown", "a.go"}, exitEnv, "golit: markdown isn't available (--markdown-bin: "},
+        {[]string{"--strict", "link.go"}, exitRender, "golit: link.go:1: broken link to missing.go\n"},
+    }
+    for _, c := range cases {
+        _, stderr, code := runGolit(t, dir, c.args...)
+        if code != c.code || !strings.HasPrefix(stderr, c.stderr) || strings.Contains(stderr, "goroutine") {
+            t.Errorf("golit %q: exit %d, stderr %q; want exit %d, stderr %q", c.args, code, stderr, c.code, c.stderr)
+        }
+    }
+}
+
+func TestInferTitle(t *testing.T) {
+    cases := []struct {
+        path, src, want string
+    }{
+        {"handlers.go", "// # Handlers\npackage mypkg\n", "mypkg — handlers.go"},
+        {"dir/handlers.go", "package mypkg\n", "mypkg — handlers.go"},
+        {"script.py", "# # Script\nprint(1)\n", "script.py"},
+        {"broken.go", "// No package clause.\n", "broken.go"},
+        {"-", "package mypkg\n", "mypkg — stdin"},
+    }
+    for _, c := range cases {
+        if got := inferTitle(c.path, []byte(c.src)); got != c.want {
+            t.Errorf("inferTitle(%q) = %q, want %q", c.path, got, c.want)
+        }
+    }
+}
+
+// A title given on the command line wins over the one golit would
+// infer, header or no header; without one, the page is titled by its
+// package and file, or by its file alone.
+func TestTitles(t *testing.T) {
+    dir := writeFiles(t, map[string]string{
+        "a.go":      "// # Leading header\n\n// Docs.\npackage mypkg\n",
+        "script.py":  "# # Leading header\nprint(1)\n",
+    })
+    cases := []struct {
+        args []string
+        want string
+    }{
+        {[]string{"a.go", "Given"}, "Given"},
+        {[]string{"a.go"}, "mypkg — a.go"},
+        {[]string{"script.py"}, "script.py"},
+        {[]string{"script.py", "Given"}, "Given"},
+    }
+    for _, c := range cases {
+        stdout, stderr, code := runGolit(t, dir, c.args...)
+        if code != 0 {
+            t.Fatalf("golit %q: exit %d: %s", c.args, code, stderr)
+        }
+        if match := titlePat.FindStringSubmatch(stdout); match == nil || match[1] != c.want {
+            t.Errorf("golit %q: title = %q, want %q", c.args, match, c.want)
+        }
+    }
+}
+
+// Each comment prefix splits a file the way `//` does: docs after the
+// marker and some whitespace, however far it's indented, headers
+// after the marker and a `#` run, and everything else, bare markers
+// and markers run into the text included, as code.
+func TestSegmentPrefixes(t *testing.T) {
+    cases := []struct {
+        prefix string
+        src    string
+        want   []string
+    }{
+        {"//", "// # Title\n// Docs.\ncode()\n//\n//x\n", []string{"header: # Title", "docs: Docs.", "code: code()\n//\n//x\n"}},
+        {"#", "# # Title\n\n# Docs\n#   indented.\nx = 1\n", []string{"header: # Title\n", "docs: Docs\n  indented.", "code: x = 1\n"}},
+        {"#", "    # Nested docs.\n    return x\n", []string{"docs: Nested docs.", "code:     return x\n"}},
+        {"#", "\t#\tTabbed.\nx\n", []string{"docs: Tabbed.", "code: x\n"}},
+        {"#", "#!/bin/sh\n#\n#no space\n## not a header\n", []string{"code: #!/bin/sh\n#\n#no space\n## not a header\n"}},
+        {"#", "# ## Sub\n# Text.\n", []string{"header: ## Sub", "docs: Text.\n"}},
+        {"--", "-- # Schema\n-- The users table.\nSELECT 1;\n", []string{"header: # Schema", "docs: The users table.", "code: SELECT 1;\n"}},
+        {"--", "  -- Indented.\n--\n--x\nx -- trailing\n", []string{"docs: Indented.", "code: --\n--x\nx -- trailing\n"}},
+        {"--", "-- ### Deep\n", []string{"header: ### Deep\n"}},
+        {";;", ";; # Title\n;; Docs.\n(def x 1)\n", []string{"header: # Title", "docs: Docs.", "code: (def x 1)\n"}},
+    }
+    for _, c := range cases {
+        docs, header, err := commentPats(c.prefix)
+        if err != nil {
+            t.Fatal(err)
+        }
+        // The first segment starts out empty, so what joins it comes
+        // after a newline.
+        got := []string{}
+        for _, seg := range segment(strings.Split(c.src, "\n"), docs, header) {
+            switch {
+            case seg.header:
+                got = append(got, "header: "+strings.TrimPrefix(seg.docs, "\n"))
+            case seg.docs != "":
+                got = append(got, "docs: "+strings.TrimPrefix(seg.docs, "\n"))
+            }
+            if seg.code != "" {
+                got = append(got, "code: "+strings.TrimPrefix(seg.code, "\n"))
+            }
+        }
+        if !reflect.DeepEqual(got, c.want) {
+            t.Errorf("with prefix %q, segment(%q) =\n%q, want\n%q", c.prefix, c.src, got, c.want)
+        }
+    }
+    if _, _, err := commentPats("  "); err == nil {
+        t.Error("commentPats accepted a blank prefix")
+    }
+}
+
+func TestCommentPrefixFor(t *testing.T) {
+    for path, want := range map[string]string{"a.go": "//", "script.py": "#", "RUN.SH": "#", "q.sql": "--", "init.lua": "--", "core.clj": ";;", "x.c": "//"} {
+        if got := commentPrefixFor(path); got != want {
+            t.Errorf("commentPrefixFor(%q) = %q, want %q", path, got, want)
+        }
+    }
+}
@@ -0,0 +1,30 @@
+// ### Markdown
+
+// Docs are rendered with goldmark, a CommonMark implementation in Go,
+// so golit doesn't need a `markdown` program installed. The various
+// `markdown` programs around don't quite agree with one another, so
+// anyone relying on a particular one can still name it with
+// `--markdown-bin` or `GOLIT_MARKDOWN`. Like the original Markdown,
+// we pass raw HTML in docs through untouched.
+
+package main
+
+import (
+    "bytes"
+
+    "github.com/yuin/goldmark"
+    "github.com/yuin/goldmark/renderer/html"
+)
+
+var markdown = goldmark.New(goldmark.WithRendererOptions(html.WithUnsafe()))
+
+// Renders Markdown in process.
+type goldmarkRenderer struct{}
+
+func (goldmarkRenderer) RenderDocs(src string) (string, error) {
+    var out bytes.Buffer
+    if err := markdown.Convert([]byte(src), &out); err != nil {
+        return "", err
+    }
+    return out.String(), nil
+}
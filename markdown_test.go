@@ -0,0 +1,18 @@
+package main
+
+import (
+    "io/ioutil"
+    "testing"
+)
+
+func TestGoldmark(t *testing.T) {
+    src, err := ioutil.ReadFile("testdata/goldmark/docs.md")
+    if err != nil {
+        t.Fatal(err)
+    }
+    got, err := goldmarkRenderer{}.RenderDocs(string(src))
+    if err != nil {
+        t.Fatal(err)
+    }
+    golden(t, "testdata/goldmark/docs.html", got)
+}
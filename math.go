@@ -0,0 +1,273 @@
+// ### Math
+
+// Numerical code is explained best with its math, so docs can have TeX
+// between dollars, `$x^2$` inline and `$$\sum_i x_i$$` on its own,
+// typeset by KaTeX in the reader's browser. Markdown would take TeX's
+// backslashes, underscores and stars for its own, so before the docs
+// are rendered each span of math is swapped for a placeholder, and
+// afterwards the placeholder for the TeX, escaped, in an element KaTeX
+// typesets. KaTeX is loaded on pages with math and no others, from a
+// CDN, or from the URL `--katex` gives, or from a local copy of its
+// `dist` directory, inlined, fonts and all, which `--offline-js` needs.
+//
+// Dollars are common outside of math, so we're careful about them.
+// Code, fenced, indented or in backticks, never has math in it, an
+// escaped `\$` is just a dollar, and as in Pandoc, inline math has no
+// space just inside its dollars and no digit just after, so `$5 and
+// $10` is money. Math doesn't go on past the end of a paragraph.
+
+package main
+
+import (
+    "crypto/sha256"
+    _ "embed"
+    "encoding/base64"
+    "flag"
+    "fmt"
+    "html"
+    "io/ioutil"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+const defaultKaTeX = "https://cdn.jsdelivr.net/npm/katex@0.16.9/dist"
+
+var katexSource = defaultKaTeX
+
+func init() {
+    flag.StringVar(&katexSource, "katex", katexSource, "KaTeX's dist, for pages with math: a `URL or directory` to link or inline")
+}
+
+//go:embed resources/math.js
+var mathScript string
+
+// A local copy of KaTeX, once it's read, with its fonts inlined.
+var katexJS, katexCSS string
+
+// Read the local copy of KaTeX that `--katex` names, if it does.
+func loadKaTeX() error {
+    if isURL(katexSource) {
+        return nil
+    }
+    js, err := ioutil.ReadFile(filepath.Join(katexSource, "katex.min.js"))
+    if err != nil {
+        return err
+    }
+    css, err := ioutil.ReadFile(filepath.Join(katexSource, "katex.min.css"))
+    if err != nil {
+        return err
+    }
+    katexJS, katexCSS = string(js), inlineFonts(string(css), katexSource)
+    return nil
+}
+
+// The local copy as it goes into the options hash, so that pages are
+// rebuilt when it changes.
+func katexKey() string {
+    if katexJS == "" {
+        return ""
+    }
+    return fmt.Sprintf("%x", sha256.Sum256([]byte(katexJS+katexCSS)))
+}
+
+var cssURLPat = regexp.MustCompile(`url\(["']?([^)"':]+)["']?\)`)
+
+// Make the fonts `css`, from `dir`, refers to into data URLs, so it
+// can be inlined. Those missing are left to fall back on others.
+func inlineFonts(css, dir string) string {
+    types := map[string]string{".woff2": "font/woff2", ".woff": "font/woff", ".ttf": "font/ttf"}
+    return cssURLPat.ReplaceAllStringFunc(css, func(match string) string {
+        name := cssURLPat.FindStringSubmatch(match)[1]
+        kind, ok := types[filepath.Ext(name)]
+        if !ok {
+            return match
+        }
+        font, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+        if err != nil {
+            return match
+        }
+        return "url(data:" + kind + ";base64," + base64.StdEncoding.EncodeToString(font) + ")"
+    })
+}
+
+// A span of math in docs: where it is, its TeX, and whether it's
+// displayed on its own.
+type mathSpan struct {
+    start, end int
+    tex        string
+    display    bool
+}
+
+var (
+    mathFencePat    = regexp.MustCompile("^\\s*(```+|~~~+)")
+    backticksPat    = regexp.MustCompile("`+")
+    placeholderPat  = regexp.MustCompile(`(<p>)?golit-math-(\d+)-(</p>)?`)
+    indentedCodePat = regexp.MustCompile("^(    |\t)")
+)
+
+// Which bytes of `docs` are code: fenced blocks, indented blocks and
+// code spans.
+func codeBytes(docs string) []bool {
+    code := make([]bool, len(docs))
+    mark := func(from, to int) {
+        for i := from; i < to; i++ {
+            code[i] = true
+        }
+    }
+    fence, blank, indented := "", true, false
+    offset := 0
+    for _, line := range strings.SplitAfter(docs, "\n") {
+        end := offset + len(line)
+        match := mathFencePat.FindStringSubmatch(line)
+        switch {
+        case fence != "":
+            mark(offset, end)
+            if match != nil && strings.HasPrefix(match[1], fence) {
+                fence = ""
+            }
+        case match != nil:
+            mark(offset, end)
+            fence = match[1]
+        case (blank || indented) && indentedCodePat.MatchString(line):
+            mark(offset, end)
+            indented = true
+        default:
+            indented = indented && strings.TrimSpace(line) == ""
+        }
+        blank = strings.TrimSpace(line) == ""
+        offset = end
+    }
+    // A code span runs from backticks to as many again, in the same
+    // paragraph.
+    runs := backticksPat.FindAllStringIndex(docs, -1)
+    for i := 0; i < len(runs); i++ {
+        open := runs[i]
+        if code[open[0]] || (open[0] > 0 && docs[open[0]-1] == '\\') {
+            continue
+        }
+        for j := i + 1; j < len(runs); j++ {
+            close := runs[j]
+            if code[close[0]] || strings.Contains(docs[open[1]:close[0]], "\n\n") {
+                break
+            }
+            if close[1]-close[0] == open[1]-open[0] {
+                mark(open[0], close[1])
+                i = j
+                break
+            }
+        }
+    }
+    return code
+}
+
+// The spans of math in `docs`.
+func mathSpans(docs string) []mathSpan {
+    if !strings.Contains(docs, "$") {
+        return nil
+    }
+    code := codeBytes(docs)
+    spans := []mathSpan{}
+    // Where the math opened at `from` by `delim` closes, or -1.
+    closing := func(from int, delim string) int {
+        for i := from; i < len(docs); i++ {
+            switch {
+            case code[i] || strings.HasPrefix(docs[i:], "\n\n"):
+                return -1
+            case docs[i] == '\\':
+                i++
+            case strings.HasPrefix(docs[i:], delim):
+                if delim == "$" && (docs[i-1] == ' ' || docs[i-1] == '\n' || i+1 < len(docs) && docs[i+1] >= '0' && docs[i+1] <= '9') {
+                    continue
+                }
+                return i
+            }
+        }
+        return -1
+    }
+    for i := 0; i < len(docs); i++ {
+        switch {
+        case code[i]:
+        case docs[i] == '\\':
+            i++
+        case strings.HasPrefix(docs[i:], "$$"):
+            if end := closing(i+2, "$$"); end > i+2 {
+                spans = append(spans, mathSpan{i, end + 2, docs[i+2 : end], true})
+                i = end + 1
+            } else {
+                i++
+            }
+        case docs[i] == '$':
+            if i+1 == len(docs) || docs[i+1] == ' ' || docs[i+1] == '\n' {
+                continue
+            }
+            if end := closing(i+1, "$"); end > i+1 {
+                spans = append(spans, mathSpan{i, end + 1, docs[i+1 : end], false})
+                i = end
+            }
+        }
+    }
+    return spans
+}
+
+// Swap the math in `docs` for placeholders the renderer leaves alone.
+func protectMath(docs string) (string, []mathSpan) {
+    spans := mathSpans(docs)
+    for n := len(spans) - 1; n >= 0; n-- {
+        docs = docs[:spans[n].start] + fmt.Sprintf("golit-math-%d-", n) + docs[spans[n].end:]
+    }
+    return docs, spans
+}
+
+// Put the math of `spans` back into the rendered docs `rendered`, for
+// KaTeX. Displayed math that's a paragraph to itself takes the place
+// of the paragraph.
+func restoreMath(rendered string, spans []mathSpan) string {
+    if len(spans) == 0 {
+        return rendered
+    }
+    return placeholderPat.ReplaceAllStringFunc(rendered, func(match string) string {
+        parts := placeholderPat.FindStringSubmatch(match)
+        var n int
+        fmt.Sscan(parts[2], &n)
+        if n >= len(spans) {
+            return match
+        }
+        span := spans[n]
+        tex := html.EscapeString(strings.TrimSpace(span.tex))
+        switch {
+        case span.display && parts[1] != "" && parts[3] != "":
+            return `<div class="math display">` + tex + "</div>"
+        case span.display:
+            return parts[1] + `<span class="math display">` + tex + "</span>" + parts[3]
+        }
+        return parts[1] + `<span class="math">` + tex + "</span>" + parts[3]
+    })
+}
+
+// Whether any of `segs` has math to typeset.
+func hasMath(segs []*seg) bool {
+    for _, seg := range segs {
+        if len(mathSpans(seg.docs)) > 0 {
+            return true
+        }
+    }
+    return false
+}
+
+// The stylesheet for pages with math, for their `<head>`.
+func katexHead() string {
+    if katexCSS != "" {
+        return fmt.Sprintf("    <style>\n%s\n    </style>\n", inlineCSS(katexCSS))
+    }
+    return fmt.Sprintf("    <link rel=\"stylesheet\" href=\"%s/katex.min.css\">\n", html.EscapeString(strings.TrimRight(katexSource, "/")))
+}
+
+// The scripts for pages with math.
+func mathScripts() string {
+    out := fmt.Sprintf("<script src=\"%s/katex.min.js\"></script>\n", html.EscapeString(strings.TrimRight(katexSource, "/")))
+    if katexJS != "" {
+        out = "<script>\n" + strings.Replace(katexJS, "</script", `<\/script`, -1) + "\n</script>\n"
+    }
+    return out + "<script>\n" + mathScript + "</script>\n"
+}
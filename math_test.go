@@ -0,0 +1,64 @@
+package main
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// Math is found between dollars, except in code of any kind, and
+// dollars that are money or escaped are left alone.
+func TestMathSpans(t *testing.T) {
+    cases := []struct {
+        docs string
+        want []string
+    }{
+        {"Area is $\\pi r^2$.", []string{"\\pi r^2"}},
+        {"$$\\sum_i x_i$$", []string{"$$\\sum_i x_i"}},
+        {"Write `$x$` for $x$.", []string{"x"}},
+        {"Or ``a ` $x$ ` b`` here.", nil},
+        {"```\n$x$\n```\nthen $y$", []string{"y"}},
+        {"~~~tex\n$$x$$\n~~~", nil},
+        {"````\n```\n$x$\n````\n$y$", []string{"y"}},
+        {"Code:\n\n    cost := $x$\n\nAfter $z$.", []string{"z"}},
+        {"A `$x` and a `$y`.", nil},
+        {"An unclosed ` lets $x$ through.", []string{"x"}},
+        {"It costs \\$5, not $x$.", []string{"x"}},
+        {"It costs $5 and $10.", nil},
+        {"Spaced $ x $ isn't.", nil},
+        {"Opened $x\n\nclosed$ later.", nil},
+    }
+    for _, c := range cases {
+        got := []string(nil)
+        for _, span := range mathSpans(c.docs) {
+            tex := span.tex
+            if span.display {
+                tex = "$$" + tex
+            }
+            got = append(got, tex)
+        }
+        if !reflect.DeepEqual(got, c.want) {
+            t.Errorf("mathSpans(%q) = %q, want %q", c.docs, got, c.want)
+        }
+    }
+}
+
+// Dollars in code come through rendering as they are, beside math
+// typeset from the same docs.
+func TestMathNotInCode(t *testing.T) {
+    dir := writeFiles(t, map[string]string{
+        "a.go": "// Use `$HOME` for $x^2$, as in:\n//\n// ```sh\n// echo $HOME $PATH$\n// ```\npackage p\n",
+    })
+    stdout, stderr, code := runGolit(t, dir, "--remote-css", "a.go")
+    if code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    for _, want := range []string{"<code>$HOME</code>", `<span class="math">x^2</span>`, "echo $HOME $PATH$"} {
+        if !strings.Contains(stdout, want) {
+            t.Errorf("page doesn't have %s:\n%s", want, stdout)
+        }
+    }
+    if strings.Count(stdout, `class="math`) != 1 {
+        t.Errorf("page has math other than x^2:\n%s", stdout)
+    }
+}
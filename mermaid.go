@@ -0,0 +1,201 @@
+// ### Mermaid diagrams
+
+// An architecture is easier to follow with a picture of it, and a
+// fenced block of docs tagged `mermaid` is drawn as one, by mermaid.js
+// in the reader's browser. Rendered, such a block becomes the
+// `<pre class="mermaid">` mermaid looks for, and the script is loaded
+// on pages that have one and no others: from a CDN, or whatever URL
+// `--mermaid-js` gives, or from a local copy it names, inlined in the
+// page. `--offline-js` insists on that, so pages never load scripts
+// from elsewhere. Mermaid shows a diagram it can't read as an error in
+// its place, so each block is sniffed first, for a diagram type it
+// knows and brackets that match, and one that looks wrong is warned
+// about, or with `--strict` fails the build.
+
+package main
+
+import (
+    "crypto/sha256"
+    _ "embed"
+    "flag"
+    "fmt"
+    "html"
+    "io/ioutil"
+    "regexp"
+    "strings"
+    "unicode"
+)
+
+const defaultMermaidJS = "https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.min.js"
+
+var (
+    mermaidJS = defaultMermaidJS
+    offlineJS bool
+)
+
+func init() {
+    flag.StringVar(&mermaidJS, "mermaid-js", mermaidJS, "the mermaid.js for pages with diagrams: a `URL or file` to link or inline")
+    flag.BoolVar(&offlineJS, "offline-js", false, "inline every script pages need, so none is loaded from elsewhere, from the local copies --mermaid-js and --katex name")
+}
+
+//go:embed resources/mermaid.js
+var mermaidInit string
+
+// A local copy of mermaid.js, once it's read.
+var mermaidSource string
+
+// Whether `source`, of a stylesheet or script, is a URL rather than a
+// local file.
+func isURL(source string) bool {
+    return strings.Contains(source, "://") || strings.HasPrefix(source, "//")
+}
+
+// Check `--mermaid-js` and `--offline-js`, reading any local copy.
+func loadMermaid() error {
+    if isURL(mermaidJS) {
+        return nil
+    }
+    src, err := ioutil.ReadFile(mermaidJS)
+    if err != nil {
+        return err
+    }
+    mermaidSource = string(src)
+    return nil
+}
+
+// The local copy as it goes into the options hash, so that pages are
+// rebuilt when it changes.
+func mermaidKey() string {
+    if mermaidSource == "" {
+        return ""
+    }
+    return fmt.Sprintf("%x", sha256.Sum256([]byte(mermaidSource)))
+}
+
+var (
+    mermaidFencePat   = regexp.MustCompile("(?m)^\\s*(```+|~~~+)\\s*mermaid\\s*$")
+    mermaidHTMLPat    = regexp.MustCompile(`(?s)<pre><code class="language-mermaid">(.*?)</code></pre>`)
+    mermaidDiagramPat = regexp.MustCompile(`^(graph|flowchart|sequenceDiagram|classDiagram(-v2)?|stateDiagram(-v2)?|erDiagram|journey|gantt|pie|quadrantChart|requirementDiagram|gitGraph|mindmap|timeline|zenuml|C4(Context|Container|Component|Dynamic|Deployment)|(sankey|xychart|block|packet|architecture)-beta|kanban)\b`)
+)
+
+// Whether any of `segs` has a diagram to draw.
+func hasMermaid(segs []*seg) bool {
+    if docSyntax == "go" {
+        return false
+    }
+    for _, seg := range segs {
+        if mermaidFencePat.MatchString(seg.docs) {
+            return true
+        }
+    }
+    return false
+}
+
+// Sniff the diagrams in the docs of `seg`, from `sourcePath`, and
+// report any that look wrong.
+func checkMermaid(seg *seg, sourcePath string) error {
+    if !mermaidFencePat.MatchString(seg.docs) || docSyntax == "go" {
+        return nil
+    }
+    // The first segment's docs start after a newline.
+    lines := strings.Split(strings.TrimPrefix(seg.docs, "\n"), "\n")
+    for i := 0; i < len(lines); i++ {
+        match := mermaidFencePat.FindStringSubmatch(lines[i])
+        if match == nil {
+            continue
+        }
+        start, body := i, []string{}
+        for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), match[1]); i++ {
+            body = append(body, lines[i])
+        }
+        problem := ""
+        if i == len(lines) {
+            problem = "isn't closed"
+        } else {
+            problem = mermaidProblem(body)
+        }
+        if problem != "" {
+            if err := report(Diagnostic{Path: sourcePath, Line: seg.line + start, Message: "mermaid diagram " + problem}); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// What's wrong with the diagram `body`, as far as a glance can tell,
+// or "".
+func mermaidProblem(body []string) string {
+    // Front matter and comments can come before the diagram's type.
+    kind := ""
+    inFrontMatter := false
+    for n, line := range body {
+        line = strings.TrimSpace(line)
+        if line == "---" && (n == 0 || inFrontMatter) {
+            inFrontMatter = !inFrontMatter
+            continue
+        }
+        if inFrontMatter || line == "" || strings.HasPrefix(line, "%%") {
+            continue
+        }
+        kind = line
+        break
+    }
+    if kind == "" {
+        return "is empty"
+    }
+    if !mermaidDiagramPat.MatchString(kind) {
+        return fmt.Sprintf("starts %q, not a diagram type", strings.Fields(kind)[0])
+    }
+    // A flowchart's brackets, outside quoted labels, should pair up.
+    // Other diagrams' text is freer.
+    if !strings.HasPrefix(kind, "graph") && !strings.HasPrefix(kind, "flowchart") {
+        return ""
+    }
+    // A `>` right after a node's id, as in `id>text]`, opens the
+    // asymmetric shape, which a `]` closes; elsewhere it's an arrow's.
+    closing := map[rune]rune{')': '(', ']': '[', '}': '{'}
+    open := []rune{}
+    for _, line := range body {
+        if strings.HasPrefix(strings.TrimSpace(line), "%%") {
+            continue
+        }
+        quoted, prev := false, ' '
+        for _, r := range line {
+            switch {
+            case r == '"':
+                quoted = !quoted
+            case quoted:
+            case r == '(' || r == '[' || r == '{':
+                open = append(open, r)
+            case r == '>' && len(open) == 0 && (prev == '_' || unicode.IsLetter(prev) || unicode.IsDigit(prev)):
+                open = append(open, r)
+            case closing[r] != 0:
+                if len(open) == 0 || (open[len(open)-1] != closing[r] && !(r == ']' && open[len(open)-1] == '>')) {
+                    return fmt.Sprintf("has an unmatched %q", r)
+                }
+                open = open[:len(open)-1]
+            }
+            prev = r
+        }
+    }
+    if len(open) > 0 {
+        return fmt.Sprintf("has an unclosed %q", open[len(open)-1])
+    }
+    return ""
+}
+
+// Make the fenced diagrams of the rendered docs `rendered` into what
+// mermaid draws.
+func markMermaid(rendered string) string {
+    return mermaidHTMLPat.ReplaceAllString(rendered, `<pre class="mermaid">$1</pre>`)
+}
+
+// The scripts for pages with diagrams.
+func mermaidScripts() string {
+    out := fmt.Sprintf("<script src=\"%s\"></script>\n", html.EscapeString(mermaidJS))
+    if mermaidSource != "" {
+        out = "<script>\n" + strings.Replace(mermaidSource, "</script", `<\/script`, -1) + "\n</script>\n"
+    }
+    return out + "<script>\n" + mermaidInit + "</script>\n"
+}
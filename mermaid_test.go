@@ -0,0 +1,74 @@
+package main
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestMermaidProblem(t *testing.T) {
+    cases := []struct{ body, want string }{
+        {"graph TD\n    A[Start] --> B{Is it?}\n    B -->|Yes| C(Done)", ""},
+        {"flowchart LR\n    id1>Asymmetric] --> id2", ""},
+        {"flowchart LR\n    A --> B>text]\n    B ==> C", ""},
+        {"flowchart LR\n    A[\"a ] in quotes\"] --> B", ""},
+        {"flowchart LR\n    %% a comment ]\n    A --> B", ""},
+        {"---\ntitle: Flow\n---\n%% comment\n\ngraph TD\n    A --> B", ""},
+        {"sequenceDiagram\n    Alice->>Bob: Hi ]", ""},
+        {"graph TD\n    A[Start --> B", `has an unclosed '['`},
+        {"graph TD\n    A(Start] --> B", `has an unmatched ']'`},
+        {"graph TD\n    A --> B)", `has an unmatched ')'`},
+        {"flowchart LR\n    id1>text) --> B", `has an unmatched ')'`},
+        {"", "is empty"},
+        {"%% just a comment", "is empty"},
+        {"flowchat TD\n    A --> B", `starts "flowchat", not a diagram type`},
+    }
+    for _, c := range cases {
+        if got := mermaidProblem(strings.Split(c.body, "\n")); got != c.want {
+            t.Errorf("mermaidProblem(%q) = %q, want %q", c.body, got, c.want)
+        }
+    }
+}
+
+func TestMarkMermaid(t *testing.T) {
+    cases := []struct{ rendered, want string }{
+        {"<pre><code class=\"language-mermaid\">graph TD\n    A --&gt; B\n</code></pre>\n",
+            "<pre class=\"mermaid\">graph TD\n    A --&gt; B\n</pre>\n"},
+        {"<pre><code class=\"language-go\">x := 1\n</code></pre>\n<pre><code>plain\n</code></pre>\n",
+            "<pre><code class=\"language-go\">x := 1\n</code></pre>\n<pre><code>plain\n</code></pre>\n"},
+    }
+    for _, c := range cases {
+        if got := markMermaid(c.rendered); got != c.want {
+            t.Errorf("markMermaid(%q) = %q, want %q", c.rendered, got, c.want)
+        }
+    }
+}
+
+// Pages with diagrams load mermaid, and only those, and with
+// `--strict` a diagram that looks wrong fails the build.
+func TestMermaidPages(t *testing.T) {
+    dir := writeFiles(t, map[string]string{
+        "diagram.go": "// ```mermaid\n// flowchart LR\n//     id1>Asymmetric] --> id2\n// ```\npackage a\n",
+        "plain.go":   "// ```go\n// x := 1\n// ```\npackage a\n",
+        "broken.go":  "// ```mermaid\n// graph TD\n//     A[Start --> B\n// ```\npackage a\n",
+    })
+    script := `<script src="` + defaultMermaidJS + `"></script>`
+    for _, c := range []struct {
+        file    string
+        diagram bool
+    }{{"diagram.go", true}, {"plain.go", false}} {
+        stdout, stderr, code := runGolit(t, dir, "--remote-css", "--no-vcs-info", "--strict", c.file)
+        if code != 0 {
+            t.Fatalf("%s: exit %d: %s", c.file, code, stderr)
+        }
+        if got := strings.Contains(stdout, script); got != c.diagram {
+            t.Errorf("%s: mermaid script %v, want %v", c.file, got, c.diagram)
+        }
+        if got := strings.Contains(stdout, `<pre class="mermaid">`); got != c.diagram {
+            t.Errorf("%s: diagram %v, want %v", c.file, got, c.diagram)
+        }
+    }
+    _, stderr, code := runGolit(t, dir, "--remote-css", "--no-vcs-info", "--strict", "broken.go")
+    if code != exitRender || !strings.Contains(stderr, "broken.go:1: mermaid diagram has an unclosed '['") {
+        t.Errorf("broken diagram: exit %d: %s", code, stderr)
+    }
+}
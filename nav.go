@@ -0,0 +1,215 @@
+// ### Navigation
+
+// With `--nav`, every page of a multi-file build gets a sidebar with
+// the whole tree of files, the current one highlighted. Directories
+// are `<details>` elements, so readers can fold them away, and with
+// no script involved the tree simply shows fully expanded.
+//
+// Whatever the flags, each page of a multi-file build starts with a
+// breadcrumb trail of the directories it's in, and ends with links to
+// the previous and next pages, so the files can be read in order like
+// chapters.
+
+package main
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "html"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+var showNav bool
+
+func init() {
+    flag.BoolVar(&showNav, "nav", false, "add a sidebar listing every page of a multi-file build")
+}
+
+// A file or directory in the sidebar tree. Files have the path of
+// their page; directories have children.
+type navNode struct {
+    name     string
+    page     string
+    children []*navNode
+}
+
+// The tree for the whole build, worked out once from the sources and
+// the pages they render to and then drawn into each page.
+var navTree *navNode
+
+func buildNav(outputs map[string]string) *navNode {
+    root := &navNode{}
+    for source, page := range outputs {
+        rel, err := filepath.Rel(outDir, page)
+        if err != nil {
+            continue
+        }
+        node := root
+        dirs := strings.Split(filepath.ToSlash(filepath.Dir(rel)), "/")
+        for _, dir := range dirs {
+            if dir == "." {
+                continue
+            }
+            node = node.child(dir)
+        }
+        node.children = append(node.children, &navNode{name: filepath.Base(source), page: page})
+    }
+    root.sort()
+    return root
+}
+
+// Find or add the directory `name` under `n`.
+func (n *navNode) child(name string) *navNode {
+    for _, child := range n.children {
+        if child.page == "" && child.name == name {
+            return child
+        }
+    }
+    child := &navNode{name: name}
+    n.children = append(n.children, child)
+    return child
+}
+
+// Order each level with files before directories, then by name.
+func (n *navNode) sort() {
+    sort.Slice(n.children, func(i, j int) bool {
+        a, b := n.children[i], n.children[j]
+        if (a.page == "") != (b.page == "") {
+            return a.page != ""
+        }
+        return a.name < b.name
+    })
+    for _, child := range n.children {
+        child.sort()
+    }
+}
+
+// Draw the sidebar for the page at `current`, with links relative to
+// it. The index page passes its own path, which matches no file.
+func renderNav(current string) string {
+    if !showNav || navTree == nil {
+        return ""
+    }
+    var out bytes.Buffer
+    fmt.Fprint(&out, "<nav id=\"nav\" aria-label=\"Files\">\n")
+    index, _ := filepath.Rel(filepath.Dir(current), filepath.Join(outDir, "index.html"))
+    fmt.Fprintf(&out, "<a href=\"%s\">Index</a>\n", html.EscapeString(filepath.ToSlash(index)))
+    out.WriteString(searchBox(current))
+    writeNavList(&out, navTree, current)
+    fmt.Fprint(&out, "</nav>\n")
+    return out.String()
+}
+
+func writeNavList(out *bytes.Buffer, node *navNode, current string) {
+    fmt.Fprint(out, "<ul>\n")
+    for _, child := range node.children {
+        if child.page == "" {
+            fmt.Fprintf(out, "<li><details open><summary>%s</summary>\n", html.EscapeString(child.name))
+            writeNavList(out, child, current)
+            fmt.Fprint(out, "</details></li>\n")
+            continue
+        }
+        rel, err := filepath.Rel(filepath.Dir(current), child.page)
+        if err != nil {
+            continue
+        }
+        class := ""
+        if child.page == current {
+            class = ` class="current"`
+        }
+        fmt.Fprintf(out, "<li><a href=\"%s\"%s>%s</a></li>\n", html.EscapeString(filepath.ToSlash(rel)), class, html.EscapeString(child.name))
+    }
+    fmt.Fprint(out, "</ul>\n")
+}
+
+// The pages of a multi-file build in the order we built them.
+var pageOrder []navNode
+
+// Draw the previous/next links for the page at `current`. The first
+// and last pages only get the one link, and single-file builds, with
+// no `pageOrder`, get none at all.
+func renderPager(current string) string {
+    links := []string{}
+    for i, node := range pageOrder {
+        if node.page != current {
+            continue
+        }
+        if i > 0 {
+            links = append(links, pagerLink(current, pageOrder[i-1], "prev", "← "+pageOrder[i-1].name))
+        }
+        if i < len(pageOrder)-1 {
+            links = append(links, pagerLink(current, pageOrder[i+1], "next", pageOrder[i+1].name+" →"))
+        }
+    }
+    if len(links) == 0 {
+        return ""
+    }
+    return "<nav id=\"pager\" aria-label=\"Pages\">" + strings.Join(links, " | ") + "</nav>\n"
+}
+
+func pagerLink(current string, target navNode, rel, label string) string {
+    href, err := filepath.Rel(filepath.Dir(current), target.page)
+    if err != nil {
+        return ""
+    }
+    return fmt.Sprintf("<a href=\"%s\" rel=\"%s\">%s</a>", html.EscapeString(filepath.ToSlash(href)), rel, html.EscapeString(label))
+}
+
+// Draw the breadcrumb trail for the page at `current`, like
+// `module › internal › store › sqlite.go`. Each directory links to
+// its index page if the build has one; pages at the top of the output
+// just show the site's name.
+func renderBreadcrumbs(current string) string {
+    if len(pageOrder) == 0 {
+        return ""
+    }
+    rel, err := filepath.Rel(outDir, current)
+    if err != nil {
+        return ""
+    }
+    parts := strings.Split(filepath.ToSlash(rel), "/")
+    crumbs := []string{crumb(current, outDir, siteName)}
+    if len(parts) > 1 {
+        dir := outDir
+        for _, part := range parts[:len(parts)-1] {
+            dir = filepath.Join(dir, part)
+            crumbs = append(crumbs, crumb(current, dir, part))
+        }
+        for _, node := range pageOrder {
+            if node.page == current {
+                crumbs = append(crumbs, html.EscapeString(node.name))
+            }
+        }
+    }
+    return "<nav id=\"breadcrumbs\" aria-label=\"Breadcrumbs\">" + strings.Join(crumbs, " › ") + "</nav>\n"
+}
+
+// One breadcrumb for `dir`, linked if there's an index page there.
+func crumb(current, dir, label string) string {
+    index := filepath.Join(dir, "index.html")
+    if !indexPages[index] {
+        return html.EscapeString(label)
+    }
+    href, err := filepath.Rel(filepath.Dir(current), index)
+    if err != nil {
+        return html.EscapeString(label)
+    }
+    return fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(filepath.ToSlash(href)), html.EscapeString(label))
+}
+
+// Tag the pages of test files, so they're not mistaken for the
+// package proper, and those the coverage profile has with how much
+// of them the tests ran.
+func fileBadges(sourcePath string) string {
+    badges := coverageBadge(sourcePath)
+    if isTestFile(sourcePath) {
+        badges = "<span class=\"badge\">test</span>" + badges
+    }
+    if badges == "" {
+        return ""
+    }
+    return "<div id=\"badges\">" + badges + "</div>\n"
+}
@@ -0,0 +1,396 @@
+// ### Page template
+
+// Every page golit writes, the index included, comes from one
+// `html/template`, by default the one built in from
+// `resources/page.html`. `--template` swaps in another, and
+// `--print-template` prints ours as a place to start. The template
+// sees a `page`: its title as plain text, the stylesheets, navigation
+// and pager as HTML we've already built, and the rendered segments.
+// `--header-file` and `--footer-file` are smaller templates, given
+// the same `page`, whose output goes above and below the docs.
+// The segments arrive over a channel as they're finished, so a page
+// is still written out while the rest of it renders, and the template
+// ranges over them like any other list.
+
+package main
+
+import (
+    _ "embed"
+    "errors"
+    "flag"
+    "fmt"
+    "html/template"
+    "io"
+    "io/ioutil"
+    "strings"
+)
+
+var templatePath string
+var printTemplate bool
+
+// How segments are laid out by the built-in template, from
+// `--layout`: side by side in a `table`, as docco does, `linear`, with
+// each segment's code below its docs in a single column, or `stacked`,
+// the same but with each segment in a `<section>` of its own.
+var layout = "table"
+
+var layouts = map[string]bool{"table": true, "linear": true, "stacked": true}
+
+// With `--fragment`, pages are just their segments, laid out as usual
+// but without the document around them, for including in pages of
+// another site. This is the template's `content`.
+var fragment bool
+
+// Templates for a banner above each page's docs and a footer below
+// them, from `--header-file` and `--footer-file`.
+var headerPath, footerPath string
+var headerTemplate, footerTemplate *template.Template
+
+// Markup for the end of every page's `<head>`, from `--head-html`,
+// each either given literally or read from the file after an `@`.
+var headFlags stringList
+var headHTML string
+
+func init() {
+    flag.StringVar(&templatePath, "template", "", "render pages with the html/template in `file` instead of the built-in one")
+    flag.BoolVar(&printTemplate, "print-template", false, "print the built-in page template and exit")
+    flag.StringVar(&layout, "layout", layout, "lay segments out as a side-by-side `table`, or linear or stacked, code below docs")
+    flag.BoolVar(&fragment, "fragment", false, "write only the segments of each page, with no <html>, <head> or <body>, for embedding")
+    flag.StringVar(&headerPath, "header-file", "", "html/template `file` for a banner above each page's docs")
+    flag.StringVar(&footerPath, "footer-file", "", "html/template `file` for a footer below each page's docs")
+    flag.Var(&headFlags, "head-html", "`HTML or @file` to add to the end of each page's <head>; may be repeated")
+}
+
+//go:embed resources/page.html
+var pageHTML string
+
+// The template pages are rendered with, and its text, which goes into
+// the build cache's options hash.
+var pageTemplate = template.Must(template.New("page").Funcs(templateFuncs).Parse(pageHTML))
+var templateText = pageHTML
+
+// Use the template at `templatePath`, if one was given, in place of
+// the built-in one, and parse the header and footer.
+func loadTemplate() error {
+    if !layouts[layout] {
+        return fmt.Errorf("unknown --layout %q; use table, linear or stacked", layout)
+    }
+    var err error
+    if templatePath != "" {
+        if pageTemplate, err = parseTemplateFile(templatePath); err != nil {
+            return err
+        }
+    }
+    if headerPath != "" {
+        if headerTemplate, err = parseTemplateFile(headerPath); err != nil {
+            return err
+        }
+    }
+    if footerPath != "" {
+        if footerTemplate, err = parseTemplateFile(footerPath); err != nil {
+            return err
+        }
+    }
+    if fragment && pageTemplate.Lookup("content") == nil {
+        return fmt.Errorf("--fragment needs a template that defines \"content\"")
+    }
+    return nil
+}
+
+// Parse the template in the file at `path`, adding its text to
+// `templateText`.
+func parseTemplateFile(path string) (*template.Template, error) {
+    text, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    t, err := template.New(path).Funcs(templateFuncs).Parse(string(text))
+    if err != nil {
+        return nil, templateError(err)
+    }
+    templateText += "\x00" + string(text)
+    return t, nil
+}
+
+// Execute the header or footer template `t` for `p`, giving "" when
+// there's none or it comes out blank. The partials can't have the
+// segments, which are the main template's to range over.
+func renderPartial(t *template.Template, p page) (template.HTML, error) {
+    if t == nil {
+        return "", nil
+    }
+    t, err := forPage(t, p.path)
+    if err != nil {
+        return "", err
+    }
+    none := make(chan pageSegment)
+    close(none)
+    p.Segments = none
+    var out strings.Builder
+    if err := t.Execute(&out, p); err != nil {
+        return "", templateError(err)
+    }
+    if strings.TrimSpace(out.String()) == "" {
+        return "", nil
+    }
+    return template.HTML(strings.TrimRight(out.String(), "\n")), nil
+}
+
+// Gather the `--head-html` markup, in the order given.
+func loadHeadHTML() error {
+    for _, value := range headFlags {
+        if strings.HasPrefix(value, "@") {
+            data, err := ioutil.ReadFile(value[1:])
+            if err != nil {
+                return err
+            }
+            value = string(data)
+        }
+        headHTML += strings.TrimRight(value, "\n") + "\n"
+    }
+    return nil
+}
+
+// Template errors start with the package they came from, ahead of
+// the `file:line:` we'd rather lead with.
+func templateError(err error) error {
+    msg := strings.TrimPrefix(err.Error(), "template: ")
+    msg = strings.TrimPrefix(msg, "html/template:")
+    return errors.New(msg)
+}
+
+// What the page template is executed with. `Site` names the build,
+// if it's a multi-file one. `Nav` goes above the page and `Pager`
+// below it, and `TOC` and `Symbols` at the top of the page proper.
+// `CodeOnly` pages are listings rather than docs, `DocsOnly` pages
+// articles of docs without the code, and `ThemeMode` is
+// `--theme-mode`. `Revision` is what the sources were checked out at,
+// if they're in git. `Scripts`, for line ranges, copy buttons and
+// keys, go at the end of the `<body>`. All but `Title`, `CSS`, `Nav`,
+// `Pager`, `TOC`, `Symbols`, `API` and `Metadata` are filled in by
+// `writePage`. `API` is the appendix of exported declarations, and
+// `Description` the docs the page opens with, as Markdown.
+type page struct {
+    Title       string
+    Description string
+    Site        string
+    Layout      string
+    CodeOnly    bool
+    DocsOnly    bool
+    ThemeMode   string
+    CSS         template.HTML
+    Head        template.HTML
+    Nav         template.HTML
+    Pager       template.HTML
+    TOC         template.HTML
+    Symbols     template.HTML
+    API         []apiEntry
+    Header      template.HTML
+    Footer      template.HTML
+    Metadata    pageMetadata
+    GeneratedBy string
+    Revision    *revision
+    Scripts     template.HTML
+    Segments    <-chan pageSegment
+    // Where the page is going, for `relurl`, and whether it needs the
+    // scripts for playground buttons, diagrams and math.
+    path                      string
+    playground, mermaid, math bool
+}
+
+// About the source of a page, where it has just the one, including
+// where it's browsed and edited with `--repo-url`.
+type pageMetadata struct {
+    Source    string
+    Package   string
+    SourceURL string
+    EditURL   string
+}
+
+// One docs/code row of a page. `Anchor` is an `id` for linking to
+// it, unique within the page, and `Wide` rows have docs alone, across
+// the full width. `Header` rows start with a header comment, and
+// `HasDocs` and `HasCode` say whether there's anything but blank lines
+// in either half. `SourceURL` links to it in the repository, and
+// `Commit` names the commit that last changed it, at `CommitURL`.
+// `Code` is its code as it is in the source, for copying, and `Fold`
+// says to fold the code away, with `FoldLines` lines in it. `License`
+// docs are a license header, to fold away. `Playground` is the program
+// a segment ending one runs, shared at `PlaygroundURL` if it has been,
+// and `Output` is what an example's code prints, in any order if it's
+// `Unordered`. `Benchmarks` are a benchmark's results.
+type pageSegment struct {
+    Anchor        string
+    DocsHTML      template.HTML
+    CodeHTML      template.HTML
+    Wide          bool
+    Header        bool
+    HasDocs       bool
+    HasCode       bool
+    SourceURL     string
+    Commit        string
+    CommitURL     string
+    Code          string
+    Fold          bool
+    FoldLines     int
+    License       bool
+    Playground    string
+    PlaygroundURL string
+    Output        string
+    Unordered     bool
+    Benchmarks    []benchRow
+}
+
+// What every segment's `id` starts with.
+const anchorPrefix = "section-"
+
+// The `id` of the `n`th segment of a page, counting from 1.
+func sectionAnchor(n int) string {
+    return fmt.Sprintf("%s%d", anchorPrefix, n)
+}
+
+func segmentFor(seg *seg) pageSegment {
+    s := pageSegment{
+        Anchor:        seg.anchor,
+        DocsHTML:      template.HTML(seg.docsRendered),
+        CodeHTML:      template.HTML(seg.codeRendered),
+        Wide:          seg.wide,
+        Header:        seg.header,
+        HasDocs:       strings.TrimSpace(seg.docs) != "",
+        HasCode:       !seg.wide && strings.TrimSpace(seg.code) != "",
+        SourceURL:     seg.sourceURL,
+        Code:          copyCode(seg),
+        Fold:          seg.fold && !seg.wide,
+        FoldLines:     foldLines(seg),
+        License:       seg.license,
+        Playground:    seg.program,
+        PlaygroundURL: seg.programURL,
+        Output:        seg.output,
+        Unordered:     seg.unordered,
+        Benchmarks:    seg.benchmarks,
+    }
+    s.Commit, s.CommitURL = commitLink(seg.commit)
+    return s
+}
+
+// With `--offline-js`, a page can only load the scripts there are
+// local copies of.
+func checkOfflineScripts(p page) error {
+    switch {
+    case !offlineJS:
+        return nil
+    case p.mermaid && mermaidSource == "":
+        return errors.New("--offline-js: the page has diagrams, but --mermaid-js doesn't name a local copy of mermaid.js")
+    case p.math && katexJS == "":
+        return errors.New("--offline-js: the page has math, but --katex doesn't name a local copy of KaTeX")
+    }
+    return nil
+}
+
+// The docs of the first of `segs` with more than headers in them,
+// passing over a license, as they are in the source.
+func description(segs []*seg) string {
+    for _, seg := range segs {
+        if seg.license {
+            continue
+        }
+        for _, line := range strings.Split(seg.docs, "\n") {
+            if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+                return seg.docs
+            }
+        }
+    }
+    return ""
+}
+
+// Execute the page template for `p` into `w`, with its segments
+// coming from `produce`, which passes each to `emit` in order. A
+// failure to produce them is reported ahead of any failure to write
+// them, since it's usually the cause.
+func writePage(w io.Writer, p page, produce func(emit func(pageSegment) error) error) error {
+    var err error
+    p.Layout, p.CodeOnly, p.DocsOnly, p.ThemeMode = layout, codeOnly, docsOnly, themeMode
+    p.Head, p.GeneratedBy = template.HTML(headHTML), version()
+    p.Revision = buildRevision
+    if !noJS {
+        p.Scripts = template.HTML(lineScripts() + copyScripts() + keyScripts() + searchScripts())
+        if p.playground {
+            p.Scripts += template.HTML(playgroundScripts())
+        }
+        if err := checkOfflineScripts(p); err != nil {
+            return err
+        }
+        if p.mermaid {
+            p.Scripts += template.HTML(mermaidScripts())
+        }
+        if p.math {
+            p.Head += template.HTML(katexHead())
+            p.Scripts += template.HTML(mathScripts())
+        }
+    }
+    if outDir != "" {
+        p.Site = siteName
+    }
+    if p.Header, err = renderPartial(headerTemplate, p); err != nil {
+        return err
+    }
+    if p.Footer, err = renderPartial(footerTemplate, p); err != nil {
+        return err
+    }
+
+    segs := make(chan pageSegment)
+    stop := make(chan bool)
+    produced := make(chan error, 1)
+    go func() {
+        defer close(segs)
+        n := 0
+        produced <- produce(func(s pageSegment) error {
+            n++
+            if s.Anchor == "" {
+                s.Anchor = sectionAnchor(n)
+            }
+            select {
+            case segs <- s:
+                return nil
+            case <-stop:
+                return errStopped
+            }
+        })
+    }()
+    t, err := forPage(pageTemplate, p.path)
+    if err != nil {
+        return err
+    }
+    p.Segments = segs
+    out := &pageWriter{w: w}
+    if fragment {
+        if err = t.ExecuteTemplate(out, "content", p); err == nil {
+            _, err = io.WriteString(out, "\n")
+        }
+    } else {
+        err = t.Execute(out, p)
+    }
+    close(stop)
+    if perr := <-produced; perr != nil && perr != errStopped {
+        return perr
+    }
+    if err != nil && out.err == nil {
+        return templateError(err)
+    }
+    return err
+}
+
+// Remembers whether writing failed, to tell that apart from an error
+// in the template.
+type pageWriter struct {
+    w   io.Writer
+    err error
+}
+
+func (w *pageWriter) Write(p []byte) (int, error) {
+    n, err := w.w.Write(p)
+    if err != nil {
+        w.err = err
+    }
+    return n, err
+}
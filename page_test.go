@@ -0,0 +1,183 @@
+package main
+
+import (
+    "html"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "testing"
+
+    xhtml "golang.org/x/net/html"
+)
+
+// A title with markup in it comes back out of the page as the same
+// text, and never as markup.
+func TestTitleRoundTrip(t *testing.T) {
+    title := `Docs</title><script>alert("x")</script> & 'more'`
+    dir := writeFiles(t, map[string]string{"a.go": "// Docs.\npackage p\n"})
+    out, stderr, code := runGolit(t, dir, "--remote-css", "a.go", title)
+    if code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    match := titlePat.FindStringSubmatch(out)
+    if match == nil || html.UnescapeString(match[1]) != title {
+        t.Errorf("title came back as %q, want %q", match, title)
+    }
+    if strings.Contains(out, "<script>alert") {
+        t.Error("title's markup got into the page")
+    }
+}
+
+// The same goes for titles made from file names, wherever a build
+// shows them.
+func TestFileNameTitles(t *testing.T) {
+    name := `a<b>&"c".go`
+    dir := writeFiles(t, map[string]string{name: "// Docs.\npackage p\n", "d.go": "// More.\npackage p\n"})
+    if _, stderr, code := runGolit(t, dir, "--nav", "--out-dir", "out", name, "d.go"); code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    err := filepath.Walk(filepath.Join(dir, "out"), func(path string, info os.FileInfo, err error) error {
+        if err != nil || filepath.Ext(path) != ".html" {
+            return err
+        }
+        page, err := ioutil.ReadFile(path)
+        if err != nil {
+            return err
+        }
+        if strings.Contains(string(page), "a<b>") || !strings.Contains(string(page), "a&lt;b&gt;&amp;") {
+            t.Errorf("%s doesn't escape %q", path, name)
+        }
+        return nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+}
+
+// Pages for a fixture, in each layout, are just as they were.
+func TestGoldenPages(t *testing.T) {
+    for _, c := range []struct {
+        name string
+        args []string
+    }{
+        {"table", []string{"--layout", "table"}},
+        {"linear", []string{"--layout", "linear"}},
+        {"stacked", []string{"--layout", "stacked"}},
+        {"docs-only", []string{"--docs-only"}},
+    } {
+        args := append([]string{"--remote-css", "--no-vcs-info"}, c.args...)
+        out, stderr, code := runGolit(t, ".", append(args, "testdata/pages/basic.go")...)
+        if code != 0 {
+            t.Fatalf("exit %d: %s", code, stderr)
+        }
+        golden(t, "testdata/pages/basic."+c.name+".html", out)
+    }
+}
+
+// A docs-only page is an article, with no table in any layout.
+func TestDocsOnlyArticle(t *testing.T) {
+    for _, layout := range []string{"table", "linear", "stacked"} {
+        out, stderr, code := runGolit(t, ".", "--docs-only", "--layout", layout, "testdata/pages/basic.go")
+        if code != 0 {
+            t.Fatalf("exit %d: %s", code, stderr)
+        }
+        if !strings.Contains(out, `<article id="article">`) || strings.Contains(out, "<table") || strings.Contains(out, `id="background"`) {
+            t.Errorf("--docs-only --layout %s isn't laid out as an article:\n%s", layout, out)
+        }
+    }
+}
+
+// Elements that have no end tag.
+var voidElements = map[string]bool{
+    "area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+    "input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+var unquotedAttrPat = regexp.MustCompile(`\s[a-z-]+=[^"'\s>]`)
+
+// Check that `page` is HTML5 as a parser would read it without having
+// to fix anything up: a doctype first, attribute values quoted, every
+// element closed where it was opened, a charset, and unique ids.
+// Returns the parsed page.
+func checkHTML5(t *testing.T, name, page string) *xhtml.Node {
+    t.Helper()
+    if !strings.HasPrefix(page, "<!DOCTYPE html>\n") {
+        t.Errorf("%s doesn't start with a doctype", name)
+    }
+    open := []string{}
+    z := xhtml.NewTokenizer(strings.NewReader(page))
+    for {
+        kind := z.Next()
+        if kind == xhtml.ErrorToken {
+            break
+        }
+        token := z.Token()
+        switch kind {
+        case xhtml.StartTagToken:
+            if raw := regexp.MustCompile(`"[^"]*"`).ReplaceAllString(string(z.Raw()), `""`); unquotedAttrPat.MatchString(raw) {
+                t.Errorf("%s: unquoted attribute in %s", name, z.Raw())
+            }
+            if !voidElements[token.Data] {
+                open = append(open, token.Data)
+            }
+        case xhtml.EndTagToken:
+            if len(open) == 0 || open[len(open)-1] != token.Data {
+                t.Errorf("%s: </%s> closes %q", name, token.Data, open)
+                return nil
+            }
+            open = open[:len(open)-1]
+        }
+    }
+    if len(open) > 0 {
+        t.Errorf("%s: %q never closed", name, open)
+    }
+    doc, err := xhtml.Parse(strings.NewReader(page))
+    if err != nil {
+        t.Fatalf("%s: %v", name, err)
+    }
+    ids := map[string]bool{}
+    charset := false
+    var walk func(n *xhtml.Node)
+    walk = func(n *xhtml.Node) {
+        if n.Type == xhtml.ElementNode {
+            for _, attr := range n.Attr {
+                switch {
+                case attr.Key == "id" && ids[attr.Val]:
+                    t.Errorf("%s: id %q used twice", name, attr.Val)
+                case attr.Key == "id":
+                    ids[attr.Val] = true
+                case n.Data == "meta" && attr.Key == "charset":
+                    charset = strings.EqualFold(attr.Val, "utf-8")
+                }
+            }
+            if n.Data == "td" && n.Parent.Parent != nil && n.Parent.Parent.Data == "thead" {
+                t.Errorf("%s: <td> in <thead>", name)
+            }
+        }
+        for c := n.FirstChild; c != nil; c = c.NextSibling {
+            walk(c)
+        }
+    }
+    walk(doc)
+    if !charset {
+        t.Errorf("%s has no <meta charset=\"utf-8\">", name)
+    }
+    return doc
+}
+
+// The golden pages are well-formed HTML5.
+func TestGoldenPagesHTML5(t *testing.T) {
+    paths, err := filepath.Glob("testdata/pages/*.html")
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, path := range paths {
+        page, err := ioutil.ReadFile(path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        checkHTML5(t, path, string(page))
+    }
+}
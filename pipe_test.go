@@ -0,0 +1,54 @@
+package main
+
+import (
+    "fmt"
+    "os/exec"
+    "strings"
+    "testing"
+    "time"
+)
+
+// A segment far bigger than a pipe's buffer goes through a program
+// that writes as it reads, without either side getting stuck.
+func TestPipeLarge(t *testing.T) {
+    cat, err := exec.LookPath("cat")
+    if err != nil {
+        t.Skip("no cat")
+    }
+    var src strings.Builder
+    for i := 0; src.Len() < 8<<20; i++ {
+        fmt.Fprintf(&src, "line %d of a segment that goes on and on\n", i)
+    }
+    type result struct {
+        out string
+        err error
+    }
+    done := make(chan result, 1)
+    go func() {
+        out, err := pipe(cat, nil, src.String())
+        done <- result{out, err}
+    }()
+    select {
+    case r := <-done:
+        if r.err != nil {
+            t.Fatal(r.err)
+        }
+        if r.out != src.String() {
+            t.Errorf("got %d bytes back from cat, want the %d sent", len(r.out), src.Len())
+        }
+    case <-time.After(30 * time.Second):
+        t.Fatal("pipe through cat deadlocked")
+    }
+}
+
+// A failing program's error says what was run and what it said.
+func TestPipeError(t *testing.T) {
+    sh, err := exec.LookPath("sh")
+    if err != nil {
+        t.Skip("no sh")
+    }
+    _, err = pipe(sh, []string{"-c", "echo broken >&2; exit 3"}, "")
+    if err == nil || !strings.HasSuffix(err.Error(), "-c echo broken >&2; exit 3: broken") {
+        t.Errorf("error = %v, want the command line and its stderr", err)
+    }
+}
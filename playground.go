@@ -0,0 +1,291 @@
+// ### Playground links
+
+// A tutorial's snippets are more convincing when they can be run. A
+// `// golit:playground` line marks the code that follows it as a
+// program for the Go Playground, and `// golit:playground name` adds
+// the code after it to the program called `name`, so one program can
+// be built up across segments. A snippet doesn't have to be a whole
+// program: without a `package` clause it's put in `package main`, and
+// if it isn't declarations it's put in `func main` too, with imports
+// added for the standard packages it uses. The last segment of each
+// program gets a "Run in Playground" button, which shares the program
+// when it's clicked, or, with `--playground-share`, a link to it
+// shared while building. Each program is type-checked, and one that
+// doesn't check is warned about, at the line in the file that's wrong.
+
+package main
+
+import (
+    _ "embed"
+    "errors"
+    "flag"
+    "fmt"
+    "go/ast"
+    "go/format"
+    "go/importer"
+    "go/parser"
+    "go/scanner"
+    "go/token"
+    "go/types"
+    "io/ioutil"
+    "net/http"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+)
+
+var playgroundShare bool
+
+func init() {
+    flag.BoolVar(&playgroundShare, "playground-share", false, "share golit:playground programs while building, and link to them, rather than when they're run")
+}
+
+//go:embed resources/playground.js
+var playgroundScript string
+
+// Where programs are shared, and where they're run once they are.
+const (
+    playgroundShareURL = "https://play.golang.org/share"
+    playgroundRunURL   = "https://go.dev/play/p/"
+)
+
+// A playground directive marks a program; a name after it adds to the
+// program of that name.
+func playgroundDirective(docs string) (string, bool) {
+    fields := strings.Fields(docs)
+    if len(fields) == 0 || fields[0] != "golit:playground" || len(fields) > 2 {
+        return "", false
+    }
+    if len(fields) == 2 {
+        return fields[1], true
+    }
+    return "", true
+}
+
+// The standard packages a snippet can use without importing them,
+// by the name it uses them by. Where two share a name the first is
+// taken.
+var playgroundPackages = map[string]string{}
+
+func init() {
+    for _, p := range []string{
+        "bufio", "bytes", "context", "errors", "flag", "fmt", "html", "io",
+        "io/ioutil", "log", "math", "math/rand", "net/http", "net/url", "os",
+        "os/exec", "path/filepath", "reflect", "regexp", "sort", "strconv",
+        "strings", "sync", "sync/atomic", "text/template", "time", "unicode",
+        "unicode/utf8", "encoding/json", "encoding/base64", "encoding/hex",
+        "crypto/sha256", "container/heap", "container/list", "path",
+    } {
+        if _, ok := playgroundPackages[path.Base(p)]; !ok {
+            playgroundPackages[path.Base(p)] = p
+        }
+    }
+}
+
+// Put together the programs marked in `segs`, from the Go file at
+// `sourcePath`, each on the last segment of its code.
+func playgroundPrograms(sourcePath string, segs []*seg) error {
+    if docsOnly || filepath.Ext(sourcePath) != ".go" {
+        return nil
+    }
+    programs := [][]*seg{}
+    named := map[string]int{}
+    for _, part := range segs {
+        if !part.playground {
+            continue
+        }
+        if i, ok := named[part.snippet]; ok && part.snippet != "" {
+            programs[i] = append(programs[i], part)
+            continue
+        }
+        named[part.snippet] = len(programs)
+        programs = append(programs, []*seg{part})
+    }
+    for _, parts := range programs {
+        last := parts[len(parts)-1]
+        program, line, err := playgroundProgram(parts)
+        if err != nil {
+            if err := report(Diagnostic{Path: sourcePath, Line: line, Message: "playground program doesn't build: " + err.Error()}); err != nil {
+                return err
+            }
+            continue
+        }
+        last.program = program
+    }
+    if !playgroundShare {
+        return nil
+    }
+    for _, part := range segs {
+        if part.program == "" {
+            continue
+        }
+        id, err := sharePlayground(part.program)
+        if err != nil {
+            if err := report(Diagnostic{Path: sourcePath, Line: part.line, Message: "not sharing playground program: " + err.Error()}); err != nil {
+                return err
+            }
+            continue
+        }
+        part.programURL = playgroundRunURL + id
+    }
+    return nil
+}
+
+// The program made of the code of `parts`, or why it doesn't build
+// and the line of the source that's at fault.
+func playgroundProgram(parts []*seg) (string, int, error) {
+    // Which line of the source each line of the snippet is.
+    body, lines := []string{}, []int{}
+    for _, seg := range parts {
+        if seg.codeLine == 0 {
+            continue
+        }
+        if len(body) > 0 {
+            body, lines = append(body, ""), append(lines, seg.codeLine)
+        }
+        for i, line := range strings.Split(strings.Trim(seg.code, "\n"), "\n") {
+            body, lines = append(body, line), append(lines, seg.codeLine+i)
+        }
+    }
+    if len(body) == 0 {
+        return "", parts[0].line, errors.New("no code")
+    }
+    code := strings.Join(body, "\n") + "\n"
+
+    // The line of the source the `n`th line of the snippet is.
+    at := func(n int) int {
+        if n < 1 {
+            n = 1
+        } else if n > len(lines) {
+            n = len(lines)
+        }
+        return lines[n-1]
+    }
+
+    // Try it as it is, as declarations, and as statements, and take
+    // whichever parses, or else blame the one that got furthest.
+    wraps := []struct{ header, footer string }{
+        {"", ""},
+        {"package main\n", ""},
+        {"package main\nfunc main() {\n", "}\n"},
+    }
+    var file *ast.File
+    var fset *token.FileSet
+    var header string
+    var failed error
+    failedLine := 0
+    for _, wrap := range wraps {
+        fset = token.NewFileSet()
+        f, err := parser.ParseFile(fset, "", wrap.header+code+wrap.footer, 0)
+        if err == nil {
+            file, header, code = f, wrap.header, wrap.header+code+wrap.footer
+            break
+        }
+        if line := errorLine(err) - strings.Count(wrap.header, "\n"); failed == nil || line > failedLine {
+            failed, failedLine = err, line
+        }
+    }
+    if file == nil {
+        return "", at(failedLine), failed
+    }
+    if file.Name.Name != "main" {
+        return "", at(1), fmt.Errorf("package %s isn't main", file.Name.Name)
+    }
+    if file.Scope.Lookup("main") == nil {
+        return "", at(len(lines)), errors.New("no func main")
+    }
+
+    // A snippet that's part of a program is given imports for the
+    // standard packages it uses but doesn't import. A whole program
+    // is left as it is.
+    imported := map[string]bool{}
+    for _, spec := range file.Imports {
+        name := path.Base(strings.Trim(spec.Path.Value, `"`))
+        if spec.Name != nil {
+            name = spec.Name.Name
+        }
+        imported[name] = true
+    }
+    missing := []string{}
+    ast.Inspect(file, func(n ast.Node) bool {
+        if sel, ok := n.(*ast.SelectorExpr); ok {
+            if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil && !imported[x.Name] && playgroundPackages[x.Name] != "" {
+                imported[x.Name] = true
+                missing = append(missing, playgroundPackages[x.Name])
+            }
+        }
+        return true
+    })
+    if header != "" && len(missing) > 0 {
+        sort.Strings(missing)
+        imports := "import (\n"
+        for _, p := range missing {
+            imports += fmt.Sprintf("%q\n", p)
+        }
+        imports += ")\n"
+        header = "package main\n" + imports + header[len("package main\n"):]
+        code = "package main\n" + imports + code[len("package main\n"):]
+        fset = token.NewFileSet()
+        var err error
+        if file, err = parser.ParseFile(fset, "", code, 0); err != nil {
+            return "", at(errorLine(err) - strings.Count(header, "\n")), err
+        }
+    }
+
+    config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+    if _, err := config.Check("main", fset, []*ast.File{file}, nil); err != nil {
+        if terr, ok := err.(types.Error); ok {
+            return "", at(terr.Fset.Position(terr.Pos).Line - strings.Count(header, "\n")), errors.New(terr.Msg)
+        }
+        return "", at(1), err
+    }
+    if formatted, err := format.Source([]byte(code)); err == nil {
+        code = string(formatted)
+    }
+    return code, 0, nil
+}
+
+// The line a parse error is on.
+func errorLine(err error) int {
+    if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+        return list[0].Pos.Line
+    }
+    return 0
+}
+
+// Share `program` with the playground, and return its id.
+func sharePlayground(program string) (string, error) {
+    client := http.Client{Timeout: 10 * time.Second}
+    resp, err := client.Post(playgroundShareURL, "text/plain; charset=utf-8", strings.NewReader(program))
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+    body, err := ioutil.ReadAll(resp.Body)
+    if err != nil {
+        return "", err
+    }
+    id := strings.TrimSpace(string(body))
+    if resp.StatusCode != http.StatusOK || id == "" || strings.ContainsAny(id, "/<> \n") {
+        return "", fmt.Errorf("%s said %s", playgroundShareURL, resp.Status)
+    }
+    return id, nil
+}
+
+// Whether any of `segs` has a playground button.
+func hasPlayground(segs []*seg) bool {
+    for _, seg := range segs {
+        if seg.program != "" && seg.programURL == "" {
+            return true
+        }
+    }
+    return false
+}
+
+// The script for the buttons.
+func playgroundScripts() string {
+    script := strings.Replace(playgroundScript, "SHARE_URL", fmt.Sprintf("%q", playgroundShareURL), 1)
+    return "<script>\n" + strings.Replace(script, "'https://go.dev/play/p/'", fmt.Sprintf("%q", playgroundRunURL), 1) + "</script>\n"
+}
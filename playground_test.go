@@ -0,0 +1,70 @@
+package main
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestPlaygroundProgram(t *testing.T) {
+    cases := []struct {
+        name  string
+        parts []*seg
+        want  string
+        line  int
+        err   string
+    }{
+        {"statements", []*seg{{codeLine: 5, code: "\nfmt.Println(strings.ToUpper(\"hi\"))\n"}},
+            "package main\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n\nfunc main() {\n\tfmt.Println(strings.ToUpper(\"hi\"))\n}\n", 0, ""},
+        {"two segments", []*seg{
+            {codeLine: 3, code: "func greet() string { return \"hi\" }\n"},
+            {codeLine: 10, code: "func main() { fmt.Println(greet()) }\n"},
+        }, "package main\n\nimport (\n\t\"fmt\"\n)\n\nfunc greet() string { return \"hi\" }\n\nfunc main() { fmt.Println(greet()) }\n", 0, ""},
+        {"whole program", []*seg{{codeLine: 1, code: "package main\n\nfunc main() {}\n"}},
+            "package main\n\nfunc main() {}\n", 0, ""},
+        {"type error", []*seg{
+            {codeLine: 3, code: "x := 1\n"},
+            {codeLine: 10, code: "_ = x\nvar s string = x\n_ = s\n"},
+        }, "", 11, "cannot use x"},
+        {"parse error", []*seg{{codeLine: 4, code: "func main() {\n\tx := \n}\n"}}, "", 6, "expected"},
+        {"not main", []*seg{{codeLine: 7, code: "package lib\n\nfunc F() {}\n"}}, "", 7, "package lib isn't main"},
+        {"no main", []*seg{{codeLine: 2, code: "func f() {}\nfunc g() {}\n"}}, "", 3, "no func main"},
+    }
+    for _, c := range cases {
+        got, line, err := playgroundProgram(c.parts)
+        if c.err != "" {
+            if err == nil || !strings.Contains(err.Error(), c.err) || line != c.line {
+                t.Errorf("%s: line %d, error %v; want line %d, %q", c.name, line, err, c.line, c.err)
+            }
+            continue
+        }
+        if err != nil || got != c.want {
+            t.Errorf("%s: program, error = %v:\n%s\nwant:\n%s", c.name, err, got, c.want)
+        }
+    }
+}
+
+// A named program takes in each segment marked with its name, and
+// goes on the last of them.
+func TestPlaygroundPrograms(t *testing.T) {
+    segs := []*seg{
+        {playground: true, snippet: "hello", line: 1, codeLine: 2, code: "func greet() string { return \"hi\" }\n"},
+        {playground: true, line: 4, codeLine: 5, code: "fmt.Println(1)\n"},
+        {line: 7, codeLine: 7, code: "var unmarked = 1\n"},
+        {playground: true, snippet: "hello", line: 9, codeLine: 10, code: "func main() { println(greet()) }\n"},
+    }
+    if err := playgroundPrograms("a.go", segs); err != nil {
+        t.Fatal(err)
+    }
+    if segs[0].program != "" || segs[2].program != "" {
+        t.Errorf("program on a segment that isn't the last of one")
+    }
+    if !strings.Contains(segs[1].program, "func main() {\n\tfmt.Println(1)\n}") {
+        t.Errorf("unnamed program:\n%s", segs[1].program)
+    }
+    if want := "package main\n\nfunc greet() string { return \"hi\" }\n\nfunc main() { println(greet()) }\n"; segs[3].program != want {
+        t.Errorf("named program:\n%s\nwant:\n%s", segs[3].program, want)
+    }
+    if !hasPlayground(segs) {
+        t.Error("no playground button")
+    }
+}
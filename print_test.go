@@ -0,0 +1,91 @@
+package main
+
+import (
+    "strings"
+    "testing"
+)
+
+// The declarations for each selector inside the `@media` queries of
+// `css` that apply to `medium`.
+func mediaRules(css, medium string) map[string]string {
+    rules := map[string]string{}
+    for rest := css; ; {
+        i := strings.Index(rest, "@media")
+        if i < 0 {
+            return rules
+        }
+        open := strings.IndexByte(rest[i:], '{') + i
+        depth, end := 1, open+1
+        for ; depth > 0 && end < len(rest); end++ {
+            switch rest[end] {
+            case '{':
+                depth++
+            case '}':
+                depth--
+            }
+        }
+        if strings.Contains(rest[i:open], medium) {
+            for _, rule := range strings.Split(rest[open+1:end-1], "}") {
+                parts := strings.SplitN(rule, "{", 2)
+                if len(parts) != 2 {
+                    continue
+                }
+                for _, selector := range strings.Split(parts[0], ",") {
+                    rules[strings.TrimSpace(selector)] += parts[1]
+                }
+            }
+        }
+        rest = rest[end:]
+    }
+}
+
+// Printed, a page drops everything that's only for clicking, lays its
+// sections out one after another and wraps its code, whatever the theme.
+func TestPrintRules(t *testing.T) {
+    defer func(saved string) { theme = saved }(theme)
+    cases := []struct {
+        selector, declaration string
+    }{
+        {"#nav", "display: none"},
+        {"#pager", "display: none"},
+        {"#breadcrumbs", "display: none"},
+        {".source-link", "display: none"},
+        {".seglinks", "display: none"},
+        {".pilcrow", "display: none"},
+        {"button.copy", "display: none"},
+        {".playground", "display: none"},
+        {"#background", "display: none"},
+        {".skip-link", "display: none"},
+        {".search", "display: none"},
+        {".symbols", "display: none"},
+        {"table", "display: block"},
+        {"td.code", "display: block"},
+        {"tr", "break-inside: avoid"},
+        {"h2", "break-after: avoid"},
+        {"pre", "white-space: pre-wrap"},
+        {"#linear .code", "overflow: visible"},
+    }
+    for _, name := range themeNames() {
+        theme = name
+        rules := mediaRules(themeStylesheet(), "print")
+        for _, c := range cases {
+            if !strings.Contains(rules[c.selector], c.declaration+";") {
+                t.Errorf("theme %s: printed, %s doesn't have %s; it has %q", name, c.selector, c.declaration, rules[c.selector])
+            }
+        }
+    }
+}
+
+// The print rules name what the template really puts on a page.
+func TestPrintRulesMatchPage(t *testing.T) {
+    dir := writeFiles(t, map[string]string{"a.go": "// # A\n\n// Docs.\npackage p\n\n// More.\nvar x = 1\n"})
+    stdout, stderr, code := runGolit(t, dir, "--remote-css", "a.go")
+    if code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    for _, markup := range []string{`<a class="skip-link"`, `<a class="pilcrow"`, `<button class="copy"`, `<div id="background">`} {
+        if !strings.Contains(stdout, markup) {
+            t.Errorf("page has no %s for the print rules to hide:\n%s", markup, stdout)
+        }
+    }
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+    "os/exec"
+    "time"
+)
+
+// Elsewhere we can only kill the tool itself.
+func killGroupOnCancel(cmd *exec.Cmd) {
+    cmd.WaitDelay = time.Second
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package main
+
+import (
+    "os/exec"
+    "syscall"
+    "time"
+)
+
+// Run `cmd` in a process group of its own, and when its context is
+// done kill the whole group, so that a tool which is only a wrapper,
+// like a version manager shim, doesn't leave its real work running.
+func killGroupOnCancel(cmd *exec.Cmd) {
+    cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+    cmd.Cancel = func() error {
+        return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+    }
+    cmd.WaitDelay = time.Second
+}
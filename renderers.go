@@ -0,0 +1,190 @@
+// ### Renderers
+
+// Rendering a segment means turning its docs into HTML and its code
+// into highlighted HTML. Both go through small interfaces, so the
+// segment loop doesn't care how it's done, and the choice is made
+// once up front: `--docs-renderer` picks goldmark, a `markdown`
+// program, or plain text, and `--code-renderer` picks chroma,
+// Pygments, or plain text. Naming a program with `--markdown-bin` or
+// `--pygmentize-bin` implies the matching renderer. When a program we
+// were asked for can't be found we say what's missing and how to
+// install it, and carry on with the built-in renderer, unless
+// `--require-external` says that's not good enough. Anything else
+// that implements the interfaces can be assigned to `docRenderer` or
+// `codeRenderer` instead.
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "html"
+    "os"
+    "runtime"
+    "strings"
+)
+
+// Turns the Markdown of a segment's docs into HTML.
+type DocRenderer interface {
+    RenderDocs(src string) (string, error)
+}
+
+// Turns a segment's code into highlighted HTML, given the name of
+// the lexer to use.
+type CodeRenderer interface {
+    RenderCode(lang, src string) (string, error)
+}
+
+// The renderers every segment goes through.
+var docRenderer DocRenderer = goldmarkRenderer{}
+var codeRenderer CodeRenderer = chromaRenderer{}
+
+var docsRendererName, codeRendererName string
+
+var requireExternal bool
+
+func init() {
+    flag.BoolVar(&requireExternal, "require-external", false, "fail, instead of using a built-in renderer, when a requested markdown or pygmentize program is missing")
+    flag.StringVar(&docsRendererName, "docs-renderer", "", "render docs with `name`: goldmark, markdown, or plain (default goldmark, or markdown given --markdown-bin)")
+    flag.StringVar(&codeRendererName, "code-renderer", "", "highlight code with `name`: chroma, pygments, or plain (default chroma, or pygments given --pygmentize-bin)")
+}
+
+// Set up the renderers the flags ask for, finding any programs they
+// need. The error says how to exit.
+func chooseRenderers() error {
+    markdownChosen := markdownBin != "" || os.Getenv("GOLIT_MARKDOWN") != ""
+    name := docsRendererName
+    if name == "" && markdownChosen {
+        name = "markdown"
+    }
+    switch name {
+    case "", "goldmark":
+        docRenderer = goldmarkRenderer{}
+    case "markdown":
+        path, err := findTool("markdown", "markdown-bin", markdownBin, "GOLIT_MARKDOWN")
+        if err != nil {
+            if err := missingTool("markdown", err, "rendering docs with goldmark"); err != nil {
+                return envError(err)
+            }
+            docRenderer = goldmarkRenderer{}
+            break
+        }
+        markdownPath = path
+        docRenderer = markdownCommand(path)
+    case "plain":
+        docRenderer = plainRenderer{}
+    default:
+        return usageError(fmt.Errorf("--docs-renderer: unknown renderer %q", name))
+    }
+
+    pygmentizeChosen := pygmentizeBin != "" || os.Getenv("GOLIT_PYGMENTIZE") != ""
+    name = codeRendererName
+    if name == "" && pygmentizeChosen {
+        name = "pygments"
+    }
+    switch name {
+    case "", "chroma":
+        codeRenderer = chromaRenderer{}
+    case "pygments":
+        path, err := findTool("pygmentize", "pygmentize-bin", pygmentizeBin, "GOLIT_PYGMENTIZE")
+        if err != nil {
+            if err := missingTool("pygmentize", err, "highlighting code with chroma"); err != nil {
+                return envError(err)
+            }
+            codeRenderer = chromaRenderer{}
+            break
+        }
+        pygmentizePath = path
+        codeRenderer = startHighlighter(path, pygmentizeChosen)
+    case "plain":
+        codeRenderer = plainRenderer{}
+    default:
+        return usageError(fmt.Errorf("--code-renderer: unknown renderer %q", name))
+    }
+    return nil
+}
+
+// Report that the program `name` couldn't be found because of `err`,
+// how to get it, and, unless we're to insist on it, the `fallback`
+// we'll use instead.
+func missingTool(name string, err error, fallback string) error {
+    msg := fmt.Sprintf("%s isn't available (%v); %s", name, err, installHint(name))
+    if requireExternal {
+        return fmt.Errorf("%s", msg)
+    }
+    return warn("%s. For now, %s instead.", msg, fallback)
+}
+
+// How to install one of the programs, on this OS.
+func installHint(name string) string {
+    hints := map[string]map[string]string{
+        "markdown": {
+            "darwin":  "brew install markdown",
+            "linux":   "sudo apt-get install markdown, or your distribution's equivalent",
+            "windows": "choco install markdown",
+        },
+        "pygmentize": {
+            "darwin":  "brew install pygments",
+            "linux":   "sudo apt-get install python3-pygments, or pip install Pygments",
+            "windows": "pip install Pygments",
+        },
+    }
+    if hint, ok := hints[name][runtime.GOOS]; ok {
+        return "install it with " + hint
+    }
+    if name == "pygmentize" {
+        return "install it with pip install Pygments"
+    }
+    return "install a markdown program and put it on the PATH"
+}
+
+// What to call a renderer when it fails.
+func rendererName(r interface{}) string {
+    switch r.(type) {
+    case markdownCommand:
+        return "markdown"
+    case pygmentizeCommand, *highlighter:
+        return "pygmentize"
+    case goldmarkRenderer:
+        return "goldmark"
+    case chromaRenderer:
+        return "chroma"
+    case goDocRenderer:
+        return "go/doc/comment"
+    }
+    return "rendering"
+}
+
+// Renders docs by running a `markdown` program.
+type markdownCommand string
+
+func (bin markdownCommand) RenderDocs(src string) (string, error) {
+    return pipe(string(bin), []string{}, src)
+}
+
+// Highlights code by running `pygmentize` afresh each time.
+type pygmentizeCommand string
+
+func (bin pygmentizeCommand) RenderCode(lang, src string) (string, error) {
+    return pipe(string(bin), []string{"-l", lang, "-f", "html"}, src)
+}
+
+// Renders docs and code as just escaped text: docs a paragraph per
+// blank-line-separated block, and code preformatted in the same
+// wrapper the highlighters use.
+type plainRenderer struct{}
+
+func (plainRenderer) RenderDocs(src string) (string, error) {
+    out := ""
+    for _, para := range strings.Split(src, "\n\n") {
+        if para = strings.TrimSpace(para); para != "" {
+            out += "<p>" + html.EscapeString(para) + "</p>\n"
+        }
+    }
+    return out, nil
+}
+
+func (plainRenderer) RenderCode(lang, src string) (string, error) {
+    src = strings.Trim(src, "\n") + "\n"
+    return `<div class="highlight"><pre>` + html.EscapeString(src) + "</pre></div>\n", nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "sync"
+    "testing"
+)
+
+// Renderers that wrap what they're given, so tests can see how
+// segments went through them without any tools installed.
+type fakeRenderer struct {
+    sync.Mutex
+    calls int
+    fail  error
+}
+
+func (f *fakeRenderer) RenderDocs(src string) (string, error) {
+    f.Lock()
+    defer f.Unlock()
+    f.calls++
+    return fmt.Sprintf("[docs %q]", src), f.fail
+}
+
+func (f *fakeRenderer) RenderCode(lang, src string) (string, error) {
+    f.Lock()
+    defer f.Unlock()
+    f.calls++
+    return fmt.Sprintf("[%s %q]", lang, src), f.fail
+}
+
+// Use `docs` and `code` for rendering until the test is over.
+func useRenderers(t *testing.T, docs DocRenderer, code CodeRenderer) {
+    savedDocs, savedCode := docRenderer, codeRenderer
+    docRenderer, codeRenderer = docs, code
+    t.Cleanup(func() { docRenderer, codeRenderer = savedDocs, savedCode })
+}
+
+// Segment and render `src` as the Go file p.go, returning each
+// segment's rendered docs and code.
+func renderFake(t *testing.T, src string) ([][2]string, error) {
+    segs, err := fileSegments("p.go", []byte(src))
+    if err != nil {
+        t.Fatal(err)
+    }
+    got := [][2]string{}
+    err = renderSegments(segs, "p.go", "", func(seg *seg) error {
+        got = append(got, [2]string{seg.docsRendered, seg.codeRendered})
+        return nil
+    })
+    return got, err
+}
+
+func TestRenderSegments(t *testing.T) {
+    fake := &fakeRenderer{}
+    useRenderers(t, fake, fake)
+    got, err := renderFake(t, "// Package p.\npackage p\n\n// ## Funcs\n\n// F does nothing.\n// Really.\nfunc F() {}\n\nvar x = 1\n")
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Lines are joined onto what came before, so a segment's docs
+    // and code can start with a newline; the renderers drop it.
+    want := [][2]string{
+        {`[docs "\nPackage p."]`, `[go "\npackage p\n  "]`},
+        {`[docs "## Funcs\n"]`, `[go "  "]`},
+        {`[docs "F does nothing.\nReally."]`, `[go "\nfunc F() {}\n\nvar x = 1\n  "]`},
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("rendered\n%q\nwant\n%q", got, want)
+    }
+}
+
+// A renderer's failure stops the page and says where it happened.
+func TestRenderSegmentsError(t *testing.T) {
+    fake := &fakeRenderer{fail: errors.New("boom")}
+    useRenderers(t, goldmarkRenderer{}, fake)
+    _, err := renderFake(t, "// Package p.\npackage p\n")
+    if err == nil || err.Error() != "p.go:1: rendering failed: boom" {
+        t.Errorf("error = %v", err)
+    }
+}
+
+func TestChooseRenderers(t *testing.T) {
+    useRenderers(t, docRenderer, codeRenderer)
+    defer func(docs, code string) { docsRendererName, codeRendererName = docs, code }(docsRendererName, codeRendererName)
+    cases := []struct {
+        docs, code string
+        want       []interface{}
+    }{
+        {"", "", []interface{}{goldmarkRenderer{}, chromaRenderer{}}},
+        {"goldmark", "chroma", []interface{}{goldmarkRenderer{}, chromaRenderer{}}},
+        {"plain", "plain", []interface{}{plainRenderer{}, plainRenderer{}}},
+    }
+    for _, c := range cases {
+        docsRendererName, codeRendererName = c.docs, c.code
+        if err := chooseRenderers(); err != nil {
+            t.Fatal(err)
+        }
+        if got := []interface{}{docRenderer, codeRenderer}; !reflect.DeepEqual(got, c.want) {
+            t.Errorf("--docs-renderer %q --code-renderer %q chose %T and %T", c.docs, c.code, got[0], got[1])
+        }
+    }
+    docsRendererName, codeRendererName = "", "nonesuch"
+    if err := chooseRenderers(); err == nil {
+        t.Error("chose an unknown code renderer")
+    }
+}
+
+// Code that's markup, or looks like the end of the page's table, is
+// shown as text.
+const hostileCode = "x := `</td></tr></table><script>alert(\"&\")</script><!--`\n"
+
+func TestPlainRendererEscapes(t *testing.T) {
+    got, err := plainRenderer{}.RenderCode("go", "\n"+hostileCode+"\n")
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := `<div class="highlight"><pre>x := ` + "`" + `&lt;/td&gt;&lt;/tr&gt;&lt;/table&gt;&lt;script&gt;alert(&#34;&amp;&#34;)&lt;/script&gt;&lt;!--` + "`" + "\n</pre></div>\n"
+    if got != want {
+        t.Errorf("got  %q\nwant %q", got, want)
+    }
+}
+
+// Chroma shows code it has no lexer for as plain text.
+func TestChromaNoLexer(t *testing.T) {
+    // As though it had been warned about, to keep the warning out of
+    // the test's output.
+    noLexers.warned["no-such-lexer"] = true
+    got, err := chromaRenderer{}.RenderCode("no-such-lexer", hostileCode)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want, _ := (plainRenderer{}).RenderCode("", hostileCode); got != want {
+        t.Errorf("got  %q\nwant %q", got, want)
+    }
+}
+
+// Without the pygmentize asked for,
+// golit says so once and still writes pages, with the code escaped.
+func TestHostileCodeFallback(t *testing.T) {
+    dir := writeFiles(t, map[string]string{
+        "a.go": "// Hostile.\n" + hostileCode,
+        "b.go": "// Hostile too.\n" + hostileCode,
+    })
+    for _, args := range [][]string{
+        {"--pygmentize-bin", filepath.Join(dir, "no-such-pygmentize"), "--out-dir", "out", "a.go", "b.go"},
+        {"--code-renderer", "plain", "--out-dir", "out", "a.go", "b.go"},
+    } {
+        _, stderr, code := runGolit(t, dir, args...)
+        if code != 0 {
+            t.Fatalf("%q: exit %d: %s", args, code, stderr)
+        }
+        if args[0] != "--code-renderer" && strings.Count(stderr, "golit: warning:") != 1 {
+            t.Errorf("%q: want one warning, got %q", args, stderr)
+        }
+        page, err := ioutil.ReadFile(filepath.Join(dir, "out", strings.TrimSuffix(args[len(args)-1], filepath.Ext(args[len(args)-1]))+".html"))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if strings.Contains(string(page), "<script>alert") || strings.Contains(string(page), "`</td>") || !strings.Contains(string(page), "&lt;/td&gt;") {
+            t.Errorf("%q: code isn't escaped", args)
+        }
+    }
+}
@@ -0,0 +1,193 @@
+// ### Source links
+
+// Pages can link back to the files they came from in the project's
+// repository. `--repo-url` is where files are browsed there, and each
+// file's path from the module root goes on the end, so any host that
+// lays its URLs out that way works:
+//
+//     https://github.com/me/proj/blob/main/
+//     https://gitlab.com/me/proj/-/blob/main/
+//     https://git.sr.ht/~me/proj/tree/main/item/
+//     https://bitbucket.org/me/proj/src/main/
+//
+// Every page gets a link to its source, and with `--line-numbers`
+// each segment's permalink is joined by one to the line it starts on,
+// as `#L132`, or on Bitbucket `#lines-132`. On GitHub, GitLab and
+// Bitbucket, pages also link to the file in the host's editor, so a
+// typo in the docs is one click from being fixed.
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+)
+
+var repoURL string
+var branch string
+
+func init() {
+    flag.StringVar(&repoURL, "repo-url", "", "link pages to their sources under `url`, like https://github.com/me/proj/blob/main/")
+    flag.StringVar(&branch, "branch", "", "the `branch` for edit links under --repo-url; the one checked out by default")
+}
+
+// Check that `--repo-url` is a URL that paths can go on the end of.
+func checkRepoURL() error {
+    if repoURL == "" {
+        return nil
+    }
+    u, err := url.Parse(repoURL)
+    if err != nil || u.Scheme == "" || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+        return fmt.Errorf("--repo-url %q isn't a URL to browse files under, like https://github.com/me/proj/blob/main/", repoURL)
+    }
+    return nil
+}
+
+// The URL of `sourcePath` in the repository, or "" without a
+// `--repo-url` or for a file outside the module.
+func sourceURL(sourcePath string) string {
+    path := repoPath(sourcePath)
+    if repoURL == "" || path == "" {
+        return ""
+    }
+    return strings.TrimSuffix(repoURL, "/") + "/" + path
+}
+
+// Where in `u`'s path Bitbucket's `/src/` starts, or -1 if `u` isn't
+// a Bitbucket URL for browsing files.
+func bitbucketSrc(u *url.URL) int {
+    if !strings.Contains(u.Host, "bitbucket") {
+        return -1
+    }
+    return strings.Index(u.Path, "/src/")
+}
+
+// The fragment linking to line `n` of a file on `--repo-url`'s host.
+func lineFragment(n int) string {
+    if u, err := url.Parse(repoURL); err == nil && bitbucketSrc(u) >= 0 {
+        return fmt.Sprintf("#lines-%d", n)
+    }
+    return fmt.Sprintf("#L%d", n)
+}
+
+// The path of `sourcePath` in the repository, escaped for a URL. Paths
+// are taken from the module root, wherever golit is run from and
+// however the file was named, falling back to the working directory
+// for files outside any module.
+func repoPath(sourcePath string) string {
+    if sourcePath == "-" {
+        return ""
+    }
+    abs, err := filepath.Abs(sourcePath)
+    if err != nil {
+        return ""
+    }
+    root := moduleRoot
+    if root == "" {
+        root = findModuleRoot(filepath.Dir(abs))
+    }
+    if root == "" {
+        if root, err = os.Getwd(); err != nil {
+            return ""
+        }
+    }
+    rel, err := filepath.Rel(root, abs)
+    if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return ""
+    }
+    return escapePath(filepath.ToSlash(rel))
+}
+
+func escapePath(path string) string {
+    parts := strings.Split(path, "/")
+    for i, part := range parts {
+        parts[i] = url.PathEscape(part)
+    }
+    return strings.Join(parts, "/")
+}
+
+// Where `sourcePath` is edited on `--repo-url`'s host, if it has an
+// editor we know: `.../blob/ref/` on GitHub is edited at
+// `.../edit/branch/`, `.../-/blob/ref/` on GitLab at
+// `.../-/edit/branch/`, and Bitbucket's `.../src/ref/path` at
+// `.../src/branch/path?mode=edit&at=branch`. Edits go on `--branch`,
+// or else the branch checked out, or else the ref in `--repo-url`.
+func editURL(sourcePath string) string {
+    path := repoPath(sourcePath)
+    if repoURL == "" || path == "" {
+        return ""
+    }
+    u, err := url.Parse(repoURL)
+    if err != nil {
+        return ""
+    }
+    if i := bitbucketSrc(u); i >= 0 {
+        ref := strings.SplitN(u.Path[i+len("/src/"):], "/", 2)[0]
+        if b := editBranch(sourcePath); b != "" {
+            ref = b
+        }
+        if ref == "" {
+            return ""
+        }
+        u.Path, u.RawPath = u.Path[:i]+"/src/"+ref+"/", ""
+        return u.String() + path + "?mode=edit&at=" + ref
+    }
+    for _, files := range []string{"/-/blob/", "/blob/"} {
+        i := strings.Index(u.Path, files)
+        if i < 0 {
+            continue
+        }
+        ref := strings.SplitN(u.Path[i+len(files):], "/", 2)[0]
+        if b := editBranch(sourcePath); b != "" {
+            ref = b
+        }
+        if ref == "" {
+            return ""
+        }
+        u.Path = u.Path[:i] + strings.Replace(files, "blob", "edit", 1) + ref + "/"
+        u.RawPath = ""
+        return u.String() + path
+    }
+    return ""
+}
+
+// The branch checked out where `sourcePath` is, looked up once, unless
+// `--branch` names one.
+var checkedOut struct {
+    sync.Once
+    branch string
+}
+
+func editBranch(sourcePath string) string {
+    if branch != "" {
+        return escapePath(branch)
+    }
+    checkedOut.Do(func() {
+        abs, err := filepath.Abs(sourcePath)
+        if err != nil {
+            return
+        }
+        out, err := pipe("git", []string{"-C", filepath.Dir(abs), "rev-parse", "--abbrev-ref", "HEAD"}, "")
+        if err != nil {
+            verbosef("can't tell which branch to edit on: %v", err)
+            return
+        }
+        if b := strings.TrimSpace(out); b != "HEAD" {
+            checkedOut.branch = escapePath(b)
+        }
+    })
+    return checkedOut.branch
+}
+
+// Link `seg` to the line it starts on in the file at `fileURL`, when
+// lines are numbered.
+func addSourceLink(seg *seg, fileURL string) {
+    if fileURL != "" && lineNumbers && !docsOnly && seg.sourceURL == "" {
+        seg.sourceURL = fileURL + lineFragment(seg.line)
+    }
+}
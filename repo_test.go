@@ -0,0 +1,55 @@
+package main
+
+import (
+    "path/filepath"
+    "testing"
+)
+
+// Each forge's file, line, edit and commit URLs, from its `--repo-url`.
+func TestForgeURLs(t *testing.T) {
+    defer func(r, b, m string, n bool) { repoURL, branch, moduleRoot, lineNumbers = r, b, m, n }(repoURL, branch, moduleRoot, lineNumbers)
+    moduleRoot = writeFiles(t, map[string]string{"go.mod": "module example.com/m\n", "sub/a b.go": "package sub\n"})
+    branch, lineNumbers = "dev", true
+    cases := []struct {
+        repo, source, line, edit, commit string
+    }{
+        {
+            "https://github.com/me/proj/blob/main/",
+            "https://github.com/me/proj/blob/main/sub/a%20b.go",
+            "https://github.com/me/proj/blob/main/sub/a%20b.go#L7",
+            "https://github.com/me/proj/edit/dev/sub/a%20b.go",
+            "https://github.com/me/proj/commit/abc1234",
+        },
+        {
+            "https://gitlab.com/me/proj/-/blob/main",
+            "https://gitlab.com/me/proj/-/blob/main/sub/a%20b.go",
+            "https://gitlab.com/me/proj/-/blob/main/sub/a%20b.go#L7",
+            "https://gitlab.com/me/proj/-/edit/dev/sub/a%20b.go",
+            "https://gitlab.com/me/proj/-/commit/abc1234",
+        },
+        {
+            "https://bitbucket.org/me/proj/src/main/",
+            "https://bitbucket.org/me/proj/src/main/sub/a%20b.go",
+            "https://bitbucket.org/me/proj/src/main/sub/a%20b.go#lines-7",
+            "https://bitbucket.org/me/proj/src/dev/sub/a%20b.go?mode=edit&at=dev",
+            "https://bitbucket.org/me/proj/commits/abc1234",
+        },
+        {
+            "https://git.sr.ht/~me/proj/tree/main/item/",
+            "https://git.sr.ht/~me/proj/tree/main/item/sub/a%20b.go",
+            "https://git.sr.ht/~me/proj/tree/main/item/sub/a%20b.go#L7",
+            "",
+            "https://git.sr.ht/~me/proj/commit/abc1234",
+        },
+    }
+    path := filepath.Join(moduleRoot, "sub", "a b.go")
+    for _, c := range cases {
+        repoURL = c.repo
+        s := &seg{line: 7}
+        addSourceLink(s, sourceURL(path))
+        got := [4]string{sourceURL(path), s.sourceURL, editURL(path), commitURL("abc1234")}
+        if want := [4]string{c.source, c.line, c.edit, c.commit}; got != want {
+            t.Errorf("with --repo-url %s, source, line, edit and commit URLs =\n%q, want\n%q", c.repo, got, want)
+        }
+    }
+}
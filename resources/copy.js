@@ -0,0 +1,18 @@
+// Show the copy buttons, where there's a clipboard to copy to, and
+// copy a segment's source when its button is clicked.
+(function() {
+  if (!navigator.clipboard) return;
+  var buttons = document.querySelectorAll('button.copy');
+  for (var i = 0; i < buttons.length; i++) {
+    buttons[i].hidden = false;
+    buttons[i].addEventListener('click', function(e) {
+      var button = e.currentTarget;
+      navigator.clipboard.writeText(button.getAttribute('data-code')).then(function() {
+        button.textContent = 'copied';
+      }, function() {
+        button.textContent = 'failed';
+      });
+      setTimeout(function() { button.textContent = 'copy'; }, 1500);
+    });
+  }
+})();
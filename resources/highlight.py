@@ -0,0 +1,43 @@
+# Highlight code for golit, one request after another, so that a build
+# starts Python and Pygments once instead of once per segment.
+#
+# Each request is a line with the lexer name, a line with the length of
+# the code in bytes, then the code. Each reply is a line with a status,
+# "ok" or "error", and a length, then that many bytes of HTML or error
+# message. We start by replying "ready", so golit knows Pygments loaded.
+
+import sys
+
+from pygments import highlight
+from pygments.formatters import HtmlFormatter
+from pygments.lexers import get_lexer_by_name
+from pygments.util import guess_decode
+
+
+def reply(status, data):
+    out = sys.stdout.buffer
+    out.write(b"%s %d\n" % (status, len(data)))
+    out.write(data)
+    out.flush()
+
+
+def main():
+    inp = sys.stdin.buffer
+    reply(b"ready", b"")
+    while True:
+        lang = inp.readline()
+        if not lang:
+            return
+        size = int(inp.readline())
+        # Decode as pygmentize does: UTF-8 if we can, Latin-1 if not.
+        code, _ = guess_decode(inp.read(size))
+        try:
+            lexer = get_lexer_by_name(lang.decode().strip())
+            html = highlight(code, lexer, HtmlFormatter())
+        except Exception as err:
+            reply(b"error", str(err).encode("utf-8"))
+            continue
+        reply(b"ok", html.encode("utf-8"))
+
+
+main()
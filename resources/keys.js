@@ -0,0 +1,76 @@
+// Move between segments with j and k, and between those with headers
+// with n and p, keeping the hash on the one we're at, and go to the
+// table of contents with t. Segments are the elements with the ids
+// golit gives them, less any headings whose ids look the same, so
+// with none there's nothing to do.
+(function(prefix) {
+  var segments = [];
+  var all = document.querySelectorAll('[id^="' + prefix + '"]');
+  for (var i = 0; i < all.length; i++) {
+    if (!/^H[1-6]$/.test(all[i].tagName)) segments.push(all[i]);
+  }
+  if (!segments.length) return;
+
+  function isHeader(segment) {
+    return !!segment.querySelector('h1, h2, h3, h4, h5, h6');
+  }
+
+  // The segment we're at: the one with the focus, or the one the hash
+  // names, or else the first one still on screen.
+  function current() {
+    for (var i = 0; i < segments.length; i++) {
+      if (segments[i].contains(document.activeElement)) return i;
+    }
+    for (i = 0; i < segments.length; i++) {
+      if ('#' + segments[i].id === location.hash) return i;
+    }
+    for (i = 0; i < segments.length; i++) {
+      if (segments[i].getBoundingClientRect().bottom > 0) return i;
+    }
+    return -1;
+  }
+
+  function go(segment) {
+    history.replaceState(null, '', '#' + segment.id);
+    segment.tabIndex = -1;
+    segment.focus();
+    segment.scrollIntoView();
+  }
+
+  // The next segment from `from` that `want` accepts, going `step`s.
+  function find(from, step, want) {
+    for (var i = from + step; i >= 0 && i < segments.length; i += step) {
+      if (want(segments[i])) return segments[i];
+    }
+    return null;
+  }
+
+  function any() { return true; }
+
+  document.addEventListener('keydown', function(e) {
+    if (e.ctrlKey || e.metaKey || e.altKey || e.defaultPrevented) return;
+    var target = e.target;
+    if (target.isContentEditable || /^(INPUT|TEXTAREA|SELECT)$/.test(target.tagName)) return;
+    var at = current(), to = null;
+    switch (e.key) {
+    case 'j': to = find(at, 1, any); break;
+    case 'k': to = find(at, -1, any); break;
+    case 'n': to = find(at, 1, isHeader); break;
+    case 'p': to = find(at, -1, isHeader); break;
+    case 't':
+      var toc = document.querySelector('.toc a');
+      if (toc) {
+        toc.focus();
+        toc.scrollIntoView();
+        e.preventDefault();
+      }
+      return;
+    default:
+      return;
+    }
+    if (to) {
+      go(to);
+      e.preventDefault();
+    }
+  });
+})(ANCHOR_PREFIX);
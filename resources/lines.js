@@ -0,0 +1,49 @@
+// Highlight the lines of a range like #L132-L140, as :target does
+// for a single line, and make one by shift-clicking a line number.
+// Either way, open any fold the lines are in.
+(function() {
+  var range = /^#(.*)L(\d+)-L(\d+)$/;
+  var single = /^#(.*)L(\d+)$/;
+
+  function reveal(el) {
+    for (; el; el = el.parentElement) {
+      if (el.tagName === 'DETAILS') el.open = true;
+    }
+  }
+
+  function select() {
+    var target = single.exec(location.hash);
+    var line = target && document.getElementById(target[1] + 'L' + target[2]);
+    if (line) {
+      reveal(line);
+      line.scrollIntoView();
+    }
+    var selected = document.querySelectorAll('.line.selected');
+    for (var i = 0; i < selected.length; i++) {
+      selected[i].classList.remove('selected');
+    }
+    var m = range.exec(location.hash);
+    if (!m) return;
+    var from = Math.min(+m[2], +m[3]), to = Math.max(+m[2], +m[3]);
+    for (var n = from; n <= to; n++) {
+      line = document.getElementById(m[1] + 'L' + n);
+      if (line) line.classList.add('selected');
+      reveal(line);
+    }
+    var first = document.getElementById(m[1] + 'L' + from);
+    if (first) first.scrollIntoView();
+  }
+
+  document.addEventListener('click', function(e) {
+    var link = e.target.closest && e.target.closest('.linenos a');
+    if (!link || !e.shiftKey) return;
+    var from = single.exec(location.hash) || range.exec(location.hash);
+    var to = single.exec(link.getAttribute('href'));
+    if (!from || !to || from[1] !== to[1]) return;
+    e.preventDefault();
+    location.hash = to[1] + 'L' + from[2] + '-L' + to[2];
+  });
+
+  window.addEventListener('hashchange', select);
+  select();
+})();
@@ -0,0 +1,12 @@
+// Typeset the page's math with KaTeX, leaving the TeX as it is where
+// it can't be.
+(function() {
+  if (!window.katex) return;
+  var spans = document.querySelectorAll('.math');
+  for (var i = 0; i < spans.length; i++) {
+    katex.render(spans[i].textContent, spans[i], {
+      displayMode: spans[i].classList.contains('display'),
+      throwOnError: false
+    });
+  }
+})();
@@ -0,0 +1,9 @@
+// Draw the page's diagrams, in mermaid's dark theme when the page is
+// dark.
+(function() {
+  if (!window.mermaid) return;
+  var body = document.body.classList;
+  var dark = body.contains('theme-dark') ||
+    (body.contains('theme-auto') && window.matchMedia && window.matchMedia('(prefers-color-scheme: dark)').matches);
+  mermaid.initialize({startOnLoad: true, theme: dark ? 'dark' : 'default'});
+})();
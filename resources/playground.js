@@ -0,0 +1,27 @@
+// Show the playground buttons, where there's fetch to share with, and
+// share a segment's program when its button is clicked, opening it in
+// the playground once it's shared. The window is opened straight
+// away, while the click still counts, so it isn't blocked.
+(function() {
+  if (!window.fetch) return;
+  var buttons = document.querySelectorAll('button.playground');
+  for (var i = 0; i < buttons.length; i++) {
+    buttons[i].hidden = false;
+    buttons[i].addEventListener('click', function(e) {
+      var button = e.currentTarget;
+      var win = window.open('', '_blank');
+      button.textContent = 'sharing';
+      fetch(SHARE_URL, {method: 'POST', body: button.getAttribute('data-code')}).then(function(resp) {
+        if (!resp.ok) throw new Error(resp.statusText);
+        return resp.text();
+      }).then(function(id) {
+        win.location = 'https://go.dev/play/p/' + id.trim();
+        button.textContent = 'Run in Playground';
+      }, function() {
+        win.close();
+        button.textContent = 'failed';
+        setTimeout(function() { button.textContent = 'Run in Playground'; }, 1500);
+      });
+    });
+  }
+})();
@@ -0,0 +1,81 @@
+// Search the build from the search box, fetching the index the first
+// time it's used. A segment matches when every word searched for
+// starts one of its terms, and those whose header matches come first.
+(function() {
+  var box = document.querySelector('.search');
+  if (!box || !window.fetch) return;
+  box.hidden = false;
+  var input = box.querySelector('input');
+  var results = box.querySelector('.search-results');
+  var root = box.getAttribute('data-root');
+  var index = null, loading = null;
+
+  function load() {
+    if (!loading) {
+      loading = fetch(box.getAttribute('data-index')).then(function(r) {
+        return r.json();
+      }).then(function(data) {
+        index = data;
+      }, function() {
+        results.textContent = 'Search is unavailable.';
+      });
+    }
+    return loading;
+  }
+
+  // `text` with each of `words` marked where it starts a word.
+  function highlight(el, text, words) {
+    var pattern = new RegExp('(^|[^\\p{L}\\p{N}_])(' + words.map(function(w) {
+      return w.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+    }).join('|') + ')', 'giu');
+    var last = 0, m;
+    while ((m = pattern.exec(text))) {
+      var start = m.index + m[1].length;
+      el.appendChild(document.createTextNode(text.slice(last, start)));
+      var mark = document.createElement('mark');
+      mark.textContent = m[2];
+      el.appendChild(mark);
+      last = start + m[2].length;
+    }
+    el.appendChild(document.createTextNode(text.slice(last)));
+  }
+
+  function matches(terms, words) {
+    return words.every(function(word) {
+      return terms.some(function(term) { return term.indexOf(word) === 0; });
+    });
+  }
+
+  function show() {
+    results.textContent = '';
+    var words = input.value.toLowerCase().split(/[^\p{L}\p{N}_]+/u).filter(Boolean);
+    if (!index || !words.length) return;
+    var found = [];
+    index.segments.forEach(function(s) {
+      if (!matches(s.terms.split(' '), words)) return;
+      var heading = (s.heading || '').toLowerCase().split(/[^\p{L}\p{N}_]+/u);
+      found.push({segment: s, rank: matches(heading, words) ? 0 : 1});
+    });
+    found.sort(function(a, b) { return a.rank - b.rank; });
+    found.slice(0, 20).forEach(function(f) {
+      var s = f.segment, page = index.pages[s.page];
+      var li = document.createElement('li');
+      var a = document.createElement('a');
+      a.href = root + page.url + '#' + s.anchor;
+      a.textContent = page.title + (s.heading ? ' › ' + s.heading : '');
+      li.appendChild(a);
+      if (s.text) {
+        var p = document.createElement('p');
+        highlight(p, s.text, words);
+        li.appendChild(p);
+      }
+      results.appendChild(li);
+    });
+    if (!found.length) results.textContent = 'Nothing found.';
+  }
+
+  input.addEventListener('focus', load);
+  input.addEventListener('input', function() {
+    load().then(show);
+  });
+})();
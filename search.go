@@ -0,0 +1,184 @@
+// ### Search
+
+// With `--search`, a multi-file build also writes `search-index.json`,
+// listing each segment of each page with the header it comes under
+// and the start of its docs as plain text, and a search box goes in
+// the sidebar, or on the index without one. The index is only fetched
+// once someone starts searching. To keep it small, a segment's docs
+// are cut short for showing, and what's searched is the set of words
+// in them and names in the code, less common words and Go's keywords.
+// A single page has nothing to search across, so gets none of this.
+
+package main
+
+import (
+    _ "embed"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "go/token"
+    "html"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "unicode/utf8"
+)
+
+var search bool
+
+func init() {
+    flag.BoolVar(&search, "search", false, "write search-index.json and add a search box to the pages of a multi-file build")
+}
+
+//go:embed resources/search.js
+var searchScript string
+
+// Where the index goes, from the top of the output.
+const searchIndexName = "search-index.json"
+
+// How much of a segment's docs is kept for showing in results.
+const searchSnippetLength = 200
+
+// Whether this build has search.
+func searching() bool {
+    return search && outDir != "" && !singlePage && !noJS
+}
+
+// The index as written: the pages, and the segments, which refer to
+// them by their place in `Pages`.
+type searchIndex struct {
+    Pages    []searchPage    `json:"pages"`
+    Segments []searchSegment `json:"segments"`
+}
+
+type searchPage struct {
+    URL   string `json:"url"`
+    Title string `json:"title"`
+}
+
+// One segment of a page: its anchor, the header it's under, the
+// start of its docs, and the words it's found by, separated by
+// spaces.
+type searchSegment struct {
+    Page    int    `json:"page"`
+    Anchor  string `json:"anchor"`
+    Heading string `json:"heading,omitempty"`
+    Text    string `json:"text,omitempty"`
+    Terms   string `json:"terms"`
+}
+
+// Gathers the segments of a page for the index as they're rendered,
+// remembering the last header seen for those that have none.
+type searchCollector struct {
+    heading  string
+    segments []searchSegment
+}
+
+func (c *searchCollector) add(seg *seg) {
+    if !searching() || seg.license {
+        return
+    }
+    if len(seg.headings) > 0 {
+        h := seg.headings[0]
+        c.heading = strings.TrimSpace(h.number + " " + plainHeading(h.text))
+    }
+    text := strings.Join(strings.Fields(html.UnescapeString(inlineTagPat.ReplaceAllString(seg.docsRendered, " "))), " ")
+    terms := searchTerms(text, seg.code)
+    if terms == "" {
+        return
+    }
+    c.segments = append(c.segments, searchSegment{Anchor: seg.anchor, Heading: c.heading, Text: snippet(text), Terms: terms})
+}
+
+// Words from the docs, and names from the code, leaving out the
+// short, common and repeated.
+var wordPat = regexp.MustCompile(`[\pL\pN_]+`)
+
+var stopwords = map[string]bool{}
+
+func init() {
+    for _, word := range strings.Fields(`a about an and are as at be but by can do for from has have
+        if in into is it its it's not of on or so than that the their then there these they
+        this to was we were when which will with you your`) {
+        stopwords[word] = true
+    }
+}
+
+func searchTerms(docs, code string) string {
+    seen := map[string]bool{}
+    terms := []string{}
+    addWords := func(text string, keep func(string) bool) {
+        for _, word := range wordPat.FindAllString(text, -1) {
+            if !keep(word) {
+                continue
+            }
+            word = strings.ToLower(word)
+            if len(word) < 2 || stopwords[word] || seen[word] {
+                continue
+            }
+            seen[word] = true
+            terms = append(terms, word)
+        }
+    }
+    addWords(docs, func(string) bool { return true })
+    addWords(code, func(word string) bool {
+        return token.IsIdentifier(word) && !token.IsKeyword(word)
+    })
+    return strings.Join(terms, " ")
+}
+
+// The start of `text`, cut at a word.
+func snippet(text string) string {
+    if len(text) <= searchSnippetLength {
+        return text
+    }
+    cut := strings.LastIndex(text[:searchSnippetLength], " ")
+    if cut < 0 {
+        for cut = searchSnippetLength; !utf8.RuneStart(text[cut]); cut-- {
+        }
+    }
+    return text[:cut] + "…"
+}
+
+// Write `search-index.json` for the pages we built.
+func writeSearchIndex(pages []pageInfo) error {
+    index := searchIndex{Pages: []searchPage{}, Segments: []searchSegment{}}
+    for _, page := range pages {
+        rel, err := filepath.Rel(outDir, page.output)
+        if err != nil {
+            return err
+        }
+        n := len(index.Pages)
+        index.Pages = append(index.Pages, searchPage{URL: filepath.ToSlash(rel), Title: page.title})
+        for _, s := range page.search {
+            s.Page = n
+            index.Segments = append(index.Segments, s)
+        }
+    }
+    data, err := json.Marshal(index)
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(filepath.Join(outDir, searchIndexName), append(data, '\n'))
+}
+
+// The search box for the page at `current`, hidden until the script
+// shows it.
+func searchBox(current string) string {
+    if !searching() {
+        return ""
+    }
+    index := relURL(current, searchIndexName)
+    root := strings.TrimSuffix(index, searchIndexName)
+    return fmt.Sprintf("<div class=\"search\" role=\"search\" data-index=\"%s\" data-root=\"%s\" hidden>"+
+        "<input type=\"search\" placeholder=\"Search\" aria-label=\"Search the docs\">"+
+        "<ol class=\"search-results\" aria-live=\"polite\"></ol></div>\n", html.EscapeString(index), html.EscapeString(root))
+}
+
+// The `<script>` for the search box.
+func searchScripts() string {
+    if !searching() {
+        return ""
+    }
+    return "<script>\n" + searchScript + "</script>\n"
+}
@@ -0,0 +1,205 @@
+// ### Serving
+
+// Browsers are fussy about `file://` pages, so `--serve :8080` builds
+// the site and serves it over HTTP. Without an `--out-dir` we build
+// into a temporary directory that's removed when we stop. A request
+// for a page that isn't there gets a 404 listing the pages that are.
+// Combine it with `--watch` to keep the site up to date while you
+// edit; open pages then reload themselves after each rebuild.
+//
+// The reloading works by adding a small script to pages as we serve
+// them, never to the files on disk, which listens for server-sent
+// events from golit. If the connection drops, say because golit was
+// restarted, the script keeps trying with growing delays and reloads
+// once it's back.
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "html"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// The address to serve on, like `:8080`, and the interface to bind to
+// when it doesn't name one.
+var serveAddr string
+
+var bindHost = "localhost"
+
+func init() {
+    flag.StringVar(&serveAddr, "serve", "", "serve the built site over HTTP at `addr`, like :8080")
+    flag.StringVar(&bindHost, "bind", bindHost, "`host` to listen on with --serve")
+}
+
+// Start serving the files in `outDir` at `serveAddr`, in the
+// background, once we know the address is ours.
+func serve() error {
+    host, port, err := net.SplitHostPort(serveAddr)
+    if err != nil {
+        return fmt.Errorf("--serve: %v", err)
+    }
+    if host == "" {
+        host = bindHost
+    }
+    listener, err := net.Listen("tcp", net.JoinHostPort(host, port))
+    if err != nil {
+        return err
+    }
+    fmt.Fprintf(os.Stderr, "golit: serving %s at http://%s/\n", outDir, listener.Addr())
+    go http.Serve(listener, siteHandler(outDir))
+    return nil
+}
+
+// Serve the files under `root`, with a friendlier 404 than usual.
+func siteHandler(root string) http.Handler {
+    files := http.FileServer(http.Dir(root))
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Cache-Control", "no-cache")
+        if r.URL.Path == reloadPath && watching {
+            streamReloads(w, r)
+            return
+        }
+        path := filepath.Join(root, filepath.FromSlash(filepath.Clean("/"+r.URL.Path)))
+        if info, err := os.Stat(path); err == nil && info.IsDir() {
+            path = filepath.Join(path, "index.html")
+        }
+        if _, err := os.Stat(path); os.IsNotExist(err) {
+            notFound(w, r, root)
+            return
+        }
+        if strings.HasSuffix(path, ".html") && watching {
+            page, err := ioutil.ReadFile(path)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusInternalServerError)
+                return
+            }
+            w.Header().Set("Content-Type", "text/html; charset=utf-8")
+            w.Write(injectReload(page))
+            return
+        }
+        if strings.HasSuffix(path, ".html") {
+            w.Header().Set("Content-Type", "text/html; charset=utf-8")
+        }
+        files.ServeHTTP(w, r)
+    })
+}
+
+// Where pages listen for reloads.
+var reloadPath = "/_golit/reload"
+
+var reloadScript = `<script>
+(function () {
+  var delay = 500, broken = false;
+  function connect() {
+    var events = new EventSource("` + reloadPath + `");
+    events.onopen = function () {
+      if (broken) location.reload();
+      delay = 500;
+    };
+    events.onmessage = function () { location.reload(); };
+    events.onerror = function () {
+      events.close();
+      broken = true;
+      setTimeout(connect, delay);
+      delay = Math.min(delay * 2, 10000);
+    };
+  }
+  connect();
+})();
+</script>
+`
+
+// Add the reload script to a page, just before `</body>` if it has
+// one.
+func injectReload(page []byte) []byte {
+    text := string(page)
+    if i := strings.LastIndex(text, "</body>"); i >= 0 {
+        return []byte(text[:i] + reloadScript + text[i:])
+    }
+    return []byte(text + reloadScript)
+}
+
+// The pages currently listening for reloads, each with a channel we
+// poke after a rebuild.
+var listeners = struct {
+    sync.Mutex
+    chans map[chan bool]bool
+}{chans: map[chan bool]bool{}}
+
+// Tell every open page to reload.
+func broadcastReload() {
+    listeners.Lock()
+    defer listeners.Unlock()
+    for ch := range listeners.chans {
+        select {
+        case ch <- true:
+        default:
+        }
+    }
+}
+
+// Hold a page's event stream open, sending an event on each reload
+// and a comment now and then so proxies don't give up on us.
+func streamReloads(w http.ResponseWriter, r *http.Request) {
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+        return
+    }
+    ch := make(chan bool, 1)
+    listeners.Lock()
+    listeners.chans[ch] = true
+    listeners.Unlock()
+    defer func() {
+        listeners.Lock()
+        delete(listeners.chans, ch)
+        listeners.Unlock()
+    }()
+
+    w.Header().Set("Content-Type", "text/event-stream")
+    fmt.Fprint(w, ": connected\n\n")
+    flusher.Flush()
+    ping := time.NewTicker(30 * time.Second)
+    defer ping.Stop()
+    for {
+        select {
+        case <-ch:
+            fmt.Fprint(w, "data: reload\n\n")
+        case <-ping.C:
+            fmt.Fprint(w, ": ping\n\n")
+        case <-r.Context().Done():
+            return
+        }
+        flusher.Flush()
+    }
+}
+
+func notFound(w http.ResponseWriter, r *http.Request, root string) {
+    pages := []string{}
+    filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+        if err == nil && info.Mode().IsRegular() && strings.HasSuffix(path, ".html") {
+            if rel, err := filepath.Rel(root, path); err == nil {
+                pages = append(pages, filepath.ToSlash(rel))
+            }
+        }
+        return nil
+    })
+    sort.Strings(pages)
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.WriteHeader(http.StatusNotFound)
+    fmt.Fprintf(w, "<!DOCTYPE html>\n<title>Not found</title>\n<h1>No page at %s</h1>\n<p>These pages are available:</p>\n<ul>\n", html.EscapeString(r.URL.Path))
+    for _, page := range pages {
+        fmt.Fprintf(w, "<li><a href=\"/%s\">%s</a></li>\n", html.EscapeString(page), html.EscapeString(page))
+    }
+    fmt.Fprint(w, "</ul>\n")
+}
@@ -0,0 +1,83 @@
+// ### Sitemap
+
+// Given `--base-url`, the address the output will be published at, a
+// multi-file build also writes a `sitemap.xml` for search engines,
+// listing every page with its absolute URL and when its source last
+// changed. A sitemap with relative URLs would be useless, so without
+// a base URL there's none.
+
+package main
+
+import (
+    "bytes"
+    "encoding/xml"
+    "flag"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+var baseURL string
+
+func init() {
+    flag.StringVar(&baseURL, "base-url", "", "`URL` the output will be published at, for sitemap.xml")
+}
+
+type sitemapURL struct {
+    Loc     string `xml:"loc"`
+    LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemap struct {
+    XMLName xml.Name     `xml:"urlset"`
+    Xmlns   string       `xml:"xmlns,attr"`
+    URLs    []sitemapURL `xml:"url"`
+}
+
+// Write `sitemap.xml` for the pages we built. The index, if there is
+// one, is dated by the newest of them.
+func writeSitemap(pages []pageInfo) error {
+    base, err := url.Parse(baseURL)
+    if err != nil {
+        return err
+    }
+    if !strings.HasSuffix(base.Path, "/") {
+        base.Path += "/"
+    }
+    entries := []sitemapURL{}
+    newest := time.Time{}
+    for _, page := range pages {
+        rel, err := filepath.Rel(outDir, page.output)
+        if err != nil {
+            return err
+        }
+        entry := sitemapURL{Loc: base.ResolveReference(&url.URL{Path: filepath.ToSlash(rel)}).String()}
+        if info, err := os.Stat(page.source); err == nil {
+            entry.LastMod = info.ModTime().UTC().Format("2006-01-02")
+            if info.ModTime().After(newest) {
+                newest = info.ModTime()
+            }
+        }
+        entries = append(entries, entry)
+    }
+    if indexPages[filepath.Join(outDir, "index.html")] {
+        index := sitemapURL{Loc: base.ResolveReference(&url.URL{Path: "index.html"}).String()}
+        if !newest.IsZero() {
+            index.LastMod = newest.UTC().Format("2006-01-02")
+        }
+        entries = append([]sitemapURL{index}, entries...)
+    }
+
+    var out bytes.Buffer
+    out.WriteString(xml.Header)
+    encoder := xml.NewEncoder(&out)
+    encoder.Indent("", "  ")
+    err = encoder.Encode(sitemap{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9", URLs: entries})
+    if err != nil {
+        return err
+    }
+    out.WriteString("\n")
+    return writeFileAtomic(filepath.Join(outDir, "sitemap.xml"), out.Bytes())
+}
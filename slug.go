@@ -0,0 +1,121 @@
+// ### Heading anchors
+
+// Markdown renderers, run a segment at a time, don't give headings
+// ids, so there'd be nothing to link to. We give every heading of a
+// page an id made from its text, as GitHub does: lower-cased, with
+// spaces as hyphens and other punctuation gone, and a number added to
+// repeats, as in `usage`, `usage-1`. Header comments get theirs when
+// the file is segmented, from the header text rather than what's
+// rendered, so numbering and Markdown don't get into them and the
+// table of contents can link to them before anything's rendered. Any
+// other headings in the docs are given ids as they're written out.
+
+package main
+
+import (
+    "crypto/sha256"
+    "flag"
+    "fmt"
+    "html"
+    "regexp"
+    "strings"
+    "unicode"
+)
+
+var stableAnchors bool
+
+func init() {
+    flag.BoolVar(&stableAnchors, "stable-anchors", false, "make segment anchors from their contents, so links to them survive edits elsewhere in the file")
+}
+
+// Make `text` into an id.
+func Slugify(text string) string {
+    var out strings.Builder
+    dash := false
+    for _, r := range strings.ToLower(text) {
+        switch {
+        case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+            if dash && out.Len() > 0 {
+                out.WriteByte('-')
+            }
+            out.WriteRune(r)
+            dash = false
+        case unicode.IsSpace(r) || r == '-':
+            dash = true
+        }
+    }
+    return out.String()
+}
+
+// The ids used so far on a page.
+type slugSet map[string]bool
+
+// Give `segs`, all the segments of a page, their anchors, and return
+// the ids of the page with those taken. Anchors number the segments,
+// unless `--stable-anchors` asks for them to be made from a hash of
+// each segment's docs and code, so that a segment keeps its anchor
+// however much changes around it. Segments that are the same are
+// told apart by a counter.
+func segmentAnchors(segs []*seg) slugSet {
+    used := slugSet{}
+    for i, seg := range segs {
+        base := sectionAnchor(i + 1)
+        if stableAnchors {
+            sum := sha256.Sum256([]byte(strings.TrimSpace(seg.docs) + "\x00" + strings.TrimSpace(seg.code)))
+            base = fmt.Sprintf("%s%x", anchorPrefix, sum[:4])
+        }
+        seg.anchor = base
+        for n := 2; used[seg.anchor]; n++ {
+            seg.anchor = fmt.Sprintf("%s-%d", base, n)
+        }
+        used[seg.anchor] = true
+    }
+    return used
+}
+
+// An id for `text` that isn't used yet on the page.
+func (used slugSet) unique(text string) string {
+    base := Slugify(text)
+    if base == "" {
+        base = "heading"
+    }
+    slug := base
+    for i := 1; used[slug]; i++ {
+        slug = fmt.Sprintf("%s-%d", base, i)
+    }
+    used[slug] = true
+    return slug
+}
+
+// Give the header comments of `segs` their ids.
+func headingIDs(segs []*seg, used slugSet) {
+    for _, seg := range segs {
+        for i := range seg.headings {
+            seg.headings[i].id = used.unique(plainHeading(seg.headings[i].text))
+        }
+    }
+}
+
+var headingTagPat = regexp.MustCompile(`(?s)<h([1-6])(\s[^>]*)?>(.*?)</h[1-6]>`)
+
+// Add ids to the headings in the rendered docs of `seg`. Those of its
+// header comments get theirs, in order, and any others, like
+// underlined Markdown headings, get ones made from their text.
+func addHeadingIDs(seg *seg, used slugSet) {
+    next := 0
+    seg.docsRendered = headingTagPat.ReplaceAllStringFunc(seg.docsRendered, func(tag string) string {
+        match := headingTagPat.FindStringSubmatch(tag)
+        if strings.Contains(match[2], "id=") {
+            return tag
+        }
+        text := Slugify(html.UnescapeString(inlineTagPat.ReplaceAllString(match[3], "")))
+        var id string
+        if next < len(seg.headings) && text == Slugify(seg.headings[next].number+" "+plainHeading(seg.headings[next].text)) {
+            id = seg.headings[next].id
+            next++
+        } else {
+            id = used.unique(text)
+        }
+        return fmt.Sprintf(`<h%s id="%s"%s>%s</h%s>`, match[1], html.EscapeString(id), match[2], match[3], match[1])
+    })
+}
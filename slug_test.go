@@ -0,0 +1,99 @@
+package main
+
+import (
+    "reflect"
+    "regexp"
+    "testing"
+)
+
+func TestSlugify(t *testing.T) {
+    cases := []struct {
+        in, want string
+    }{
+        {"Getting Started", "getting-started"},
+        {"  What's new?  ", "whats-new"},
+        {"Step 1 -- setup", "step-1-setup"},
+        {"snake_case stays", "snake_case-stays"},
+        {"Überblick & Änderungen", "überblick-änderungen"},
+        {"日本語の見出し", "日本語の見出し"},
+        {"Emoji 🚀 launch", "emoji-launch"},
+        {"!!!", ""},
+    }
+    for _, c := range cases {
+        if got := Slugify(c.in); got != c.want {
+            t.Errorf("Slugify(%q) = %q, want %q", c.in, got, c.want)
+        }
+    }
+}
+
+// Header comments are slugged as they read, with their code spans
+// kept and the rest of their Markdown gone, and repeats numbered.
+func TestHeadingIDs(t *testing.T) {
+    segs := []*seg{{headings: []heading{
+        {level: 1, text: "Using `Open(path)`"},
+        {level: 2, text: "*Usage*"},
+        {level: 2, text: "Usage"},
+        {level: 2, text: "[Usage](#x)"},
+        {level: 2, text: "`!`"},
+        {level: 2, text: "`!`"},
+    }}}
+    headingIDs(segs, slugSet{})
+    got := []string{}
+    for _, h := range segs[0].headings {
+        got = append(got, h.id)
+    }
+    want := []string{"using-openpath", "usage", "usage-1", "usage-2", "heading", "heading-1"}
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("ids = %q, want %q", got, want)
+    }
+}
+
+// Rendered headings that aren't header comments get ids that don't
+// collide with those that are.
+func TestAddHeadingIDs(t *testing.T) {
+    used := slugSet{}
+    s := &seg{headings: []heading{{level: 2, text: "Setup"}}}
+    headingIDs([]*seg{s}, used)
+    s.docsRendered = "<h2>Setup</h2>\n<h3>Setup</h3>\n<h3 id=\"kept\">Kept</h3>"
+    addHeadingIDs(s, used)
+    if want := "<h2 id=\"setup\">Setup</h2>\n<h3 id=\"setup-1\">Setup</h3>\n<h3 id=\"kept\">Kept</h3>"; s.docsRendered != want {
+        t.Errorf("docs = %q, want %q", s.docsRendered, want)
+    }
+}
+
+var segmentIDPat = regexp.MustCompile(`<tr id="(section-[^"]*)"`)
+
+// With `--stable-anchors`, a segment inserted near the top of a file
+// leaves the anchors of the others as they were, where numbered ones
+// below it would all shift.
+func TestStableAnchors(t *testing.T) {
+    head, body := "// Opens.\npackage p\n\n", "// Then x.\nvar x = 1\n\n// The same.\nvar y = 1\n\n// The same.\nvar y = 1\n"
+    inserted := head + "// Inserted.\nconst z = 0\n\n" + body
+    ids := func(src string, args ...string) []string {
+        t.Helper()
+        dir := writeFiles(t, map[string]string{"a.go": src})
+        stdout, stderr, code := runGolit(t, dir, append(args, "a.go")...)
+        if code != 0 {
+            t.Fatalf("exit %d: %s", code, stderr)
+        }
+        got := []string{}
+        for _, match := range segmentIDPat.FindAllStringSubmatch(stdout, -1) {
+            got = append(got, match[1])
+        }
+        return got
+    }
+    before := ids(head+body, "--stable-anchors")
+    after := ids(inserted, "--stable-anchors")
+    if len(before) != 4 || len(after) != 5 {
+        t.Fatalf("anchors before %q and after %q the insertion", before, after)
+    }
+    if kept := append(after[:1:1], after[2:]...); !reflect.DeepEqual(kept, before) {
+        t.Errorf("anchors around the inserted one = %q, want %q", kept, before)
+    }
+    if before[3] != before[2]+"-2" {
+        t.Errorf("repeated segment's anchor = %q, want %q", before[3], before[2]+"-2")
+    }
+    if numbered := ids(inserted); numbered[2] != "section-3" {
+        t.Errorf("without --stable-anchors, anchors = %q, want them numbered", numbered)
+    }
+}
@@ -0,0 +1,90 @@
+// ### Highlight styles
+
+// Pygments and chroma both come with dozens of color styles, and
+// `--highlight-style` colors code with one of them in place of the
+// theme's palette. The highlighted markup is the same whatever the
+// style, since it's all classes, so what the style changes is the
+// CSS: from `pygmentize -S` when Pygments is highlighting, or made
+// from chroma's registry of styles otherwise. It's inlined after the
+// theme and scoped to `.highlight`, so it colors only the code. An
+// unknown style is reported before anything is rendered.
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/alecthomas/chroma/v2"
+    chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+    "github.com/alecthomas/chroma/v2/styles"
+)
+
+var highlightStyle string
+
+func init() {
+    flag.StringVar(&highlightStyle, "highlight-style", "", "color code with the Pygments or chroma style `name`, like monokai, instead of the theme's colors")
+}
+
+// The CSS for `--highlight-style`, once loaded.
+var highlightCSS string
+
+// Its rules are all under this, which is specific enough to win over
+// themes' palettes, dark ones included.
+const highlightScope = "body .highlight"
+
+// Make the CSS for `--highlight-style` with whichever highlighter is
+// rendering code.
+func loadHighlightStyle() error {
+    if highlightStyle == "" {
+        return nil
+    }
+    switch codeRenderer.(type) {
+    case pygmentizeCommand, *highlighter:
+        out, err := pipe(pygmentizePath, []string{"-S", highlightStyle, "-f", "html", "-a", highlightScope}, "")
+        if err != nil {
+            return fmt.Errorf("--highlight-style %q: %v", highlightStyle, err)
+        }
+        // Pygments adds a few rules for line numbers outside the
+        // scope, which we leave out.
+        rules := []string{}
+        for _, line := range strings.Split(out, "\n") {
+            if strings.HasPrefix(line, highlightScope) {
+                rules = append(rules, line)
+            }
+        }
+        highlightCSS = strings.Join(rules, "\n") + "\n"
+    default:
+        style, ok := styles.Registry[strings.ToLower(highlightStyle)]
+        if !ok {
+            names := styles.Names()
+            sort.Strings(names)
+            return fmt.Errorf("unknown --highlight-style %q; use one of %s", highlightStyle, strings.Join(names, ", "))
+        }
+        highlightCSS = chromaStyleCSS(style)
+    }
+    return nil
+}
+
+// CSS for a chroma style, in the form Pygments writes it, with its
+// short class names.
+func chromaStyleCSS(style *chroma.Style) string {
+    var out strings.Builder
+    if bg := chromahtml.StyleEntryToCSS(style.Get(chroma.Background)); bg != "" {
+        fmt.Fprintf(&out, "%s { %s }\n", highlightScope, bg)
+    }
+    types := style.Types()
+    sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+    for _, t := range types {
+        class, ok := chroma.StandardTypes[t]
+        if !ok || class == "" || t == chroma.Background {
+            continue
+        }
+        if css := chromahtml.StyleEntryToCSS(style.Get(t)); css != "" {
+            fmt.Fprintf(&out, "%s .%s { %s } /* %s */\n", highlightScope, class, css, t)
+        }
+    }
+    return out.String()
+}
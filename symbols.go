@@ -0,0 +1,162 @@
+// ### Symbols
+
+// A page of Go gets a menu of the file's top-level declarations, its
+// funcs, methods, types, consts and vars, in the order they're
+// declared, each linking to the segment it's in. Methods are shown
+// as `Type.Method`. Unexported names are listed too, but toned down,
+// and `--symbols exported` leaves them out while `none` leaves out
+// the menu. Deprecated declarations are tagged. A file that doesn't
+// parse has no menu, since the page itself doesn't need it to.
+
+package main
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "go/ast"
+    "go/parser"
+    "go/token"
+    "html"
+    "path/filepath"
+)
+
+var symbols = "all"
+
+func init() {
+    flag.StringVar(&symbols, "symbols", symbols, "list `all` of a Go file's top-level declarations in a menu, only the exported ones, or none")
+}
+
+func checkSymbols() error {
+    switch symbols {
+    case "all", "exported", "none":
+        return nil
+    }
+    return fmt.Errorf("unknown --symbols %q; use all, exported or none", symbols)
+}
+
+// A top-level declaration and the line it's on. For the API
+// appendix, it has the declaration itself, just the one spec of a
+// `GenDecl`, and its doc comment. The names of a spec like
+// `var a, b int` share it.
+type symbol struct {
+    kind, name string
+    exported   bool
+    line       int
+    decl       ast.Node
+    doc        *ast.CommentGroup
+}
+
+// The top-level declarations of the Go source `src`, with the file
+// set their positions are in, or none if it doesn't parse.
+func fileSymbols(src []byte) ([]symbol, *token.FileSet) {
+    fset := token.NewFileSet()
+    file, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+    if err != nil {
+        return nil, fset
+    }
+    found := []symbol{}
+    add := func(kind, name string, pos token.Pos, exported bool, decl ast.Node, doc *ast.CommentGroup) {
+        if name != "_" {
+            found = append(found, symbol{kind, name, exported, fset.Position(pos).Line, decl, doc})
+        }
+    }
+    for _, decl := range file.Decls {
+        switch decl := decl.(type) {
+        case *ast.FuncDecl:
+            if decl.Recv == nil || len(decl.Recv.List) == 0 {
+                add("func", decl.Name.Name, decl.Name.Pos(), decl.Name.IsExported(), decl, decl.Doc)
+                continue
+            }
+            recv := receiverName(decl.Recv.List[0].Type)
+            add("method", recv+"."+decl.Name.Name, decl.Name.Pos(), decl.Name.IsExported() && ast.IsExported(recv), decl, decl.Doc)
+        case *ast.GenDecl:
+            for _, spec := range decl.Specs {
+                one := &ast.GenDecl{Tok: decl.Tok, Specs: []ast.Spec{spec}}
+                switch spec := spec.(type) {
+                case *ast.TypeSpec:
+                    add("type", spec.Name.Name, spec.Name.Pos(), spec.Name.IsExported(), one, specDoc(decl, spec.Doc))
+                case *ast.ValueSpec:
+                    for _, name := range spec.Names {
+                        add(decl.Tok.String(), name.Name, name.Pos(), name.IsExported(), one, specDoc(decl, spec.Doc))
+                    }
+                }
+            }
+        }
+    }
+    return found, fset
+}
+
+// The doc comment for a spec of `decl`: its own, or, when it's the
+// only one, written without parentheses, the declaration's.
+func specDoc(decl *ast.GenDecl, doc *ast.CommentGroup) *ast.CommentGroup {
+    if doc == nil && !decl.Lparen.IsValid() {
+        return decl.Doc
+    }
+    return doc
+}
+
+// The name of a method's receiver type, without any `*` or type
+// parameters.
+func receiverName(expr ast.Expr) string {
+    for {
+        switch e := expr.(type) {
+        case *ast.StarExpr:
+            expr = e.X
+        case *ast.ParenExpr:
+            expr = e.X
+        case *ast.IndexExpr:
+            expr = e.X
+        case *ast.IndexListExpr:
+            expr = e.X
+        case *ast.Ident:
+            return e.Name
+        default:
+            return "?"
+        }
+    }
+}
+
+// The last of `segs` starting at or before `line`.
+func segmentAt(segs []*seg, line int) *seg {
+    var in *seg
+    for _, seg := range segs {
+        if seg.line > line {
+            break
+        }
+        in = seg
+    }
+    return in
+}
+
+// Draw the symbols menu for the Go file at `sourcePath`, linking each
+// declaration to the segment it's in. It's "" for other files, and
+// for a file with no declarations to list.
+func renderSymbols(sourcePath string, src []byte, segs []*seg) string {
+    if symbols == "none" || docsOnly || filepath.Ext(sourcePath) != ".go" {
+        return ""
+    }
+    var out bytes.Buffer
+    found, _ := fileSymbols(src)
+    for _, sym := range found {
+        if symbols == "exported" && !sym.exported {
+            continue
+        }
+        in := segmentAt(segs, sym.line)
+        if in == nil {
+            continue
+        }
+        class, badge := sym.kind, ""
+        if !sym.exported {
+            class += " unexported"
+        }
+        if isDeprecated(sym.doc) {
+            class, badge = class+" deprecated", " "+deprecatedBadge
+        }
+        fmt.Fprintf(&out, "<li class=\"%s\"><a href=\"#%s\"><code>%s</code></a>%s</li>\n", class, html.EscapeString(in.anchor), html.EscapeString(sym.name), badge)
+    }
+    if out.Len() == 0 {
+        return ""
+    }
+    return "<nav class=\"symbols\" aria-label=\"Symbols\"><details>\n<summary>Symbols</summary>\n<ul>\n" + out.String() + "</ul>\n</details></nav>\n"
+}
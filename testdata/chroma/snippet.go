@@ -0,0 +1,26 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// A type, a method and a func, with most kinds of token.
+type greeter struct {
+    name  string
+    times int
+}
+
+func (g *greeter) greet() string {
+    return strings.Repeat("hello, "+g.name+"!\n", g.times)
+}
+
+func main() {
+    g := &greeter{name: `<world> & "friends"`, times: 2}
+    for i := 0; i < 3; i++ {
+        fmt.Printf("%d: %s", i, g.greet()) /* block comment */
+    }
+    var x float64 = 1.5e3
+    _ = 'c' + rune(0x41)
+    _ = x
+}
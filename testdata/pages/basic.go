@@ -0,0 +1,23 @@
+// # Basic
+//
+// A small literate program, with a header, docs beside code, and code
+// with no docs.
+
+package main
+
+import "fmt"
+
+// ## Greeting
+
+// `greet` says hello to `name`, **loudly** if asked to. See
+// [fmt](https://pkg.go.dev/fmt) for the verbs.
+func greet(name string, loud bool) string {
+    if loud {
+        return fmt.Sprintf("HELLO, %s!", name)
+    }
+    return fmt.Sprintf("hello, %s", name)
+}
+
+func main() {
+    fmt.Println(greet("world", false))
+}
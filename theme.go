@@ -0,0 +1,101 @@
+// ### Themes
+
+// The built-in stylesheet is the layout, from docco, with a theme on
+// top of it for colors, type and a matching highlighting palette.
+// Themes are embedded from `resources/themes`, each a directory with a
+// `theme.css` whose first comment describes it, and chosen with
+// `--theme`; `--list-themes` lists them. Any `--css` goes after the
+// theme, so it can override it.
+//
+// A theme's `dark.css` is its dark palette, used when the reader's
+// system asks for dark mode. `--theme-mode` can force pages light or
+// dark instead, by the class it gives each page's `<body>`:
+// `theme-light`, `theme-dark`, or `theme-auto` to follow the reader.
+// The palette is written once, for `theme-dark`, and repeated for
+// `theme-auto` inside a `prefers-color-scheme` query.
+
+package main
+
+import (
+    "embed"
+    "flag"
+    "fmt"
+    "io"
+    "io/fs"
+    "path"
+    "regexp"
+    "strings"
+)
+
+var theme = "docco"
+var listThemes bool
+var themeMode = "auto"
+
+func init() {
+    flag.StringVar(&theme, "theme", theme, "style pages with the built-in theme `name`; see --list-themes")
+    flag.BoolVar(&listThemes, "list-themes", false, "list the built-in themes and exit")
+    flag.StringVar(&themeMode, "theme-mode", themeMode, "show pages light, dark, or auto to follow the reader's preference")
+}
+
+//go:embed resources/themes
+var themeFS embed.FS
+
+// The names of the built-in themes, in order.
+func themeNames() []string {
+    entries, _ := fs.ReadDir(themeFS, "resources/themes")
+    names := []string{}
+    for _, entry := range entries {
+        if entry.IsDir() {
+            names = append(names, entry.Name())
+        }
+    }
+    return names
+}
+
+// One of a theme's stylesheets, or "" if it doesn't have it.
+func themeFile(name, file string) string {
+    data, err := fs.ReadFile(themeFS, path.Join("resources/themes", name, file))
+    if err != nil {
+        return ""
+    }
+    return string(data)
+}
+
+func checkTheme() error {
+    if themeFile(theme, "theme.css") == "" {
+        return fmt.Errorf("unknown --theme %q; use one of %s", theme, strings.Join(themeNames(), ", "))
+    }
+    switch themeMode {
+    case "light", "dark", "auto":
+        return nil
+    }
+    return fmt.Errorf("unknown --theme-mode %q; use light, dark or auto", themeMode)
+}
+
+var themeDescriptionPat = regexp.MustCompile(`^/\*\s*(.*?)\s*\*/`)
+
+// Write the themes and what they're like, for `--list-themes`.
+func writeThemes(w io.Writer) {
+    for _, name := range themeNames() {
+        description := ""
+        if match := themeDescriptionPat.FindStringSubmatch(themeFile(name, "theme.css")); match != nil {
+            description = match[1]
+        }
+        fmt.Fprintf(w, "%-14s %s\n", name, description)
+    }
+}
+
+// The built-in stylesheet with `--theme` on top of it.
+func themeStylesheet() string {
+    return doccoCSS + themeFile(theme, "theme.css") + darkStylesheet(themeFile(theme, "dark.css"))
+}
+
+// A dark palette, both forced and as the reader prefers.
+func darkStylesheet(dark string) string {
+    if dark == "" {
+        return ""
+    }
+    auto := strings.Replace(strings.TrimRight(dark, "\n"), "body.theme-dark", "body.theme-auto", -1)
+    return dark + "@media (prefers-color-scheme: dark) {\n" +
+        "  " + strings.Replace(auto, "\n", "\n  ", -1) + "\n}\n"
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+// Each theme's full stylesheet, dark palette and all, and the list of
+// them, as golden files.
+func TestThemeGoldens(t *testing.T) {
+    defer func(saved string) { theme = saved }(theme)
+    for _, name := range themeNames() {
+        theme = name
+        golden(t, "testdata/themes/"+name+".css", themeStylesheet())
+    }
+    var list bytes.Buffer
+    writeThemes(&list)
+    golden(t, "testdata/themes/list.txt", list.String())
+}
+
+// A dark palette only applies to dark pages, so every one of its
+// selectors is under `body.theme-dark`, and each comes out again
+// under `body.theme-auto` for readers who prefer dark.
+func TestDarkPalettesScoped(t *testing.T) {
+    for _, name := range themeNames() {
+        dark := themeFile(name, "dark.css")
+        if dark == "" {
+            t.Errorf("theme %s has no dark.css", name)
+            continue
+        }
+        selectors := ""
+        for _, line := range strings.Split(dark, "\n") {
+            if line = strings.TrimSpace(line); strings.HasSuffix(line, "{") || strings.HasSuffix(line, ",") {
+                selectors += strings.TrimSuffix(line, "{")
+            }
+        }
+        for _, selector := range strings.Split(selectors, ",") {
+            if selector = strings.TrimSpace(selector); selector != "" && !strings.HasPrefix(selector, "body.theme-dark") {
+                t.Errorf("theme %s: dark selector %q isn't under body.theme-dark", name, selector)
+            }
+        }
+        auto := darkStylesheet(dark)
+        if strings.Count(auto, "body.theme-auto") != strings.Count(dark, "body.theme-dark") || !strings.Contains(auto, "@media (prefers-color-scheme: dark) {") {
+            t.Errorf("theme %s: dark palette isn't repeated for theme-auto:\n%s", name, auto)
+        }
+    }
+}
+
+func TestThemeFlags(t *testing.T) {
+    dir := writeFiles(t, map[string]string{"a.go": "// Docs.\npackage p\n"})
+    stdout, stderr, code := runGolit(t, dir, "--theme", "minimal", "--theme-mode", "dark", "a.go")
+    if code != 0 {
+        t.Fatalf("exit %d: %s", code, stderr)
+    }
+    if !strings.Contains(stdout, "theme-dark\">") || !strings.Contains(stdout, themeFile("minimal", "theme.css")) {
+        t.Errorf("page isn't styled with the minimal theme, forced dark:\n%s", stdout)
+    }
+    for _, args := range [][]string{{"--theme", "nope", "a.go"}, {"--theme-mode", "dim", "a.go"}} {
+        if _, stderr, code := runGolit(t, dir, args...); code != exitUsage || !strings.Contains(stderr, args[1]) {
+            t.Errorf("golit %q: exit %d, stderr %q; want exit %d naming %s", args, code, stderr, exitUsage, args[1])
+        }
+    }
+}
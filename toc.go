@@ -0,0 +1,185 @@
+// ### Table of contents
+
+// The header comments of a file outline it, so we gather them as we
+// segment the file and put a table of contents at the top of its page,
+// nested by header level and linking to each header where it's
+// rendered. A file with fewer than two headers doesn't need one. Links
+// show a header's text without its Markdown, so `` `seg` `` and
+// `[docco](...)` come out as just `seg` and `docco`. `--toc-depth`
+// leaves out the deeper levels, and `--numbered` numbers the headers.
+
+package main
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "html"
+    "regexp"
+    "strings"
+)
+
+// How many levels of headers go in the table of contents, from
+// `--toc-depth`, with 0 meaning all of them. With `--numbered`,
+// headers are numbered.
+var tocDepth int
+var numbered bool
+
+func init() {
+    flag.IntVar(&tocDepth, "toc-depth", 0, "include headers down to `level` in the table of contents, so 2 means # and ##; 0 for all")
+    flag.BoolVar(&numbered, "numbered", false, "number headers, like 1, 1.1 and 2, in the docs and the table of contents")
+}
+
+// A header comment: how many `#` it has, its text, and, with
+// `--numbered`, its number.
+type heading struct {
+    level  int
+    text   string
+    number string
+    // The id of the header where it's rendered.
+    id string
+}
+
+// Read a header comment, already stripped of its comment marker.
+func parseHeading(line string) heading {
+    line = strings.TrimSpace(line)
+    text := strings.TrimLeft(line, "#")
+    return heading{level: len(line) - len(text), text: replaceEmoji(strings.TrimSpace(closingHashesPat.ReplaceAllString(text, "")))}
+}
+
+// Headers may be closed with `#`s too, like `## Usage ##`.
+var closingHashesPat = regexp.MustCompile(`\s+#+\s*$`)
+
+// Inline Markdown to strip from headers: images and links keep their
+// text, code spans their code, and emphasis and HTML tags go.
+var (
+    inlineLinkPat   = regexp.MustCompile(`!?\[([^\]]*)\](\([^)]*\)|\[[^\]]*\])?`)
+    inlineCodePat   = regexp.MustCompile("`+([^`]*)`+")
+    inlineTagPat    = regexp.MustCompile(`<[^>]+>`)
+    inlineEmphPat   = regexp.MustCompile(`\*+|(^|\W)_+|_+(\W|$)`)
+    inlineEscapePat = regexp.MustCompile(`\\([[:punct:]])`)
+)
+
+func plainHeading(text string) string {
+    // Code spans and escaped characters are kept as they are, so set
+    // them aside while the rest is stripped.
+    kept := []string{}
+    keep := func(pat *regexp.Regexp) {
+        text = pat.ReplaceAllStringFunc(text, func(match string) string {
+            kept = append(kept, pat.FindStringSubmatch(match)[1])
+            return fmt.Sprintf("\x00%d\x00", len(kept)-1)
+        })
+    }
+    keep(inlineCodePat)
+    keep(inlineEscapePat)
+    text = inlineLinkPat.ReplaceAllString(text, "$1")
+    text = inlineTagPat.ReplaceAllString(text, "")
+    text = inlineEmphPat.ReplaceAllString(text, "$1$2")
+    for i, k := range kept {
+        text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), k, 1)
+    }
+    return strings.TrimSpace(text)
+}
+
+// An entry in the table of contents and those nested under it.
+type tocEntry struct {
+    *heading
+    children []*tocEntry
+}
+
+// Nest the headings of `segs` under the headers of lower levels
+// before them. Levels can be skipped, so a `###` right after a `#`
+// goes straight under it.
+func outline(segs []*seg) *tocEntry {
+    root := &tocEntry{heading: &heading{}}
+    for _, seg := range segs {
+        for j := range seg.headings {
+            entry := &tocEntry{heading: &seg.headings[j]}
+            parent := root
+            for len(parent.children) > 0 && parent.children[len(parent.children)-1].level < entry.level {
+                parent = parent.children[len(parent.children)-1]
+            }
+            parent.children = append(parent.children, entry)
+        }
+    }
+    return root
+}
+
+// With `--numbered`, number the headings of `segs` by where they are
+// in the outline, like 1, 1.1, 1.2 and 2, both in the segments' docs
+// and for the table of contents. Numbers come from the outline rather
+// than straight from header levels so that the two always agree.
+func numberHeadings(segs []*seg) {
+    if !numbered {
+        return
+    }
+    var number func(entries []*tocEntry, prefix string)
+    number = func(entries []*tocEntry, prefix string) {
+        for i, entry := range entries {
+            entry.number = fmt.Sprintf("%s%d", prefix, i+1)
+            number(entry.children, entry.number+".")
+        }
+    }
+    number(outline(segs).children, "")
+    for _, seg := range segs {
+        if len(seg.headings) == 0 {
+            continue
+        }
+        lines := strings.Split(seg.docs, "\n")
+        next := 0
+        for i, line := range lines {
+            match := headingLinePat.FindStringSubmatchIndex(line)
+            if match == nil || next == len(seg.headings) {
+                continue
+            }
+            lines[i] = line[:match[1]] + seg.headings[next].number + " " + line[match[1]:]
+            next++
+        }
+        seg.docs = strings.Join(lines, "\n")
+    }
+}
+
+// The `#`s opening a header line of docs, and the space after them.
+var headingLinePat = regexp.MustCompile(`^\s*#+\s+`)
+
+// Draw the table of contents for `segs`, down to `--toc-depth`. It's
+// "" if there'd be less than two entries in it.
+func renderTOC(segs []*seg) string {
+    root := outline(segs)
+    var out bytes.Buffer
+    if writeTOCList(&out, root.children) < 2 {
+        return ""
+    }
+    return "<nav class=\"toc\" aria-label=\"Contents\">\n" + out.String() + "</nav>\n"
+}
+
+// Write a list of `entries` and those under them, skipping any deeper
+// than `--toc-depth`, and return how many were written.
+func writeTOCList(out *bytes.Buffer, entries []*tocEntry) int {
+    count := 0
+    for _, entry := range entries {
+        if tocDepth > 0 && entry.level > tocDepth {
+            continue
+        }
+        if count == 0 {
+            fmt.Fprint(out, "<ul>\n")
+        }
+        count++
+        text := html.EscapeString(plainHeading(entry.text))
+        if entry.number != "" {
+            text = entry.number + " " + text
+        }
+        fmt.Fprintf(out, "<li><a href=\"#%s\">%s</a>", html.EscapeString(entry.id), text)
+        var children bytes.Buffer
+        if n := writeTOCList(&children, entry.children); n > 0 {
+            fmt.Fprint(out, "\n")
+            out.Write(children.Bytes())
+            count += n
+        }
+        fmt.Fprint(out, "</li>\n")
+    }
+    if count > 0 {
+        fmt.Fprint(out, "</ul>\n")
+    }
+    return count
+}
@@ -0,0 +1,146 @@
+// ### TODO callouts
+
+// A docs line starting with a marker like `TODO:`, or `TODO(alice):`
+// with who it's for, is a note to the file's maintainers rather than
+// part of its story, so its paragraph is set apart as a callout,
+// labeled with the marker and keeping the name. The markers are
+// `TODO`, `FIXME`, `BUG`, `NOTE` and `HACK` unless `--todo-markers`
+// names others. Before the docs are rendered, each such line is made
+// to start a paragraph of its own, so the renderer can't run it into
+// the prose before it, and afterwards the paragraphs it starts are
+// dressed up. `--todos hide` leaves them out, and `--todos only`
+// leaves out everything else, for a page of just what's outstanding.
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    todos       = "show"
+    todoMarkers = "TODO,FIXME,BUG,NOTE,HACK"
+)
+
+func init() {
+    flag.StringVar(&todos, "todos", todos, "show TODO and other marked paragraphs of docs as callouts, hide them, or show only them")
+    flag.StringVar(&todoMarkers, "todo-markers", todoMarkers, "the `markers`, separated by commas, that start the paragraphs --todos is for")
+}
+
+// Patterns for a docs line starting with a marker, and for what
+// renderers make of one.
+var (
+    todoLinePat *regexp.Regexp
+    todoHTMLPat *regexp.Regexp
+)
+
+var todoMarkerPat = regexp.MustCompile(`^[A-Za-z]\w*$`)
+
+func checkTodos() error {
+    switch todos {
+    case "show", "hide", "only":
+    default:
+        return fmt.Errorf("unknown --todos %q; use show, hide or only", todos)
+    }
+    markers := []string{}
+    for _, marker := range strings.Split(todoMarkers, ",") {
+        if marker = strings.TrimSpace(marker); marker == "" {
+            continue
+        }
+        if !todoMarkerPat.MatchString(marker) {
+            return fmt.Errorf("--todo-markers: %q isn't a word", marker)
+        }
+        markers = append(markers, marker)
+    }
+    if len(markers) == 0 {
+        todoLinePat, todoHTMLPat = nil, nil
+        return nil
+    }
+    alternatives := strings.Join(markers, "|")
+    todoLinePat = regexp.MustCompile(`^\s*(` + alternatives + `)(\([^)]*\))?:`)
+    todoHTMLPat = regexp.MustCompile(`<p>(` + alternatives + `)(\(([^)]*)\))?:\s*`)
+    return nil
+}
+
+var fencePat = regexp.MustCompile("^\\s*(```|~~~)")
+
+// Split the marked paragraphs of `docs` from the rest, making each
+// start a paragraph of its own in `all`. A marked paragraph runs from
+// its marker to the next blank line or marker. Fenced code is passed
+// over.
+func splitTodos(docs string) (all, marked, rest []string) {
+    inFence, inTodo := false, false
+    for _, line := range strings.Split(docs, "\n") {
+        if fencePat.MatchString(line) {
+            inFence = !inFence
+        }
+        switch {
+        case inFence || strings.TrimSpace(line) == "":
+            inTodo = false
+        case todoLinePat.MatchString(line):
+            if len(all) > 0 && strings.TrimSpace(all[len(all)-1]) != "" {
+                all = append(all, "")
+            }
+            if len(marked) > 0 {
+                marked = append(marked, "")
+            }
+            inTodo = true
+        }
+        all = append(all, line)
+        if inTodo {
+            marked = append(marked, line)
+        } else {
+            rest = append(rest, line)
+        }
+    }
+    return all, marked, rest
+}
+
+// Give the marked paragraphs of `segs`' docs their own paragraphs, and
+// with `--todos hide` or `only`, leave out them or the segments
+// without them. A file with no marked paragraphs at all keeps none of
+// its segments but one saying so.
+func segmentTodos(segs []*seg) []*seg {
+    if todoLinePat == nil {
+        return segs
+    }
+    kept := []*seg{}
+    for _, seg := range segs {
+        all, marked, rest := splitTodos(seg.docs)
+        switch todos {
+        case "show":
+            seg.docs = strings.Join(all, "\n")
+        case "hide":
+            seg.docs = strings.Join(rest, "\n")
+        case "only":
+            if len(marked) == 0 {
+                continue
+            }
+            seg.docs, seg.header, seg.headings = strings.Join(marked, "\n"), false, nil
+        }
+        kept = append(kept, seg)
+    }
+    if len(kept) == 0 && len(segs) > 0 {
+        kept = append(kept, &seg{docs: "Nothing here is marked " + strings.Join(strings.Split(todoMarkers, ","), ", ") + ".", lang: segs[0].lang, wide: true, line: 1})
+    }
+    return kept
+}
+
+// Make the paragraphs of the rendered docs `rendered` that start with
+// a marker into callouts.
+func markTodos(rendered string) string {
+    if todoHTMLPat == nil {
+        return rendered
+    }
+    return todoHTMLPat.ReplaceAllStringFunc(rendered, func(match string) string {
+        parts := todoHTMLPat.FindStringSubmatch(match)
+        out := fmt.Sprintf(`<p class="todo todo-%s"><span class="todo-label">%s</span> `, strings.ToLower(parts[1]), parts[1])
+        if parts[2] != "" {
+            out += `<span class="todo-author">(` + parts[3] + ")</span> "
+        }
+        return out
+    })
+}
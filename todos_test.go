@@ -0,0 +1,115 @@
+package main
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// Set `--todos` and `--todo-markers` for the rest of a test.
+func useTodos(t *testing.T, mode, markers string) {
+    saved := []string{todos, todoMarkers}
+    line, rendered := todoLinePat, todoHTMLPat
+    t.Cleanup(func() {
+        todos, todoMarkers = saved[0], saved[1]
+        todoLinePat, todoHTMLPat = line, rendered
+    })
+    todos, todoMarkers = mode, markers
+    if err := checkTodos(); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestSplitTodos(t *testing.T) {
+    useTodos(t, "show", "TODO,FIXME")
+    cases := []struct {
+        docs              string
+        all, marked, rest []string
+    }{
+        {"Intro.\nTODO: fix\nthis.\n\nAfter.",
+            []string{"Intro.", "", "TODO: fix", "this.", "", "After."},
+            []string{"TODO: fix", "this."},
+            []string{"Intro.", "", "After."}},
+        {"TODO(alice): one.\nFIXME: two.",
+            []string{"TODO(alice): one.", "", "FIXME: two."},
+            []string{"TODO(alice): one.", "", "FIXME: two."},
+            nil},
+        {"```\nTODO: in a fence.\n```\nNOTE: not a marker.",
+            []string{"```", "TODO: in a fence.", "```", "NOTE: not a marker."},
+            nil,
+            []string{"```", "TODO: in a fence.", "```", "NOTE: not a marker."}},
+        {"Not a TODO: mid-line.",
+            []string{"Not a TODO: mid-line."}, nil, []string{"Not a TODO: mid-line."}},
+    }
+    for _, c := range cases {
+        all, marked, rest := splitTodos(c.docs)
+        if !reflect.DeepEqual(all, c.all) || !reflect.DeepEqual(marked, c.marked) || !reflect.DeepEqual(rest, c.rest) {
+            t.Errorf("splitTodos(%q) =\n%q\n%q\n%q\nwant\n%q\n%q\n%q", c.docs, all, marked, rest, c.all, c.marked, c.rest)
+        }
+    }
+}
+
+func TestMarkTodos(t *testing.T) {
+    useTodos(t, "show", "TODO,XXX")
+    cases := []struct{ rendered, want string }{
+        {"<p>TODO: fix this.</p>", `<p class="todo todo-todo"><span class="todo-label">TODO</span> fix this.</p>`},
+        {"<p>TODO(alice): fix this.</p>", `<p class="todo todo-todo"><span class="todo-label">TODO</span> <span class="todo-author">(alice)</span> fix this.</p>`},
+        {"<p>XXX: custom.</p>", `<p class="todo todo-xxx"><span class="todo-label">XXX</span> custom.</p>`},
+        {"<p>FIXME: not a marker here.</p>", "<p>FIXME: not a marker here.</p>"},
+        {"<p>Not a TODO: mid-sentence.</p>", "<p>Not a TODO: mid-sentence.</p>"},
+    }
+    for _, c := range cases {
+        if got := markTodos(c.rendered); got != c.want {
+            t.Errorf("markTodos(%q) = %q, want %q", c.rendered, got, c.want)
+        }
+    }
+}
+
+func TestSegmentTodos(t *testing.T) {
+    docs := func(segs []*seg) []string {
+        out := []string{}
+        for _, seg := range segs {
+            out = append(out, seg.docs)
+        }
+        return out
+    }
+    segments := func() []*seg {
+        return []*seg{
+            {docs: "# Title", header: true, line: 1},
+            {docs: "Prose.\nTODO(alice): fix.", line: 2},
+            {docs: "```\nTODO: in a fence.\n```", line: 4},
+        }
+    }
+    cases := []struct {
+        mode, markers string
+        want          []string
+    }{
+        {"show", "TODO", []string{"# Title", "Prose.\n\nTODO(alice): fix.", "```\nTODO: in a fence.\n```"}},
+        {"hide", "TODO", []string{"# Title", "Prose.", "```\nTODO: in a fence.\n```"}},
+        {"only", "TODO", []string{"TODO(alice): fix."}},
+        {"only", "LATER", []string{"Nothing here is marked LATER."}},
+    }
+    for _, c := range cases {
+        useTodos(t, c.mode, c.markers)
+        if got := docs(segmentTodos(segments())); !reflect.DeepEqual(got, c.want) {
+            t.Errorf("--todos %s --todo-markers %s: docs = %q, want %q", c.mode, c.markers, got, c.want)
+        }
+    }
+}
+
+func TestCheckTodos(t *testing.T) {
+    useTodos(t, "show", "TODO")
+    for _, c := range []struct{ mode, markers, err string }{
+        {"maybe", "TODO", `unknown --todos "maybe"; use show, hide or only`},
+        {"show", "TODO,to do", `--todo-markers: "to do" isn't a word`},
+    } {
+        todos, todoMarkers = c.mode, c.markers
+        if err := checkTodos(); err == nil || err.Error() != c.err {
+            t.Errorf("--todos %s --todo-markers %s: error = %v, want %s", c.mode, c.markers, err, c.err)
+        }
+    }
+    todos, todoMarkers = "show", " , "
+    if err := checkTodos(); err != nil || todoLinePat != nil || !strings.Contains(markTodos("<p>TODO: x</p>"), "<p>TODO") {
+        t.Errorf("no markers: %v, %v", err, todoLinePat)
+    }
+}
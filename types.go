@@ -0,0 +1,191 @@
+// ### Type information
+
+// Pages for teaching read better when hovering over a name shows what
+// it is. With `--type-info`, a Go file is type-checked along with the
+// rest of its package, as `go build` would see it, and each name in
+// its code gets a `title` with its type, or a func's signature. Code
+// that's mid-refactor often doesn't check, and then the page goes
+// without, with a warning, rather than failing. Checking is slow, so
+// what it finds is kept in the user's cache directory, keyed by the
+// contents of the package's files.
+
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "go/ast"
+    gobuild "go/build"
+    "go/importer"
+    "go/parser"
+    "go/token"
+    "go/types"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "runtime"
+)
+
+var typeInfo bool
+
+func init() {
+    flag.BoolVar(&typeInfo, "type-info", false, "type-check Go files and show each name's type when it's hovered over; slow")
+}
+
+// A name in the code and what to show for it.
+type typeTitle struct {
+    Line  int    `json:"line"`
+    Col   int    `json:"col"`
+    Name  string `json:"name"`
+    Title string `json:"title"`
+}
+
+// Add the types of the names in the Go file at `sourcePath` to
+// `marks`, warning and leaving them as they were if it doesn't check.
+func addTypeInfo(sourcePath string, src []byte, marks map[identPos]codeMark) (map[identPos]codeMark, error) {
+    if !typeInfo || docsOnly || filepath.Ext(sourcePath) != ".go" {
+        return marks, nil
+    }
+    titles, err := typeTitles(sourcePath, src)
+    if err != nil {
+        return marks, report(Diagnostic{Path: sourcePath, Message: "no type information: " + err.Error()})
+    }
+    if marks == nil {
+        marks = map[identPos]codeMark{}
+    }
+    for _, t := range titles {
+        pos := identPos{t.Line, t.Col}
+        mark := marks[pos]
+        mark.name, mark.title = t.Name, t.Title
+        marks[pos] = mark
+    }
+    return marks, nil
+}
+
+// The names in the Go file at `sourcePath`, whose source is `src`,
+// with their types, from the cache if they're there.
+func typeTitles(sourcePath string, src []byte) ([]typeTitle, error) {
+    sourcePath = filepath.Clean(sourcePath)
+    paths := checkedFiles(sourcePath)
+    h := sha256.New()
+    fmt.Fprintf(h, "%s\x00%s\x00", runtime.Version(), filepath.Base(sourcePath))
+    sources := make([][]byte, len(paths))
+    for i, path := range paths {
+        if path == sourcePath {
+            sources[i] = src
+        } else if data, err := ioutil.ReadFile(path); err == nil {
+            sources[i] = data
+        } else {
+            return nil, err
+        }
+        fmt.Fprintf(h, "%s\x00%x\x00", filepath.Base(path), sha256.Sum256(sources[i]))
+    }
+    cachePath := ""
+    if dir, err := os.UserCacheDir(); err == nil {
+        cachePath = filepath.Join(dir, "golit", "types", fmt.Sprintf("%x.json", h.Sum(nil)))
+        if data, err := ioutil.ReadFile(cachePath); err == nil {
+            titles := []typeTitle{}
+            if json.Unmarshal(data, &titles) == nil {
+                return titles, nil
+            }
+        }
+    }
+
+    fset := token.NewFileSet()
+    files := []*ast.File{}
+    var target *ast.File
+    for i, path := range paths {
+        file, err := parser.ParseFile(fset, path, sources[i], 0)
+        if err != nil {
+            return nil, err
+        }
+        if path == sourcePath {
+            target = file
+        }
+        files = append(files, file)
+    }
+    info := &types.Info{Defs: map[*ast.Ident]types.Object{}, Uses: map[*ast.Ident]types.Object{}}
+    config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+    pkg, err := config.Check(target.Name.Name, fset, files, info)
+    if err != nil {
+        return nil, err
+    }
+    titles := []typeTitle{}
+    add := func(ident *ast.Ident, obj types.Object) {
+        if obj == nil {
+            return
+        }
+        pos := fset.Position(ident.Pos())
+        if pos.Filename != sourcePath {
+            return
+        }
+        titles = append(titles, typeTitle{pos.Line, pos.Column, ident.Name, types.ObjectString(obj, types.RelativeTo(pkg))})
+    }
+    for ident, obj := range info.Defs {
+        add(ident, obj)
+    }
+    for ident, obj := range info.Uses {
+        add(ident, obj)
+    }
+
+    if cachePath != "" {
+        if data, err := json.Marshal(titles); err == nil {
+            if os.MkdirAll(filepath.Dir(cachePath), 0755) == nil {
+                writeFileAtomic(cachePath, data)
+            }
+        }
+    }
+    return titles, nil
+}
+
+// The other Go files in the directory of `sourcePath`: its package,
+// its tests and its external tests, any of which type-checking it can
+// depend on.
+func packageMates(sourcePath string) []string {
+    paths, _ := filepath.Glob(filepath.Join(filepath.Dir(sourcePath), "*.go"))
+    mates := []string{}
+    for _, path := range paths {
+        if filepath.Clean(path) != filepath.Clean(sourcePath) {
+            mates = append(mates, path)
+        }
+    }
+    return mates
+}
+
+// The files of the package `sourcePath` is in, as a build would have
+// them: with its tests if it's a test, or just its external test
+// package if it's in one. A file the build would leave out is checked
+// alone.
+func checkedFiles(sourcePath string) []string {
+    dir, base := filepath.Dir(sourcePath), filepath.Base(sourcePath)
+    pkg, err := gobuild.ImportDir(dir, 0)
+    if err != nil {
+        return []string{sourcePath}
+    }
+    has := func(names []string) bool {
+        for _, name := range names {
+            if name == base {
+                return true
+            }
+        }
+        return false
+    }
+    var names []string
+    switch {
+    case has(pkg.GoFiles):
+        names = pkg.GoFiles
+    case has(pkg.TestGoFiles):
+        names = append(append([]string{}, pkg.GoFiles...), pkg.TestGoFiles...)
+    case has(pkg.XTestGoFiles):
+        names = pkg.XTestGoFiles
+    default:
+        return []string{sourcePath}
+    }
+    paths := []string{}
+    for _, name := range names {
+        paths = append(paths, filepath.Join(dir, name))
+    }
+    return paths
+}
@@ -0,0 +1,87 @@
+// ### Source revision
+
+// Docs drift from the code they describe, so every page says which
+// revision of it they were generated from. When the sources are in a
+// git work tree, we ask git for the commit checked out, and whether
+// anything has changed since, once per build, and put that in a line
+// at the foot of each page of a site, linking to the commit under
+// `--repo-url`. Outside a repository the line is left out, and so is
+// git, when nothing would show the line. Whether the tree is dirty
+// depends on where golit runs, so a site that needs to come out the
+// same everywhere should use `--no-vcs-info`.
+
+package main
+
+import (
+    "flag"
+    "path/filepath"
+    "strings"
+)
+
+var noVCSInfo bool
+
+func init() {
+    flag.BoolVar(&noVCSInfo, "no-vcs-info", false, "leave the git revision of the sources off of pages")
+}
+
+// The revision of the sources being built, or nil when there isn't
+// one to show. `Hash` is short, and `URL` links to the commit.
+type revision struct {
+    Hash  string
+    URL   string
+    Dirty bool
+}
+
+var buildRevision *revision
+
+// Whether the pages of this build would show the revision: only a
+// site's do, and not as fragments, or with a template that doesn't use
+// it. A page written to stdout or `-o` comes out the same wherever it's
+// built.
+func showsRevision() bool {
+    return !noVCSInfo && outDir != "" && !fragment && strings.Contains(templateText, ".Revision")
+}
+
+// Find the revision of the work tree that `sources` are in.
+func readRevision(sources []string) {
+    buildRevision = nil
+    if !showsRevision() || len(sources) == 0 {
+        return
+    }
+    dir := "."
+    if sources[0] != "-" {
+        abs, err := filepath.Abs(sources[0])
+        if err != nil {
+            return
+        }
+        dir = filepath.Dir(abs)
+    }
+    hash, err := pipe("git", []string{"-C", dir, "rev-parse", "HEAD"}, "")
+    if err != nil {
+        verbosef("not showing the revision: %v", err)
+        return
+    }
+    hash = strings.TrimSpace(hash)
+    if !isHash(hash) || len(hash) < 7 {
+        return
+    }
+    status, err := pipe("git", []string{"-C", dir, "status", "--porcelain"}, "")
+    if err != nil {
+        verbosef("not showing the revision: %v", err)
+        return
+    }
+    buildRevision = &revision{Hash: hash[:7], URL: commitURL(hash), Dirty: strings.TrimSpace(status) != ""}
+}
+
+// The revision as it goes into the options hash, so that pages are
+// rebuilt when it changes.
+func revisionKey() string {
+    if buildRevision == nil {
+        return ""
+    }
+    key := buildRevision.Hash
+    if buildRevision.Dirty {
+        key += "+dirty"
+    }
+    return key
+}
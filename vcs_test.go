@@ -0,0 +1,82 @@
+package main
+
+import (
+    "io/ioutil"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestShowsRevision(t *testing.T) {
+    defer func(saved bool) { noVCSInfo = saved }(noVCSInfo)
+    defer func(saved bool) { fragment = saved }(fragment)
+    defer func(saved string) { outDir = saved }(outDir)
+    defer func(saved string) { templateText = saved }(templateText)
+    cases := []struct {
+        name      string
+        outDir    string
+        noVCSInfo bool
+        fragment  bool
+        template  string
+        want      bool
+    }{
+        {"site", "site", false, false, pageHTML, true},
+        {"stdout", "", false, false, pageHTML, false},
+        {"no-vcs-info", "site", true, false, pageHTML, false},
+        {"fragment", "site", false, true, pageHTML, false},
+        {"template without it", "site", false, false, "{{.Content}}", false},
+        {"footer partial", "site", false, false, "{{.Content}}\x00{{.Revision.Hash}}", true},
+    }
+    for _, c := range cases {
+        outDir, noVCSInfo, fragment, templateText = c.outDir, c.noVCSInfo, c.fragment, c.template
+        if got := showsRevision(); got != c.want {
+            t.Errorf("%s: showsRevision() = %v, want %v", c.name, got, c.want)
+        }
+    }
+}
+
+// The footer is on a site's pages, and not on a page written to
+// stdout, or with `--no-vcs-info`.
+func TestRevisionFooter(t *testing.T) {
+    git, err := exec.LookPath("git")
+    if err != nil {
+        t.Skip("no git")
+    }
+    dir := writeFiles(t, map[string]string{"a.go": "// Docs.\npackage a\n"})
+    for _, args := range [][]string{
+        {"init", "-q"},
+        {"add", "a.go"},
+        {"-c", "user.name=A", "-c", "user.email=a@example.com", "commit", "-q", "-m", "a"},
+    } {
+        cmd := exec.Command(git, append([]string{"-C", dir}, args...)...)
+        if out, err := cmd.CombinedOutput(); err != nil {
+            t.Fatalf("git %s: %v\n%s", args[0], err, out)
+        }
+    }
+
+    site := func(out string, flags ...string) string {
+        args := append([]string{"--remote-css", "--out-dir", out}, flags...)
+        if _, stderr, code := runGolit(t, dir, append(args, "a.go")...); code != 0 {
+            t.Fatalf("%q: exit %d: %s", args, code, stderr)
+        }
+        page, err := ioutil.ReadFile(filepath.Join(dir, out, "a.html"))
+        if err != nil {
+            t.Fatal(err)
+        }
+        return string(page)
+    }
+    if !strings.Contains(site("site"), `<footer id="revision">`) {
+        t.Errorf("site page has no revision footer")
+    }
+    if strings.Contains(site("plain", "--no-vcs-info"), `id="revision"`) {
+        t.Errorf("--no-vcs-info page has a revision footer")
+    }
+    stdout, stderr, code := runGolit(t, dir, "--remote-css", "a.go")
+    if code != 0 {
+        t.Fatalf("stdout: exit %d: %s", code, stderr)
+    }
+    if strings.Contains(stdout, `id="revision"`) {
+        t.Errorf("page on stdout has a revision footer")
+    }
+}
@@ -0,0 +1,118 @@
+// ### Watching
+
+// With `--watch`, golit stays running after the first build and
+// re-renders sources as they change, printing a line for each
+// rebuild. We watch the directories holding the sources rather than
+// the files themselves, because editors often save by writing a
+// temporary file and renaming it over the original, which would leave
+// a watch on the file itself pointing at nothing. A single save can
+// also produce a burst of events, so we wait for things to go quiet
+// briefly before rebuilding. With `--type-info` a change to any Go file
+// rebuilds every page of its directory, since they show its types.
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "path/filepath"
+    "strings"
+    "syscall"
+    "time"
+
+    "github.com/fsnotify/fsnotify"
+)
+
+var watching bool
+
+func init() {
+    flag.BoolVar(&watching, "watch", false, "keep running and rebuild pages when their sources change")
+}
+
+// How long to wait after the last event before rebuilding.
+var debounce = 100 * time.Millisecond
+
+// Watch `sources` until interrupted, calling `rebuild` with the ones
+// that changed, in build order. `rebuild` reports whether anything
+// went wrong.
+func watch(sources []string, rebuild func(changed []string) bool) error {
+    watcher, err := fsnotify.NewWatcher()
+    if err != nil {
+        return err
+    }
+    defer watcher.Close()
+
+    bySource := map[string]string{}
+    dirs := map[string]bool{}
+    for _, source := range sources {
+        abs, err := filepath.Abs(source)
+        if err != nil {
+            return err
+        }
+        bySource[abs] = source
+        dir := filepath.Dir(abs)
+        if !dirs[dir] {
+            if err := watcher.Add(dir); err != nil {
+                return fmt.Errorf("%s: %v", dir, err)
+            }
+            dirs[dir] = true
+        }
+    }
+
+    interrupt := make(chan os.Signal, 1)
+    signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+    defer signal.Stop(interrupt)
+
+    fmt.Fprintf(os.Stderr, "golit: watching %d file(s), ^C to stop\n", len(sources))
+    pending, pendingDirs := map[string]bool{}, map[string]bool{}
+    var quiet <-chan time.Time
+    for {
+        select {
+        case event := <-watcher.Events:
+            if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+                continue
+            }
+            name := filepath.Clean(event.Name)
+            if source, ok := bySource[name]; ok {
+                pending[source] = true
+                quiet = time.After(debounce)
+            }
+            if typeInfo && filepath.Ext(name) == ".go" {
+                pendingDirs[filepath.Dir(name)] = true
+                quiet = time.After(debounce)
+            }
+        case err := <-watcher.Errors:
+            fmt.Fprintln(os.Stderr, "golit: watch:", err)
+        case <-quiet:
+            changed := []string{}
+            for abs, source := range bySource {
+                if filepath.Ext(abs) == ".go" && pendingDirs[filepath.Dir(abs)] {
+                    pending[source] = true
+                }
+            }
+            for _, source := range sources {
+                if pending[source] {
+                    changed = append(changed, source)
+                }
+            }
+            pending, pendingDirs = map[string]bool{}, map[string]bool{}
+            if len(changed) == 0 {
+                quiet = nil
+                continue
+            }
+            quiet = nil
+            start := time.Now()
+            failed := rebuild(changed)
+            status := "rebuilt"
+            if failed {
+                status = "rebuilt with errors"
+            }
+            fmt.Fprintf(os.Stderr, "golit: %s %s in %v\n", status, strings.Join(changed, ", "), time.Since(start).Round(time.Millisecond))
+        case <-interrupt:
+            fmt.Fprintln(os.Stderr)
+            return nil
+        }
+    }
+}